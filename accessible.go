@@ -0,0 +1,255 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+
+	"code.cloudfoundry.org/bytefmt"
+)
+
+// A line mode for screen readers: no box drawing, no colors and no cursor
+// movement, state changes are announced as plain lines and every action is
+// a single letter followed by Enter.
+
+var (
+	accessible   bool
+	announceLock sync.Mutex
+)
+
+const accessibleHelp = `Commands, type the letter and press Enter:
+  s  stats
+  l  list duplicates
+  p  provenance, the directory pairs most duplicates come from
+  i  large redundant installers and disk images
+  g  history of the duplicate bytes of every root
+  x  errors, the files that couldn't be read or acted on
+  e  export duplicates
+  m  move duplicates
+  d  delete duplicates
+  k  replace duplicates with hardlinks
+  y  replace duplicates with symlinks
+  f  replace duplicates with clones of their original (btrfs, XFS, APFS)
+  b  tag duplicates for review in the file manager
+  c  delete the duplicates in caches
+  t  link duplicate trees
+  r  resolve sync conflicts
+  h  help
+  q  quit`
+
+func announce(format string, a ...interface{}) {
+	announceLock.Lock()
+	defer announceLock.Unlock()
+	fmt.Fprintln(os.Stdout, formatter.Sprintf(format, a...))
+}
+
+func announceStats() {
+	snap := takeSnapshot(0)
+	finished := "no"
+	if snap.finished {
+		finished = "yes"
+	}
+	percent := 0.0
+	if snap.stats.size > 0 {
+		percent = snap.stats.duplicatePercent()
+	}
+	announce("Scanned %d files, %s. Skipped %d. Errors %d. Duplicates %d, %s, %.2f percent, %s severity. In caches %s. Conflicts %d. Finished %s.",
+		snap.stats.count, bytefmt.ByteSize(snap.stats.size), snap.stats.skipped, errorCount(),
+		snap.stats.duplicates, bytefmt.ByteSize(snap.stats.duplicateSize), percent, severityLabels[percentSeverity(percent)],
+		formatCacheDuplicates(snap.stats), snap.conflicts, finished)
+	if content := formatContent(snap.stats); content != "" {
+		announce("Content: %s.", content)
+	}
+}
+
+// announcePhases tells when the walk is done and when every file is grouped,
+// and every error as it happens
+func announcePhases(events <-chan tEvent) {
+	for e := range events {
+		if e.kind == eventError {
+			announce("Error: %s: %v", e.path, e.err)
+			continue
+		}
+		switch e.phase {
+		case phaseHashing:
+			announce("All files found, hashing the remaining files.")
+		case phaseFinished:
+			announce("Scan finished.")
+			announceStats()
+			for _, notice := range listNotices() {
+				announce("Notice: %s", notice)
+			}
+		}
+	}
+}
+
+func announceGroups() {
+	groups := duplicateGroups()
+	if len(groups) == 0 {
+		announce("No duplicates found yet.")
+		return
+	}
+	for i, group := range groups {
+		announce("Group %d of %d, %d copies, %s reclaimable, %s confidence. Original: %s", i+1, len(groups), len(group.files), bytefmt.ByteSize(group.reclaimable()), confidenceName(group.hash), group.files[0].path)
+		for _, dup := range group.files[1:] {
+			announce("  Duplicate: %s", dup.path)
+		}
+	}
+}
+
+type tAccessible struct {
+	lines *bufio.Scanner
+}
+
+// finish announces the outcome of an action, the session goes on
+func (a *tAccessible) finish(o tOutcome) {
+	forgetFiles(o.gone)
+	if o.report != "" {
+		announce("%s", strings.TrimSpace(o.report))
+	}
+	for _, line := range o.summary {
+		announce("%s", line)
+	}
+	if o.err != nil {
+		announce("Failed: %v", o.err)
+	}
+}
+
+// ask reads a yes/no answer, anything but y is no
+func (a *tAccessible) ask(question string) bool {
+	announce("%s Type y or n.", question)
+	if !a.lines.Scan() {
+		return false
+	}
+	return strings.EqualFold(strings.TrimSpace(a.lines.Text()), "y")
+}
+
+// confirm asks the question worded for the duplicates before the action
+// acting on them
+func (a *tAccessible) confirm(verb string, question func(list []string) string, action func() tOutcome) {
+	list := listDuplicates()
+	if len(list) == 0 {
+		announce("No duplicates to %s.", verb)
+	} else if a.ask(question(list)) {
+		a.finish(action())
+	}
+}
+
+func (a *tAccessible) resolveConflicts() {
+	for _, c := range conflicts {
+		if _, err := os.Lstat(c.copy); err != nil {
+			continue
+		}
+		announce("Sync conflict. Original: %s. Conflict copy: %s.", describeFile(c.base), describeFile(c.copy))
+		announce("Type o to keep the original, c to keep the conflict copy, b to keep both, s to stop.")
+		if !a.lines.Scan() {
+			return
+		}
+		switch strings.TrimSpace(a.lines.Text()) {
+		case "o":
+			if dryRun {
+				announce("Would delete the conflict copy.")
+			} else if err := removeFile(c.copy); err != nil {
+				announce("Couldn't delete the conflict copy: %v", err)
+			} else {
+				announce("Kept the original.")
+			}
+		case "c":
+			if dryRun {
+				announce("Would rename the conflict copy to the original.")
+			} else if err := keepConflictCopy(c); err != nil {
+				announce("Couldn't keep the conflict copy: %v", err)
+			} else {
+				announce("Kept the conflict copy.")
+			}
+		case "s":
+			return
+		default:
+			announce("Kept both.")
+		}
+	}
+	announce("No more conflicts.")
+}
+
+// accessibleActions are the commands acting on the files
+const accessibleActions = "mdkyfbct"
+
+func runAccessible(in io.Reader, options tOptions) error {
+	a := &tAccessible{lines: bufio.NewScanner(in)}
+	announce("dup-fu is scanning %s. Type h and press Enter for help.", rootsName())
+	events := subscribe(eventPhaseChanged, eventError)
+	go findDuplicates()
+	go scan(options)
+	go announcePhases(events)
+	for a.lines.Scan() {
+		command := strings.TrimSpace(a.lines.Text())
+		if command != "" && strings.Contains(accessibleActions, command) && !scanFinished() {
+			// the groups are still being built
+			announce("Wait for the scan to finish.")
+			continue
+		}
+		switch command {
+		case "":
+		case "h":
+			announce(accessibleHelp)
+		case "s":
+			announceStats()
+		case "l":
+			announceGroups()
+		case "p":
+			announce("%s", formatProvenance())
+		case "i":
+			announce("%s", formatInstallers())
+		case "g":
+			report, err := formatHistory("")
+			if err != nil {
+				announce("%v", err)
+				continue
+			}
+			announce("%s", report)
+		case "x":
+			announce("%s", formatErrors())
+		case "e":
+			a.finish(exportDuplicates())
+		case "m":
+			a.confirm("move", func(list []string) string { return removalQuestion("Move", list) }, moveDuplicates)
+		case "d":
+			a.confirm("delete", func(list []string) string { return removalQuestion("Delete", list) }, deleteDuplicates)
+		case "k":
+			a.confirm("hardlink", func(list []string) string { return linksQuestion(opHardlink, list) }, hardlinkDuplicates)
+		case "y":
+			a.confirm("symlink", func(list []string) string { return linksQuestion(opSymlink, list) }, symlinkDuplicates)
+		case "f":
+			a.confirm("clone", func(list []string) string { return linksQuestion("clone", list) }, reflinkDuplicates)
+		case "b":
+			a.confirm("tag", tagsQuestion, tagDuplicates)
+		case "c":
+			list, size := cacheDuplicates()
+			if len(list) == 0 {
+				announce("No duplicates in caches.")
+			} else if a.ask(formatter.Sprintf("Delete %d duplicate files in caches, %s?", len(list), bytefmt.ByteSize(size))) {
+				a.finish(clearCacheDuplicates())
+			}
+		case "t":
+			if count, size := treesToLink(); count == 0 {
+				announce("No duplicate trees to link.")
+			} else if a.ask(treesQuestion(count, size)) {
+				a.finish(linkDuplicateTrees())
+			}
+		case "r":
+			if !conflictsFound() {
+				announce("Wait for the scan to finish.")
+				continue
+			}
+			a.resolveConflicts()
+		case "q":
+			return nil
+		default:
+			announce("Unknown command, type h for help.")
+		}
+	}
+	return a.lines.Err()
+}
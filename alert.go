@@ -0,0 +1,107 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"code.cloudfoundry.org/bytefmt"
+)
+
+// An alert fires when a finished scan exceeds -alert-percent or
+// -alert-size, so a scheduled run can act as a storage health check: the
+// headless modes exit with alertExitCode and -alert-webhook gets a POST.
+
+const alertExitCode = 3
+
+var errAlerted = errors.New("the alert fired")
+
+var (
+	alertPercent float64
+	alertSize    string
+	// alertSize parsed, 0 when unset
+	alertLimit   uint64
+	alertWebhook string
+	alertOnce    sync.Once
+)
+
+func alertEnabled() bool {
+	return alertPercent > 0 || alertLimit > 0
+}
+
+// alertReason tells why s raises an alert, empty when it doesn't
+func alertReason(s tStats) string {
+	var reasons []string
+	if alertPercent > 0 && s.size > 0 && s.duplicatePercent() > alertPercent {
+		reasons = append(reasons, fmt.Sprintf("duplicates are %.2f%% of %s", s.duplicatePercent(), rootsName()))
+	}
+	if alertLimit > 0 && s.duplicateSize > alertLimit {
+		reasons = append(reasons, fmt.Sprintf("%s reclaimable in %s", bytefmt.ByteSize(s.duplicateSize), rootsName()))
+	}
+	return strings.Join(reasons, ", ")
+}
+
+func postAlert(reason string, s tStats) error {
+	// text is what Slack and Mattermost incoming webhooks show
+	body, err := json.Marshal(map[string]interface{}{
+		"text":             "dup-fu: " + reason,
+		"scanDir":          absPath(scanDir),
+		"duplicatePercent": s.duplicatePercent(),
+		"duplicateSize":    s.duplicateSize,
+		"size":             s.size,
+	})
+	if err != nil {
+		return err
+	}
+	client := http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Post(alertWebhook, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("alert webhook: %s", resp.Status)
+	}
+	return nil
+}
+
+// checkAlert raises the alert once if the finished scan exceeds a threshold
+func checkAlert() bool {
+	snap := takeSnapshot(0)
+	reason := alertReason(snap.stats)
+	if !snap.finished || reason == "" {
+		return false
+	}
+	alertOnce.Do(func() {
+		addNotice("Alert: %s", reason)
+		if alertWebhook != "" {
+			if err := postAlert(reason, snap.stats); err != nil {
+				addNotice("couldn't post the alert: %v", err)
+			}
+		}
+	})
+	return true
+}
+
+func watchAlert(phases <-chan tEvent) {
+	for e := range phases {
+		if e.phase == phaseFinished {
+			checkAlert()
+			return
+		}
+	}
+}
+
+// alertError ends a headless run with alertExitCode when the alert fired,
+// main exits once the run released what it holds
+func alertError() error {
+	if alertEnabled() && checkAlert() {
+		return errAlerted
+	}
+	return nil
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/rivo/tview"
+)
+
+// Copies kept on purpose (a library two programs ship, a photo in an album
+// and in its original folder) are listed in targetDir, one absolute path per
+// line. They are never reported or acted on again, but still count as a copy
+// of the others.
+
+const allowedFile = "allowed.txt"
+
+var (
+	allowedLock sync.Mutex
+	allowed     = make(map[string]bool)
+)
+
+func loadAllowed() error {
+	f, err := os.Open(filepath.Join(targetDir, allowedFile))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	allowedLock.Lock()
+	defer allowedLock.Unlock()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if line := strings.TrimSpace(scanner.Text()); line != "" {
+			allowed[line] = true
+		}
+	}
+	return scanner.Err()
+}
+
+func isAllowed(path string) bool {
+	allowedLock.Lock()
+	defer allowedLock.Unlock()
+	if len(allowed) == 0 {
+		return false
+	}
+	return allowed[absPath(path)]
+}
+
+// allowPaths appends the paths to the list, they are skipped from now on
+func allowPaths(paths []string) error {
+	dir, err := ensureTargetDir()
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(filepath.Join(dir, allowedFile), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	allowedLock.Lock()
+	defer allowedLock.Unlock()
+	for _, path := range paths {
+		path = absPath(path)
+		if allowed[path] {
+			continue
+		}
+		if _, err := fmt.Fprintln(f, path); err != nil {
+			f.Close()
+			return err
+		}
+		allowed[path] = true
+	}
+	return f.Close()
+}
+
+// reportedGroup tells whether a group has enough copies of a -content kind,
+// across roots with -cross-only, and at least one of them isn't allowed
+func reportedGroup(list []tFileData) bool {
+	if len(list) < minCopies || !reportedContent(list) || (crossOnly && !spansRoots(list)) {
+		return false
+	}
+	for _, d := range list[1:] {
+		if !isAllowed(d.path) {
+			return true
+		}
+	}
+	return false
+}
+
+// allowSelected marks the copies of the selected group as intentional
+func allowSelected(right *tview.List) {
+	list, err := selectedGroup(right)
+	if err != nil {
+		setStatus(err.Error())
+		return
+	}
+	paths := make([]string, 0, len(list)-1)
+	for _, d := range list[1:] {
+		paths = append(paths, d.path)
+	}
+	if err := allowPaths(paths); err != nil {
+		setStatus(err.Error())
+		return
+	}
+	index := right.GetCurrentItem()
+	text, _ := right.GetItemText(index)
+	right.SetItemText(index, text, "allowed, not reported again")
+	setStatus(formatter.Sprintf("Allowed %d copies, listed in %s", len(paths), filepath.Join(targetDir, allowedFile)))
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"archive/zip"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"code.cloudfoundry.org/bytefmt"
+)
+
+// A zip archive is often kept next to the folder it was extracted to. Every
+// entry is checked against the loose files by size and by the CRC32 the
+// archive stores, if they all match the archive or the folder is redundant.
+
+// zip archives found by the walk, guarded by walkLock
+var archives []string
+
+func visitArchive(path string) {
+	if strings.EqualFold(filepath.Ext(path), ".zip") {
+		archives = append(archives, path)
+	}
+}
+
+// extractedTo returns the directory the archive is fully extracted to: the
+// folder named after it, or its own directory when the entries have a top
+// folder of their own
+func extractedTo(path string) (string, error) {
+	r, err := zip.OpenReader(path)
+	if err != nil {
+		return "", err
+	}
+	defer r.Close()
+	dir := filepath.Dir(path)
+	named := strings.TrimSuffix(path, filepath.Ext(path))
+	if matchesEntries(named, r.File) {
+		return named, nil
+	}
+	if top := topFolder(r.File); top != "" && matchesEntries(dir, r.File) {
+		return filepath.Join(dir, top), nil
+	}
+	return "", nil
+}
+
+// topFolder returns the folder every entry is in, if there is one
+func topFolder(entries []*zip.File) string {
+	top := ""
+	for _, entry := range entries {
+		i := strings.Index(entry.Name, "/")
+		if i < 1 || (top != "" && entry.Name[:i] != top) {
+			return ""
+		}
+		top = entry.Name[:i]
+	}
+	return top
+}
+
+func matchesEntries(base string, entries []*zip.File) bool {
+	files := 0
+	for _, entry := range entries {
+		if entry.FileInfo().IsDir() {
+			continue
+		}
+		files++
+		if !sameAsEntry(filepath.Join(base, filepath.FromSlash(entry.Name)), entry) {
+			return false
+		}
+	}
+	return files > 0
+}
+
+func sameAsEntry(path string, entry *zip.File) bool {
+	f, err := openFile(path)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil || info.IsDir() || uint64(info.Size()) != entry.UncompressedSize64 {
+		return false
+	}
+	h := crc32.NewIEEE()
+	if _, err := io.Copy(h, f); err != nil {
+		return false
+	}
+	return h.Sum32() == entry.CRC32
+}
+
+// findExtractedArchives adds a notice for every archive extracted nearby
+func findExtractedArchives() {
+	for _, path := range archives {
+		dir, err := extractedTo(path)
+		if err != nil && err != zip.ErrFormat {
+			publishError(path, err)
+			continue
+		}
+		if dir == "" {
+			continue
+		}
+		size := uint64(0)
+		if info, err := os.Stat(path); err == nil {
+			size = uint64(info.Size())
+		}
+		addNotice("%s is fully extracted to %s, the archive or the folder is redundant: delete the archive to reclaim %s",
+			path, dir, bytefmt.ByteSize(size))
+	}
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// A front-end shared by several people starts dup-fu per role: -rpc-viewer
+// browses the scan and the groups but is refused every action. Each act
+// request, carried out or refused, is logged to the audit log in target-dir
+// with the user the front-end names.
+
+const auditFile = "audit.jsonl"
+
+var rpcViewer bool
+
+type tAuditEntry struct {
+	Time   int64  `json:"time"`
+	User   string `json:"user"`
+	Action string `json:"action"`
+	Files  int    `json:"files"`
+	// why the action was refused or stopped, empty when it went through
+	Error string `json:"error,omitempty"`
+}
+
+func appendAudit(entry tAuditEntry) error {
+	dir, err := ensureTargetDir()
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(filepath.Join(dir, auditFile), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	if err := json.NewEncoder(f).Encode(entry); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// auditAct logs an act request, a failure to log fails the request
+func auditAct(user, action string, files int, rpcErr *tRPCError) *tRPCError {
+	entry := tAuditEntry{Time: time.Now().Unix(), User: user, Action: action, Files: files}
+	if rpcErr != nil {
+		entry.Error = rpcErr.Message
+	}
+	if err := appendAudit(entry); err != nil {
+		return &tRPCError{rpcServerError, "audit log: " + err.Error()}
+	}
+	return rpcErr
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// OS backup structures, "deduplicating" them destroys the backups
+var osBackups = []tLibrary{
+	{"Time Machine", "Backups.backupdb"},
+	{"Time Machine", ".backupbundle"},
+	{"File History", "FileHistory"},
+}
+
+// Repositories of deduplicating backup tools are recognized by their
+// layout, their content-addressed packs look like millions of unique random
+// files: nothing to find, but hours of hashing.
+var backupRepositories = []struct {
+	tool    string
+	markers []string
+}{
+	{"restic", []string{"config", "data", "index", "keys", "snapshots"}},
+	{"Borg", []string{"README", "config", "data"}},
+	{"Kopia", []string{"kopia.repository.f"}},
+}
+
+var scanBackups bool
+
+// backupRepository returns the tool path is a backup repository of, if any
+func backupRepository(path string) string {
+	for _, repo := range backupRepositories {
+		found := true
+		for _, marker := range repo.markers {
+			if _, err := os.Lstat(filepath.Join(path, marker)); err != nil {
+				found = false
+				break
+			}
+		}
+		if found {
+			return repo.tool
+		}
+	}
+	return ""
+}
+
+// visitBackupDir skips OS backups and backup repositories unless
+// -scan-backups is set
+func visitBackupDir(path string) error {
+	if scanBackups {
+		return nil
+	}
+	if name := matchLibrary(osBackups, filepath.Base(path)); name != "" {
+		addNotice("Skipped %s backup: %s", name, path)
+		return filepath.SkipDir
+	}
+	if tool := backupRepository(path); tool != "" {
+		addNotice("Skipped %s repository: %s", tool, path)
+		return filepath.SkipDir
+	}
+	return nil
+}
@@ -0,0 +1,327 @@
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+	"math/bits"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// The bursts command finds the near-duplicate sets of a photo library:
+// burst sequences and repeated shots, taken a moment apart and looking
+// alike without being the same file. They're reported apart from the exact
+// duplicates, with the sharpest photo of each set suggested as the one to
+// keep. Photos are matched by a difference hash, the sharpness is the
+// variance of the Laplacian of a reduced copy.
+
+const (
+	// differing bits of two difference hashes still alike
+	burstDistance = 12
+	// width of the reduced copy the sharpness is measured on
+	sharpnessWidth = 256
+	exifTimeFormat = "2006:01:02 15:04:05"
+)
+
+var burstGap time.Duration
+
+type tBurstPhoto struct {
+	file      tFileData
+	taken     time.Time
+	hash      uint64
+	sharpness float64
+}
+
+// reduce averages the gray levels of the image over a w x h grid, a cell is
+// sampled at most 8 times a side to keep large photos quick
+func reduce(img image.Image, w, h int) []float64 {
+	b := img.Bounds()
+	cells := make([]float64, w*h)
+	for y := 0; y < h; y++ {
+		y0, y1 := b.Min.Y+y*b.Dy()/h, b.Min.Y+(y+1)*b.Dy()/h
+		for x := 0; x < w; x++ {
+			x0, x1 := b.Min.X+x*b.Dx()/w, b.Min.X+(x+1)*b.Dx()/w
+			sum, n := 0.0, 0
+			for py := y0; py < y1; py += (y1 - y0 + 7) / 8 {
+				for px := x0; px < x1; px += (x1 - x0 + 7) / 8 {
+					sum += float64(color.GrayModel.Convert(img.At(px, py)).(color.Gray).Y)
+					n++
+				}
+			}
+			if n > 0 {
+				cells[y*w+x] = sum / float64(n)
+			}
+		}
+	}
+	return cells
+}
+
+// differenceHash sets a bit for every cell of a 9x8 grid darker than its
+// right neighbor, alike pictures differ by a few bits
+func differenceHash(img image.Image) uint64 {
+	cells := reduce(img, 9, 8)
+	var hash uint64
+	for y := 0; y < 8; y++ {
+		for x := 0; x < 8; x++ {
+			hash <<= 1
+			if cells[y*9+x] < cells[y*9+x+1] {
+				hash |= 1
+			}
+		}
+	}
+	return hash
+}
+
+// sharpness is the variance of the Laplacian, edges in focus make it large
+func sharpness(img image.Image) float64 {
+	b := img.Bounds()
+	w := sharpnessWidth
+	if b.Dx() < w {
+		w = b.Dx()
+	}
+	h := w * b.Dy() / b.Dx()
+	if w < 3 || h < 3 {
+		return 0
+	}
+	gray := reduce(img, w, h)
+	var sum, sumSquares float64
+	n := 0
+	for y := 1; y < h-1; y++ {
+		for x := 1; x < w-1; x++ {
+			i := y*w + x
+			lap := 4*gray[i] - gray[i-1] - gray[i+1] - gray[i-w] - gray[i+w]
+			sum += lap
+			sumSquares += lap * lap
+			n++
+		}
+	}
+	mean := sum / float64(n)
+	return sumSquares/float64(n) - mean*mean
+}
+
+// exifTime reads DateTimeOriginal and SubSecTimeOriginal from the Exif block
+// of a JPEG, burst shots are often taken within the same second
+func exifTime(path string) (time.Time, bool) {
+	f, err := openFile(path)
+	if err != nil {
+		return time.Time{}, false
+	}
+	defer f.Close()
+	head := make([]byte, 128*1024)
+	n, _ := io.ReadFull(f, head)
+	head = head[:n]
+	if len(head) < 4 || head[0] != 0xff || head[1] != 0xd8 {
+		return time.Time{}, false
+	}
+	for i := 2; i+4 <= len(head) && head[i] == 0xff; {
+		marker, size := head[i+1], int(binary.BigEndian.Uint16(head[i+2:]))
+		end := i + 2 + size
+		if end > len(head) {
+			end = len(head)
+		}
+		segment := head[i+4 : end]
+		if marker == 0xe1 && strings.HasPrefix(string(segment), "Exif\x00\x00") {
+			return tiffTime(segment[6:])
+		}
+		if marker == 0xda {
+			// the image data, no Exif before it
+			break
+		}
+		i += 2 + size
+	}
+	return time.Time{}, false
+}
+
+// tiffTime walks IFD0 to the Exif IFD and reads the time tags
+func tiffTime(tiff []byte) (time.Time, bool) {
+	if len(tiff) < 8 {
+		return time.Time{}, false
+	}
+	var order binary.ByteOrder = binary.BigEndian
+	if string(tiff[:2]) == "II" {
+		order = binary.LittleEndian
+	}
+	// entries returns the tags of the IFD at offset with their value field
+	entries := func(offset uint32) map[uint16][]byte {
+		tags := make(map[uint16][]byte)
+		if int(offset)+2 > len(tiff) {
+			return tags
+		}
+		count := int(order.Uint16(tiff[offset:]))
+		for i := 0; i < count; i++ {
+			at := int(offset) + 2 + i*12
+			if at+12 > len(tiff) {
+				break
+			}
+			tags[order.Uint16(tiff[at:])] = tiff[at : at+12]
+		}
+		return tags
+	}
+	// ascii reads a string value, inline up to 4 bytes
+	ascii := func(entry []byte) string {
+		if entry == nil {
+			return ""
+		}
+		count := order.Uint32(entry[4:])
+		value := entry[8:12]
+		if count > 4 {
+			offset := order.Uint32(entry[8:])
+			if uint64(offset)+uint64(count) > uint64(len(tiff)) {
+				return ""
+			}
+			value = tiff[offset : offset+count]
+		} else {
+			value = value[:count]
+		}
+		return strings.TrimRight(string(value), "\x00 ")
+	}
+	ifd0 := entries(order.Uint32(tiff[4:]))
+	taken := ifd0[0x0132]
+	subsec := ""
+	if pointer := ifd0[0x8769]; pointer != nil {
+		exif := entries(order.Uint32(pointer[8:]))
+		if original := exif[0x9003]; original != nil {
+			taken = original
+		}
+		subsec = ascii(exif[0x9291])
+	}
+	t, err := time.ParseInLocation(exifTimeFormat, ascii(taken), time.Local)
+	if err != nil {
+		return time.Time{}, false
+	}
+	if subsec != "" {
+		if fraction, err := time.ParseDuration("0." + subsec + "s"); err == nil {
+			t = t.Add(fraction)
+		}
+	}
+	return t, true
+}
+
+// burstCandidates is one photo of every content, the exact duplicates are
+// reported by the scan already
+func burstCandidates() []tFileData {
+	duplicatesLock.Lock()
+	defer duplicatesLock.Unlock()
+	var files []tFileData
+	for hash, list := range duplicates {
+		if !strings.HasPrefix(hash, "partial:") {
+			files = append(files, list[0])
+		}
+	}
+	return files
+}
+
+// readBurstPhoto decodes a photo, files that aren't one are left out
+func readBurstPhoto(d tFileData) (tBurstPhoto, bool) {
+	if d.content == contentUnknown {
+		// the head of a file settled without a hash couldn't be read
+		d.content = sniffFile(d.path)
+	}
+	if d.content != contentImage {
+		return tBurstPhoto{}, false
+	}
+	f, err := openFile(d.path)
+	if err != nil {
+		return tBurstPhoto{}, false
+	}
+	img, _, err := image.Decode(f)
+	f.Close()
+	if err != nil || img.Bounds().Dx() == 0 || img.Bounds().Dy() == 0 {
+		return tBurstPhoto{}, false
+	}
+	photo := tBurstPhoto{file: d, hash: differenceHash(img), sharpness: sharpness(img)}
+	var ok bool
+	if photo.taken, ok = exifTime(d.path); !ok {
+		photo.taken = time.Unix(0, d.modified)
+	}
+	return photo, true
+}
+
+func readBurstPhotos(files []tFileData) []tBurstPhoto {
+	jobs := make(chan tFileData)
+	var lock sync.Mutex
+	var workers sync.WaitGroup
+	var photos []tBurstPhoto
+	for i := 0; i < runtime.NumCPU(); i++ {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			for d := range jobs {
+				if photo, ok := readBurstPhoto(d); ok {
+					lock.Lock()
+					photos = append(photos, photo)
+					lock.Unlock()
+				}
+			}
+		}()
+	}
+	for _, d := range files {
+		jobs <- d
+	}
+	close(jobs)
+	workers.Wait()
+	return photos
+}
+
+// findBursts groups the photos taken within -burst-gap of the previous one
+// and looking alike, sharpest first
+func findBursts(photos []tBurstPhoto) [][]tBurstPhoto {
+	sort.Slice(photos, func(i, j int) bool {
+		return photos[i].taken.Before(photos[j].taken)
+	})
+	var bursts [][]tBurstPhoto
+	var current []tBurstPhoto
+	flush := func() {
+		if len(current) > 1 {
+			sort.SliceStable(current, func(i, j int) bool {
+				return current[i].sharpness > current[j].sharpness
+			})
+			bursts = append(bursts, current)
+		}
+		current = nil
+	}
+	for _, photo := range photos {
+		if len(current) > 0 {
+			last := current[len(current)-1]
+			if photo.taken.Sub(last.taken) > burstGap || bits.OnesCount64(photo.hash^last.hash) > burstDistance {
+				flush()
+			}
+		}
+		current = append(current, photo)
+	}
+	flush()
+	return bursts
+}
+
+func runBursts(out io.Writer, options tOptions) error {
+	go findDuplicates()
+	waitForScan(options)
+	bursts := findBursts(readBurstPhotos(burstCandidates()))
+	if len(bursts) == 0 {
+		_, err := fmt.Fprintln(out, "No burst or repeated shots found.")
+		return err
+	}
+	for _, burst := range bursts {
+		first := burst[0].taken
+		for _, photo := range burst {
+			if photo.taken.Before(first) {
+				first = photo.taken
+			}
+		}
+		fmt.Fprintf(out, "%d photos taken %s, keep the sharpest:\n", len(burst), first.Format("2006-01-02 15:04:05"))
+		for i, photo := range burst {
+			mark := "    "
+			if i == 0 {
+				mark = "keep"
+			}
+			fmt.Fprintf(out, "  %s  %8.1f  %s\n", mark, photo.sharpness, photo.file.path)
+		}
+	}
+	return nil
+}
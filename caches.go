@@ -0,0 +1,121 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"sync/atomic"
+
+	"code.cloudfoundry.org/bytefmt"
+	"github.com/rivo/tview"
+)
+
+// Caches are rebuilt by their application when a file is missing, a
+// duplicate in one is safe to delete without looking at it. They're counted
+// on their own and Ctrl+k clears them in one go, at least one copy of every
+// group is always kept.
+
+// directory names of browser and application caches
+var cacheDirNames = map[string]bool{
+	".cache":       true,
+	"Caches":       true,
+	"Cache":        true,
+	"cache2":       true,
+	"Code Cache":   true,
+	"GPUCache":     true,
+	"CacheStorage": true,
+	"INetCache":    true,
+	"__pycache__":  true,
+}
+
+// cacheRoots are the user cache and temp directories of the platform
+var cacheRoots = userCacheRoots()
+
+func userCacheRoots() []string {
+	roots := []string{os.TempDir()}
+	if dir, err := os.UserCacheDir(); err == nil {
+		roots = append(roots, dir)
+	}
+	return roots
+}
+
+func isCachePath(path string) bool {
+	abs := absPath(path)
+	for _, root := range cacheRoots {
+		if isWithin(abs, root) {
+			return true
+		}
+	}
+	for dir := filepath.Dir(abs); dir != filepath.Dir(dir); dir = filepath.Dir(dir) {
+		if cacheDirNames[filepath.Base(dir)] {
+			return true
+		}
+	}
+	return false
+}
+
+// cacheDuplicates returns the copies in caches, keeping the original of a
+// group found only in caches
+func cacheDuplicates() ([]string, uint64) {
+	var list []string
+	var size uint64
+	for _, group := range duplicateGroups() {
+		var cached []tFileData
+		for _, d := range group.files {
+			if isCachePath(d.path) {
+				cached = append(cached, d)
+			}
+		}
+		if len(cached) == len(group.files) {
+			cached = cached[1:]
+		}
+		for _, d := range cached {
+			list = append(list, d.path)
+			size += uint64(d.size)
+		}
+	}
+	return list, size
+}
+
+func formatCacheDuplicates(s tStats) string {
+	return formatter.Sprintf("%d, %s", s.cacheDuplicates, bytefmt.ByteSize(s.cacheDuplicateSize))
+}
+
+func clearCacheDuplicates() tOutcome {
+	list, _ := cacheDuplicates()
+	if dryRun {
+		return newOutcome(opDelete, list, nil)
+	}
+	before := atomic.LoadInt64(&actionFailures)
+	removed := make([]string, 0, len(list))
+	for _, path := range list {
+		if err := removeFile(path); err != nil {
+			actionFailed(path, err)
+			continue
+		}
+		removed = append(removed, path)
+	}
+	o := newOutcome(opDelete, removed, nil)
+	o.logf("Deleted %d duplicate file(s) from caches", len(removed))
+	logFailures(&o, before)
+	return o
+}
+
+// confirmCacheClear asks once for the whole bucket, the files aren't
+// reviewed one by one
+func confirmCacheClear(app *tview.Application, right *tview.List) {
+	if !scanFinished() {
+		setStatus("Wait for the scan to finish")
+		return
+	}
+	list, size := cacheDuplicates()
+	if len(list) == 0 {
+		setStatus("No duplicates in caches")
+		return
+	}
+	text := formatter.Sprintf("Delete %d duplicate file(s) in caches, %s?", len(list), bytefmt.ByteSize(size))
+	showModal(app, "caches", text, []string{"Yes", "No"}, func(label string) {
+		if label == "Yes" {
+			runAction(app, right, "Deleting", clearCacheDuplicates)
+		}
+	})
+}
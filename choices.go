@@ -0,0 +1,96 @@
+package main
+
+import (
+	"sync"
+
+	"github.com/gdamore/tcell"
+	"github.com/rivo/tview"
+)
+
+// A group keeps its original (see -keep) and removes the copies unless told
+// otherwise: Ctrl+o opens the selected group, Space or Enter toggles a file
+// between keep and remove. A group always keeps at least one file.
+
+var (
+	choicesLock sync.Mutex
+	// by path, true to keep the file and false to remove it
+	choices = make(map[string]bool)
+)
+
+func keepFile(list []tFileData, i int) bool {
+	if keep, chosen := choices[list[i].path]; chosen {
+		return keep
+	}
+	return i == 0
+}
+
+// splitGroup returns the file kept as the original and the files to remove
+func splitGroup(list []tFileData) (tFileData, []tFileData) {
+	choicesLock.Lock()
+	defer choicesLock.Unlock()
+	if len(choices) == 0 {
+		return list[0], list[1:]
+	}
+	original := -1
+	var removed []tFileData
+	for i, d := range list {
+		if !keepFile(list, i) {
+			removed = append(removed, d)
+		} else if original < 0 {
+			original = i
+		}
+	}
+	if original < 0 {
+		// every file marked for removal, the original stays
+		return list[0], removed[1:]
+	}
+	return list[original], removed
+}
+
+func choiceText(list []tFileData, i int) string {
+	choicesLock.Lock()
+	defer choicesLock.Unlock()
+	if keepFile(list, i) {
+		return "keep    " + list[i].path
+	}
+	return "remove  " + list[i].path
+}
+
+// showChoices lists the files of the selected group to pick the ones to keep
+func showChoices(app *tview.Application, right *tview.List) {
+	list, err := selectedGroup(right)
+	if err != nil {
+		setStatus(err.Error())
+		return
+	}
+	index := right.GetCurrentItem()
+	files := tview.NewList().ShowSecondaryText(false)
+	files.SetBorder(true).SetTitle("Keep or remove (Space or Enter toggles, Esc to close)").SetTitleAlign(tview.AlignLeft)
+	for i := range list {
+		files.AddItem(choiceText(list, i), "", 0, nil)
+	}
+	toggle := func(i int) {
+		choicesLock.Lock()
+		choices[list[i].path] = !keepFile(list, i)
+		choicesLock.Unlock()
+		files.SetItemText(i, choiceText(list, i), "")
+	}
+	files.SetSelectedFunc(func(i int, _, _ string, _ rune) {
+		toggle(i)
+	})
+	files.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		if event.Key() == tcell.KeyRune && event.Rune() == ' ' {
+			toggle(files.GetCurrentItem())
+			return nil
+		}
+		return event
+	})
+	files.SetDoneFunc(func() {
+		pages.RemovePage("choices")
+		app.SetFocus(pages)
+		original, removed := splitGroup(list)
+		right.SetItemText(index, displayPath(original.path, 0), formatter.Sprintf("%d file(s) to remove", len(removed)))
+	})
+	pages.AddPage("choices", files, true, true)
+	app.SetFocus(files)
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"io"
+)
+
+// Content-defined chunking (FastCDC): cut points are picked by a rolling gear
+// hash of the content, so an insertion only changes the chunks around it and
+// files sharing most of their content share most of their chunks.
+
+const (
+	chunkMin = 16 * 1024
+	chunkAvg = 64 * 1024
+	chunkMax = 256 * 1024
+	// harder to match before chunkAvg, easier after, keeps sizes near chunkAvg
+	chunkMaskSmall = uint64(1<<18-1) << (64 - 18)
+	chunkMaskLarge = uint64(1<<14-1) << (64 - 14)
+)
+
+var gear [256]uint64
+
+func init() {
+	// splitmix64, the table only has to be fixed and well mixed
+	seed := uint64(0x9e3779b97f4a7c15)
+	for i := range gear {
+		seed += 0x9e3779b97f4a7c15
+		z := seed
+		z = (z ^ (z >> 30)) * 0xbf58476d1ce4e5b9
+		z = (z ^ (z >> 27)) * 0x94d049bb133111eb
+		gear[i] = z ^ (z >> 31)
+	}
+}
+
+type tChunk struct {
+	sum  [sha1.Size]byte
+	size int64
+}
+
+// cutPoint returns the length of the next chunk of data
+func cutPoint(data []byte) int {
+	n := len(data)
+	if n <= chunkMin {
+		return n
+	}
+	if n > chunkMax {
+		n = chunkMax
+	}
+	normal := chunkAvg
+	if n < normal {
+		normal = n
+	}
+	var fp uint64
+	i := chunkMin
+	for ; i < normal; i++ {
+		fp = (fp << 1) + gear[data[i]]
+		if fp&chunkMaskSmall == 0 {
+			return i
+		}
+	}
+	for ; i < n; i++ {
+		fp = (fp << 1) + gear[data[i]]
+		if fp&chunkMaskLarge == 0 {
+			return i
+		}
+	}
+	return n
+}
+
+func chunkFile(path string) ([]tChunk, error) {
+	f, err := openFile(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	r := bufio.NewReaderSize(f, chunkMax)
+	var chunks []tChunk
+	for {
+		data, err := r.Peek(chunkMax)
+		if len(data) == 0 {
+			if err == io.EOF {
+				return chunks, nil
+			}
+			return nil, err
+		}
+		if err != nil && err != io.EOF {
+			return nil, err
+		}
+		n := cutPoint(data)
+		chunks = append(chunks, tChunk{sha1.Sum(data[:n]), int64(n)})
+		r.Discard(n)
+	}
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+
+	"github.com/rivo/tview"
+)
+
+// clipboard commands by platform, the first one available is used
+var clipboardCommands = map[string][][]string{
+	"darwin":  {{"pbcopy"}},
+	"windows": {{"clip"}},
+	"linux":   {{"wl-copy"}, {"xclip", "-selection", "clipboard"}, {"xsel", "--clipboard", "--input"}},
+}
+
+// copyToClipboard uses the platform clipboard, or the OSC 52 escape sequence
+// which most terminals (also over SSH) forward to the local clipboard
+func copyToClipboard(text string) error {
+	for _, command := range clipboardCommands[runtime.GOOS] {
+		if _, err := exec.LookPath(command[0]); err != nil {
+			continue
+		}
+		cmd := exec.Command(command[0], command[1:]...)
+		cmd.Stdin = strings.NewReader(text)
+		if err := cmd.Run(); err == nil {
+			return nil
+		}
+	}
+	_, err := fmt.Fprintf(os.Stdout, "\x1b]52;c;%s\a", base64.StdEncoding.EncodeToString([]byte(text)))
+	return err
+}
+
+func selectedGroup(right *tview.List) ([]tFileData, error) {
+	index := right.GetCurrentItem()
+	if index < 0 || index >= len(listHashes) {
+		return nil, errors.New("no duplicate selected")
+	}
+	duplicatesLock.Lock()
+	defer duplicatesLock.Unlock()
+	return append([]tFileData(nil), duplicates[listHashes[index]]...), nil
+}
+
+// copySelected copies the selected original's path, or every path of the group
+func copySelected(right *tview.List, group bool) {
+	list, err := selectedGroup(right)
+	if err != nil {
+		setStatus(err.Error())
+		return
+	}
+	paths := []string{list[0].path}
+	if group {
+		paths = make([]string, 0, len(list))
+		for _, d := range list {
+			paths = append(paths, d.path)
+		}
+	}
+	if err := copyToClipboard(strings.Join(paths, "\n")); err != nil {
+		setStatus("Copy failed: " + err.Error())
+		return
+	}
+	setStatus(formatter.Sprintf("Copied %d path(s)", len(paths)))
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+
+	"code.cloudfoundry.org/bytefmt"
+)
+
+// The command comes first, its options and directories after it:
+// `dup-fu report -include "*.jpg" /photos /backup`. scan, report and clean
+// take every directory as a scan root, -target names the target-dir. Without
+// a command dup-fu scans, and a second directory is the target-dir as it
+// always was.
+
+type tCommand struct {
+	name    string
+	usage   string
+	summary string
+}
+
+var commands = []tCommand{
+	{"scan", "[options] [dir...]", "find duplicates interactively, in the TUI or with -simple-ui, -accessible or -rpc"},
+	{"report", "[options] [dir...]", "scan and print the duplicate groups, as JSON or CSV with -output"},
+	{"clean", "[options] [dir...]", "scan and apply -action to every duplicate, asks first unless -yes"},
+	{"plan", "[options] [scan-dir] [target-dir]", "scan and print the -action operations as a JSON plan"},
+	{"apply", "[options] plan.json", "verify and apply the operations of a plan"},
+	{"decisions", "[options] [scan-dir]", "scan and print every grouped file as CSV with a keep or remove decision"},
+	{"review", "[options] [scan-dir] > review.html", "scan and write a gallery page of the photo and video groups, it saves a decisions file"},
+	{"apply-decisions", "[options] decisions.csv", "verify and apply -action to the files a decisions file marks remove"},
+	{"similar", "[options] [scan-dir]", "list files sharing most of their content"},
+	{"bursts", "[options] [scan-dir]", "list burst and repeated photos with the sharpest one to keep"},
+	{"layers", "[options] [scan-dir]", "list container image layers stored more than once"},
+	{"history", "[options] [scan-dir]", "graph the duplicate bytes of the scanned roots over time"},
+	{"cache", "stats|prune|verify|migrate|export|import [options] hash-list [new-root]", "count, drop the stale entries of, check a -sample of, rehash with -hash, export or import a -hashes-from list"},
+	{"service", "install|uninstall|start [options] [dir...]", "schedule a weekly digest run"},
+	{"hash-helper", "[options]", "list and hash the files a -helper scan can't read, started by that scan"},
+	{"help", "", "print this help"},
+}
+
+// assumeYes skips the confirmation of clean
+var assumeYes bool
+
+// commandName returns the command the arguments start with, if any
+func commandName(args []string) string {
+	if len(args) == 0 {
+		return ""
+	}
+	for _, c := range commands {
+		if c.name == args[0] {
+			return c.name
+		}
+	}
+	return ""
+}
+
+// rootsOnly tells whether every directory argument of the command is a root
+func rootsOnly(command string) bool {
+	return command == "scan" || command == "report" || command == "clean"
+}
+
+func usage() {
+	out := flag.CommandLine.Output()
+	fmt.Fprintln(out, "Usage: dup-fu [command] [options] [scan-dir] [target-dir]")
+	fmt.Fprintln(out, "\nCommands:")
+	for _, c := range commands {
+		fmt.Fprintf(out, "  %-16s %s\n", c.name, c.summary)
+		if c.usage != "" {
+			fmt.Fprintf(out, "                   dup-fu %s %s\n", c.name, c.usage)
+		}
+	}
+	fmt.Fprintln(out, "\nOptions:")
+	flag.PrintDefaults()
+}
+
+// runReport prints every group, the original first, largest waste first
+func runReport(out io.Writer, options tOptions) error {
+	go findDuplicates()
+	waitForScan(options)
+	switch outputFormat {
+	case outputJSON:
+		return writeJSONReport(out)
+	case outputCSV:
+		return writeCSVReport(out)
+	}
+	groups := duplicateGroups()
+	if len(groups) == 0 {
+		_, err := fmt.Fprintln(out, "No duplicates.")
+		return err
+	}
+	total := uint64(0)
+	for _, g := range groups {
+		total += g.reclaimable()
+		fmt.Fprintf(out, "%8s  %d copies of %s:\n", bytefmt.ByteSize(g.reclaimable()), len(g.files), bytefmt.ByteSize(uint64(g.files[0].size)))
+		fmt.Fprintf(out, "          %s (original)\n", g.files[0].path)
+		for _, d := range g.files[1:] {
+			fmt.Fprintf(out, "          %s\n", d.path)
+		}
+	}
+	_, err := fmt.Fprint(out, formatter.Sprintf("%8s  reclaimable in %d group(s)\n", bytefmt.ByteSize(total), len(groups)))
+	return err
+}
+
+// runClean acts on every duplicate like the TUI hotkeys, once confirmed
+func runClean(in io.Reader, out io.Writer, options tOptions) error {
+	go findDuplicates()
+	waitForScan(options)
+	list := listDuplicates()
+	if len(list) == 0 {
+		_, err := fmt.Fprintln(out, "No duplicates.")
+		return err
+	}
+	if !dryRun && !assumeYes {
+		fmt.Fprint(out, formatter.Sprintf("%s %d duplicate file(s), %s? [y/N] ", strings.ToUpper(planAction[:1])+planAction[1:], len(list), bytefmt.ByteSize(duplicatesSize(list))))
+		answer, _ := bufio.NewReader(in).ReadString('\n')
+		if !strings.EqualFold(strings.TrimSpace(answer), "y") {
+			return nil
+		}
+	}
+	switch planAction {
+	case opMove:
+		return printOutcome(moveDuplicates())
+	case opHardlink:
+		return printOutcome(hardlinkDuplicates())
+	case opSymlink:
+		return printOutcome(symlinkDuplicates())
+	case opReflink:
+		return printOutcome(reflinkDuplicates())
+	case opTag:
+		return printOutcome(tagDuplicates())
+	}
+	return printOutcome(deleteDuplicates())
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"bytes"
+	"io"
+)
+
+const compareBufferSize = 1024 * 1024
+
+// sameContent compares two files byte by byte, stopping at the first difference
+func sameContent(a, b string) (bool, error) {
+	fa, err := openFile(a)
+	if err != nil {
+		return false, err
+	}
+	defer fa.Close()
+	fb, err := openFile(b)
+	if err != nil {
+		return false, err
+	}
+	defer fb.Close()
+	return sameReaders(fa, fb)
+}
+
+// isPrefix reports whether a is the beginning of b, e.g. an interrupted copy
+func isPrefix(a, b string) (bool, error) {
+	fa, err := openFile(a)
+	if err != nil {
+		return false, err
+	}
+	defer fa.Close()
+	fb, err := openFile(b)
+	if err != nil {
+		return false, err
+	}
+	defer fb.Close()
+	info, err := fa.Stat()
+	if err != nil {
+		return false, err
+	}
+	return sameReaders(fa, io.LimitReader(fb, info.Size()))
+}
+
+func sameReaders(fa, fb io.Reader) (bool, error) {
+	bufA := make([]byte, compareBufferSize)
+	bufB := make([]byte, compareBufferSize)
+	for {
+		na, errA := io.ReadFull(fa, bufA)
+		nb, errB := io.ReadFull(fb, bufB)
+		if !bytes.Equal(bufA[:na], bufB[:nb]) {
+			return false, nil
+		}
+		endA := errA == io.EOF || errA == io.ErrUnexpectedEOF
+		endB := errB == io.EOF || errB == io.ErrUnexpectedEOF
+		if errA != nil && !endA {
+			return false, errA
+		}
+		if errB != nil && !endB {
+			return false, errB
+		}
+		if endA || endB {
+			return endA && endB, nil
+		}
+	}
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Every group carries how sure dup-fu is that its files are the same: a
+// partial hash of a VM disk only covers the head and the tail, a 32 bit
+// hash collides once in a few billion pairs, a pair compared byte by byte or
+// a 128 bit hash leaves no doubt. Destructive actions skip the groups below
+// -min-confidence, medium by default: a partial group is only acted on when
+// -min-confidence low asks for it.
+
+const (
+	confidenceLow = iota
+	confidenceMedium
+	confidenceHigh
+)
+
+var (
+	confidenceNames = []string{"low", "medium", "high"}
+	minConfidence   string
+	// minConfidence parsed by setMinConfidence
+	minConfidenceLevel int
+)
+
+func setMinConfidence(name string) error {
+	for level, n := range confidenceNames {
+		if n == name {
+			minConfidenceLevel = level
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid -min-confidence value: %s", name)
+}
+
+// groupConfidence tells the confidence level from the group key
+func groupConfidence(hash string) int {
+	switch {
+	case strings.HasPrefix(hash, "partial:"):
+		return confidenceLow
+	case strings.HasPrefix(hash, "compared:"):
+		return confidenceHigh
+	case len(hash) >= 32:
+		// md5 and longer, from -hash or -hashes-from
+		return confidenceHigh
+	default:
+		return confidenceMedium
+	}
+}
+
+func confidenceName(hash string) string {
+	return confidenceNames[groupConfidence(hash)]
+}
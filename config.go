@@ -0,0 +1,141 @@
+package main
+
+import (
+	_ "embed"
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Options can be kept in a config file, one per line as on the command line
+// (`-hash blake3`), # starts a comment. The file is read before the command
+// line, which wins. -init writes a starter file, embedded in the binary so a
+// release is a single file.
+//
+// A [name] line starts a profile, its options apply on top of the ones
+// before the first profile with -profile name. A profile line without a
+// leading - is a directory: the scan-dir, then the target-dir, used when the
+// command line has none.
+
+const configFile = "config"
+
+//go:embed dupfu.conf
+var starterConfig string
+
+var (
+	initConfig bool
+	profile    string
+	// the directories of the profile
+	profileDirs []string
+)
+
+func configPath() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "dup-fu", configFile), nil
+}
+
+// profileName finds -profile on the command line, it's needed before the
+// command line is parsed
+func profileName(args []string) string {
+	for i, arg := range args {
+		name := strings.TrimPrefix(strings.TrimPrefix(arg, "-"), "-")
+		if name == "profile" && i+1 < len(args) {
+			return args[i+1]
+		}
+		if strings.HasPrefix(name, "profile=") {
+			return strings.TrimPrefix(name, "profile=")
+		}
+	}
+	return ""
+}
+
+// configArgs returns the options of the config file as arguments, the
+// global ones then those of the profile, and the directories of the profile
+func configArgs(profile string) ([]string, []string, error) {
+	path, err := configPath()
+	if err != nil {
+		return nil, nil, nil
+	}
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) && profile == "" {
+		return nil, nil, nil
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	var args, dirs []string
+	section, found := "", false
+	for i, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			section = strings.TrimSpace(line[1 : len(line)-1])
+			found = found || section == profile
+			continue
+		}
+		if section != "" && section != profile {
+			continue
+		}
+		if !strings.HasPrefix(line, "-") {
+			if section == "" {
+				return nil, nil, fmt.Errorf("%s:%d: not an option: %s", path, i+1, line)
+			}
+			dirs = append(dirs, line)
+			continue
+		}
+		// -name=value keeps the spaces of the value and works for booleans
+		if fields := strings.SplitN(line, " ", 2); len(fields) == 2 {
+			line = fields[0] + "=" + strings.TrimSpace(fields[1])
+		}
+		args = append(args, line)
+	}
+	if profile != "" && !found {
+		return nil, nil, fmt.Errorf("no profile %s in %s", profile, path)
+	}
+	return args, dirs, nil
+}
+
+// loadConfig sets the options of the config file and of the profile named
+// in args, before the command line
+func loadConfig(args []string) error {
+	options, dirs, err := configArgs(profileName(args))
+	if err != nil {
+		return err
+	}
+	profileDirs = dirs
+	return flag.CommandLine.Parse(options)
+}
+
+// commandArgs are the directories of the command line, those of the profile
+// without any
+func commandArgs() []string {
+	if flag.NArg() == 0 {
+		return profileDirs
+	}
+	return flag.Args()
+}
+
+// writeStarterConfig writes the embedded starter config, an existing file is
+// left alone
+func writeStarterConfig() (string, error) {
+	path, err := configPath()
+	if err != nil {
+		return "", err
+	}
+	if _, err := os.Stat(path); err == nil {
+		return "", errors.New(path + " already exists")
+	}
+	if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+		return "", err
+	}
+	return path, ioutil.WriteFile(path, []byte(starterConfig), 0644)
+}
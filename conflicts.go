@@ -0,0 +1,127 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"time"
+
+	"code.cloudfoundry.org/bytefmt"
+	"github.com/rivo/tview"
+)
+
+// conflict copies made by sync tools, the base name is the first group plus
+// the extension group
+var conflictPatterns = []*regexp.Regexp{
+	// Syncthing: name.sync-conflict-20200101-120000-ABCDEFG.ext
+	regexp.MustCompile(`^(.*)\.sync-conflict-\d{8}-\d{6}(?:-[A-Z0-9]{7})?(\.[^.]*)?$`),
+	// Dropbox, Nextcloud: name (Bob's conflicted copy 2020-01-01).ext
+	regexp.MustCompile(`^(.*) \([^)]*conflicted copy[^)]*\)(\.[^.]*)?$`),
+}
+
+type tConflict struct {
+	base string
+	copy string
+}
+
+var conflicts []tConflict
+
+func conflictBase(path string) (string, bool) {
+	name := filepath.Base(path)
+	for _, pattern := range conflictPatterns {
+		if m := pattern.FindStringSubmatch(name); m != nil {
+			return filepath.Join(filepath.Dir(path), m[1]+m[2]), true
+		}
+	}
+	return "", false
+}
+
+// visitConflict records path if it's a conflict copy, whatever its content
+func visitConflict(path string) {
+	if base, ok := conflictBase(path); ok {
+		conflicts = append(conflicts, tConflict{base, path})
+	}
+}
+
+func describeFile(path string) string {
+	info, err := os.Stat(path)
+	if err != nil {
+		return path + " (missing)"
+	}
+	return formatter.Sprintf("%s (%s, %s)", path, bytefmt.ByteSize(uint64(info.Size())), info.ModTime().Format(time.RFC822))
+}
+
+// conflictsFound tells whether the walk is done, every conflict copy is
+// known then
+func conflictsFound() bool {
+	duplicatesLock.Lock()
+	defer duplicatesLock.Unlock()
+	return stats.complted
+}
+
+// keepConflictCopy puts the conflict copy in place of the original, sent to
+// the trash first so the choice can be undone
+func keepConflictCopy(c tConflict) error {
+	if _, err := os.Lstat(c.base); err == nil {
+		if err := removeFile(c.base); err != nil {
+			return err
+		}
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+	return os.Rename(c.copy, c.base)
+}
+
+// resolveConflicts walks through the conflict copies one modal at a time, a
+// dry run shows what the choices would have done once they're all made
+func resolveConflicts(app *tview.Application) {
+	if !conflictsFound() {
+		setStatus("Wait for the scan to finish")
+		return
+	}
+	pendingConflicts := conflicts
+	var o tOutcome
+	done := func() {
+		if o.report != "" {
+			showOutcome(app, o)
+		}
+	}
+	var next func()
+	next = func() {
+		if len(pendingConflicts) == 0 {
+			done()
+			return
+		}
+		c := pendingConflicts[0]
+		pendingConflicts = pendingConflicts[1:]
+		if _, err := os.Lstat(c.copy); err != nil {
+			// already resolved
+			next()
+			return
+		}
+		text := "Sync conflict\n\nOriginal: " + describeFile(c.base) + "\nConflict copy: " + describeFile(c.copy)
+		buttons := []string{"Keep original", "Keep conflict copy", "Keep both", "Stop"}
+		showModal(app, "conflict", text, buttons, func(label string) {
+			switch label {
+			case "Keep original":
+				if dryRun {
+					o.report += fmt.Sprintf("would remove %s\n", c.copy)
+				} else if err := removeFile(c.copy); err != nil {
+					publishError(c.copy, err)
+				}
+			case "Keep conflict copy":
+				if dryRun {
+					o.report += fmt.Sprintf("would rename %s to %s\n", c.copy, c.base)
+				} else if err := keepConflictCopy(c); err != nil {
+					publishError(c.copy, err)
+				}
+			case "Stop":
+				done()
+				return
+			}
+			next()
+		})
+	}
+	next()
+}
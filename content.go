@@ -0,0 +1,129 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"code.cloudfoundry.org/bytefmt"
+)
+
+// Files are classified by their first bytes while they're hashed, not by
+// their extension: a downloads folder is full of .jpg pages and .zip
+// installers. -content reports only the groups of the listed kinds.
+
+const (
+	contentUnknown = iota
+	contentImage
+	contentVideo
+	contentAudio
+	contentArchive
+	contentDocument
+	contentBinary
+	contentKinds
+
+	// http.DetectContentType looks at most at this many bytes
+	sniffSize = 512
+)
+
+var (
+	contentNames = []string{"unknown", "image", "video", "audio", "archive", "document", "binary"}
+	contentTypes string
+	// contentTypes parsed by setContentFilter, empty reports every kind
+	contentFilter = make(map[int]bool)
+)
+
+// archive formats http.DetectContentType doesn't know
+var archiveMagic = [][]byte{
+	[]byte("7z\xbc\xaf\x27\x1c"),
+	[]byte("\xfd7zXZ\x00"),
+	[]byte("BZh"),
+	[]byte("\x28\xb5\x2f\xfd"),
+}
+
+func setContentFilter(list string) error {
+	if list == "" {
+		return nil
+	}
+	for _, name := range strings.Split(list, ",") {
+		kind := contentKind(strings.TrimSpace(name))
+		if kind == contentUnknown {
+			return fmt.Errorf("invalid -content value: %s", name)
+		}
+		contentFilter[kind] = true
+	}
+	return nil
+}
+
+func contentKind(name string) int {
+	for kind, n := range contentNames {
+		if n == name {
+			return kind
+		}
+	}
+	return contentUnknown
+}
+
+// sniffContent classifies the head of a file
+func sniffContent(head []byte) int {
+	for _, magic := range archiveMagic {
+		if bytes.HasPrefix(head, magic) {
+			return contentArchive
+		}
+	}
+	mime := http.DetectContentType(head)
+	switch {
+	case strings.HasPrefix(mime, "image/"):
+		return contentImage
+	case strings.HasPrefix(mime, "video/"):
+		return contentVideo
+	case strings.HasPrefix(mime, "audio/"), mime == "application/ogg":
+		return contentAudio
+	case mime == "application/zip", mime == "application/x-gzip", mime == "application/x-rar-compressed":
+		return contentArchive
+	case strings.HasPrefix(mime, "text/"), mime == "application/pdf", mime == "application/postscript":
+		return contentDocument
+	default:
+		return contentBinary
+	}
+}
+
+// sniffFile reads the head of a file about to be hashed, the read warms the
+// cache for the hash
+func sniffFile(path string) int {
+	f, err := openFile(path)
+	if err != nil {
+		return contentUnknown
+	}
+	defer f.Close()
+	head := make([]byte, sniffSize)
+	n, _ := f.Read(head)
+	return sniffContent(head[:n])
+}
+
+// contentOf is the kind the store keeps for an unchanged file, a file
+// settled without a hash is sniffed otherwise
+func contentOf(data tFileData) int {
+	if stored, exist := storedFile(data); exist && stored.content != contentUnknown {
+		return stored.content
+	}
+	return sniffFile(data.path)
+}
+
+// reportedContent tells whether a group passes -content
+func reportedContent(list []tFileData) bool {
+	return len(contentFilter) == 0 || contentFilter[list[0].content]
+}
+
+// formatContent is the stats breakdown, the kinds found with their file
+// count and duplicate size
+func formatContent(s tStats) string {
+	parts := make([]string, 0, contentKinds)
+	for kind := contentImage; kind < contentKinds; kind++ {
+		if s.contentCount[kind] > 0 {
+			parts = append(parts, formatter.Sprintf("%s %d (%s dup)", contentNames[kind], s.contentCount[kind], bytefmt.ByteSize(s.contentDuplicateSize[kind])))
+		}
+	}
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Large cleanups are reviewed in a spreadsheet: `dup-fu decisions` writes
+// every file of every group as CSV with a keep or remove decision, the
+// reviewers change them, and `dup-fu apply-decisions` applies -action to the
+// files marked remove. They're verified like the operations of a plan, a
+// file changed since the export is skipped.
+
+const (
+	decisionKeep   = "keep"
+	decisionRemove = "remove"
+)
+
+var decisionsHeader = []string{"group", "decision", "path", "size", "modified", "hash"}
+
+// removedByDefault returns the copies the scan would act on, the originals
+// and the copies it leaves alone are kept
+func removedByDefault() (map[string]bool, error) {
+	list, _, err := withoutSeeding(listDuplicates())
+	if err != nil {
+		return nil, err
+	}
+	list = withoutMismatches(list)
+	removed := make(map[string]bool)
+	for _, path := range list {
+		removed[path] = true
+	}
+	return removed, nil
+}
+
+// writeDecisions writes a row for every grouped file
+func writeDecisions(out io.Writer) error {
+	removed, err := removedByDefault()
+	if err != nil {
+		return err
+	}
+	w := csv.NewWriter(out)
+	if err := w.Write(decisionsHeader); err != nil {
+		return err
+	}
+	for i, group := range duplicateGroups() {
+		for _, d := range group.files {
+			decision := decisionKeep
+			if removed[d.path] {
+				decision = decisionRemove
+			}
+			row := []string{strconv.Itoa(i + 1), decision, absPath(d.path), strconv.FormatInt(d.size, 10), strconv.FormatInt(d.modified, 10), group.hash}
+			if err := w.Write(row); err != nil {
+				return err
+			}
+		}
+	}
+	w.Flush()
+	return w.Error()
+}
+
+func runDecisions(out io.Writer, options tOptions) error {
+	go findDuplicates()
+	waitForScan(options)
+	return writeDecisions(out)
+}
+
+// readDecisions turns the rows into a plan: the first kept file of a group is
+// the original of its removed files
+func readDecisions(in io.Reader) (tPlan, error) {
+	plan := tPlan{Version: planVersion, Created: time.Now().Unix()}
+	r := csv.NewReader(in)
+	r.FieldsPerRecord = len(decisionsHeader)
+	rows, err := r.ReadAll()
+	if err != nil {
+		return plan, err
+	}
+	if len(rows) == 0 || strings.Join(rows[0], ",") != strings.Join(decisionsHeader, ",") {
+		return plan, errors.New("not a decisions file, the header is: " + strings.Join(decisionsHeader, ","))
+	}
+	var groups []string
+	originals := make(map[string]string)
+	removed := make(map[string][]tOperation)
+	for i, row := range rows[1:] {
+		group, decision, path, hash := row[0], strings.ToLower(strings.TrimSpace(row[1])), row[2], row[5]
+		size, errSize := strconv.ParseInt(row[3], 10, 64)
+		modified, errModified := strconv.ParseInt(row[4], 10, 64)
+		if errSize != nil || errModified != nil {
+			return plan, fmt.Errorf("line %d: invalid size or modified time", i+2)
+		}
+		if _, known := originals[group]; !known && removed[group] == nil {
+			groups = append(groups, group)
+		}
+		switch decision {
+		case decisionKeep:
+			if originals[group] == "" {
+				originals[group] = path
+			}
+		case decisionRemove:
+			removed[group] = append(removed[group], tOperation{Op: planAction, Path: path, Size: size, Modified: modified, Hash: hash})
+		default:
+			return plan, fmt.Errorf("line %d: the decision is keep or remove, not %q", i+2, row[1])
+		}
+	}
+	var paths []string
+	for _, group := range groups {
+		original := originals[group]
+		if original == "" {
+			// nothing would be left of the content
+			return plan, fmt.Errorf("group %s: every file is marked remove", group)
+		}
+		paths = append(paths, original)
+		for _, op := range removed[group] {
+			op.Original = original
+			plan.Operations = append(plan.Operations, op)
+			paths = append(paths, op.Path)
+		}
+	}
+	// the directory every file is in is locked while they're applied
+	if plan.ScanDir = commonDir(paths); plan.ScanDir == "" {
+		return plan, errors.New("the files share no directory")
+	}
+	plan.TargetDir = targetOption
+	if plan.TargetDir == "" {
+		plan.TargetDir = filepath.Join(plan.ScanDir, ".dup-fu")
+	}
+	return plan, nil
+}
+
+func runApplyDecisions(file string) error {
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	plan, err := readDecisions(f)
+	if err != nil {
+		return err
+	}
+	return applyPlan(plan)
+}
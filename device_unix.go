@@ -0,0 +1,34 @@
+//go:build !windows
+// +build !windows
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+)
+
+// deviceID identifies the device root lives on
+func deviceID(root string) string {
+	info, err := os.Stat(root)
+	if err != nil {
+		return root
+	}
+	if st, ok := info.Sys().(*syscall.Stat_t); ok {
+		return fmt.Sprint(st.Dev)
+	}
+	return root
+}
+
+// fileID identifies a file by its device and inode, the same whatever
+// path leads to it
+func fileID(path string, info os.FileInfo) string {
+	if helped, ok := info.(tHelperInfo); ok {
+		return helped.entry.ID
+	}
+	if st, ok := info.Sys().(*syscall.Stat_t); ok {
+		return fmt.Sprintf("%d:%d", st.Dev, st.Ino)
+	}
+	return ""
+}
@@ -0,0 +1,21 @@
+//go:build windows
+// +build windows
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// deviceID identifies the device root lives on, a drive letter or a share
+func deviceID(root string) string {
+	return strings.ToUpper(filepath.VolumeName(absPath(root)))
+}
+
+// fileID identifies a file by its absolute path, the file index would take
+// opening every file
+func fileID(path string, info os.FileInfo) string {
+	return absPath(path)
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// Scan roots are grouped by the device they live on, every device gets its
+// own walker and hashing workers so a slow USB drive doesn't hold back the
+// internal SSD. The results meet in findDuplicates. With -sequential a
+// spinning disk reads one file at a time in walk order instead of seeking
+// between two workers and the walker.
+
+type tRoots []string
+
+func (r *tRoots) String() string {
+	return strings.Join(*r, ",")
+}
+
+func (r *tRoots) Set(dir string) error {
+	*r = append(*r, dir)
+	return nil
+}
+
+type tDevice struct {
+	roots []string
+	files chan tFileData
+}
+
+var (
+	extraRoots tRoots
+	// -target, every directory argument is a root when it's set
+	targetOption string
+	// -cross-only, groups within a single root aren't reported
+	crossOnly bool
+	devices   []*tDevice
+	// guards the bookkeeping of walkers running on several devices
+	walkLock sync.Mutex
+)
+
+func scanRoots() []string {
+	return append([]string{scanDir}, extraRoots...)
+}
+
+// rootsName lists the scan roots for display
+func rootsName() string {
+	return strings.Join(scanRoots(), ", ")
+}
+
+// rootOf returns the scan root path is in
+func rootOf(path string) string {
+	abs := absPath(path)
+	for _, root := range scanRoots() {
+		if dir := absPath(root); abs == dir || isWithin(abs, dir) {
+			return root
+		}
+	}
+	return scanDir
+}
+
+// spansRoots tells whether the copies of a group are in more than one root
+func spansRoots(list []tFileData) bool {
+	for _, d := range list[1:] {
+		if rootOf(d.path) != rootOf(list[0].path) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkRoots refuses nested roots, their files would be duplicates of themselves
+func checkRoots() error {
+	roots := scanRoots()
+	for i, a := range roots {
+		for _, b := range roots[i+1:] {
+			absA, absB := absPath(a), absPath(b)
+			if absA == absB || isWithin(absA, absB) || isWithin(absB, absA) {
+				return fmt.Errorf("scan roots overlap: %s and %s", a, b)
+			}
+		}
+	}
+	return nil
+}
+
+// setupDevices groups the scan roots by device and starts their hashing workers
+func setupDevices(options tOptions) {
+	devices = nil
+	workersLock.Lock()
+	hashWorkers = nil
+	workersLock.Unlock()
+	byID := make(map[string]*tDevice)
+	for _, root := range scanRoots() {
+		id := deviceID(root)
+		d, exist := byID[id]
+		if !exist {
+			d = &tDevice{files: make(chan tFileData, 200)}
+			byID[id] = d
+			devices = append(devices, d)
+			if !options.sequential {
+				startWorkers(root, d.files, options)
+			}
+		}
+		d.roots = append(d.roots, root)
+	}
+}
+
+// hashingChannel is the channel of the device path lives on
+func hashingChannel(path string) chan tFileData {
+	for _, d := range devices {
+		for _, root := range d.roots {
+			if path == root || isWithin(path, root) {
+				return d.files
+			}
+		}
+	}
+	return devices[0].files
+}
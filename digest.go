@@ -0,0 +1,161 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/smtp"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"code.cloudfoundry.org/bytefmt"
+)
+
+const (
+	digestStateFile = "digest.json"
+	digestTopGroups = 10
+)
+
+var (
+	digest       bool
+	smtpServer   string
+	smtpUser     string
+	smtpPassword string
+	mailFrom     string
+	mailTo       string
+)
+
+// tDigestState is what the previous digest reported, for the trend
+type tDigestState struct {
+	Time          int64    `json:"time"`
+	DuplicateSize uint64   `json:"duplicateSize"`
+	Groups        []string `json:"groups"`
+}
+
+type tGroup struct {
+	hash  string
+	files []tFileData
+}
+
+// reclaimable is the space freed by removing every copy but the original
+func (g tGroup) reclaimable() uint64 {
+	return uint64(g.files[0].size) * uint64(len(g.files)-1)
+}
+
+// duplicateGroups returns the groups with duplicates, largest reclaimable first
+func duplicateGroups() []tGroup {
+	groups := takeSnapshot(-1).groups
+	if groups == nil {
+		return make([]tGroup, 0)
+	}
+	return groups
+}
+
+// waitForScan runs the scan without the TUI and returns once every file is grouped
+func waitForScan(options tOptions) {
+	go scan(options)
+	for !scanFinished() {
+		time.Sleep(100 * time.Millisecond)
+	}
+}
+
+func readDigestState(path string) (tDigestState, error) {
+	var state tDigestState
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return state, nil
+	}
+	if err != nil {
+		return state, err
+	}
+	return state, json.Unmarshal(data, &state)
+}
+
+func formatDigest(groups []tGroup, previous tDigestState) string {
+	var body bytes.Buffer
+	percent := 0.0
+	if stats.size > 0 {
+		percent = stats.duplicatePercent()
+	}
+	body.WriteString(formatter.Sprintf("dup-fu digest for %s\n\n", rootsName()))
+	body.WriteString(formatter.Sprintf("Scanned: %d file(s), %s\n", stats.count, bytefmt.ByteSize(stats.size)))
+	body.WriteString(formatter.Sprintf("Duplicates: %d file(s), %s reclaimable (%.2f%%)\n", stats.duplicates, bytefmt.ByteSize(stats.duplicateSize), percent))
+	if previous.Time > 0 {
+		seen := make(map[string]bool)
+		for _, hash := range previous.Groups {
+			seen[hash] = true
+		}
+		added := 0
+		for _, group := range groups {
+			if !seen[group.hash] {
+				added++
+			}
+		}
+		trend := "+"
+		delta := stats.duplicateSize - previous.DuplicateSize
+		if stats.duplicateSize < previous.DuplicateSize {
+			trend = "-"
+			delta = previous.DuplicateSize - stats.duplicateSize
+		}
+		since := time.Unix(previous.Time, 0).Format("2006-01-02")
+		body.WriteString(formatter.Sprintf("New duplicate groups since %s: %d\n", since, added))
+		body.WriteString(formatter.Sprintf("Trend since %s: %s%s\n", since, trend, bytefmt.ByteSize(delta)))
+	}
+	body.WriteString("\nTop offenders:\n")
+	for i, group := range groups {
+		if i == digestTopGroups {
+			break
+		}
+		body.WriteString(formatter.Sprintf("  %8s  %s (%d copies, %s confidence)\n", bytefmt.ByteSize(group.reclaimable()), group.files[0].path, len(group.files), confidenceName(group.hash)))
+	}
+	return body.String()
+}
+
+func sendMail(subject, body string) error {
+	host, _, err := net.SplitHostPort(smtpServer)
+	if err != nil {
+		return err
+	}
+	var auth smtp.Auth
+	if smtpUser != "" {
+		auth = smtp.PlainAuth("", smtpUser, smtpPassword, host)
+	}
+	recipients := strings.Split(mailTo, ",")
+	msg := fmt.Sprintf("From: %s\r\nTo: %s\r\nSubject: %s\r\nDate: %s\r\n\r\n%s",
+		mailFrom, mailTo, subject, time.Now().Format(time.RFC1123Z), strings.Replace(body, "\n", "\r\n", -1))
+	return smtp.SendMail(smtpServer, auth, mailFrom, recipients, []byte(msg))
+}
+
+// runDigest scans without the TUI, mails the digest and keeps its state for
+// the next run's trend
+func runDigest(options tOptions) error {
+	go findDuplicates()
+	waitForScan(options)
+	groups := duplicateGroups()
+	statePath := filepath.Join(targetDir, digestStateFile)
+	previous, err := readDigestState(statePath)
+	if err != nil {
+		return err
+	}
+	subject := formatter.Sprintf("dup-fu: %s reclaimable in %s", bytefmt.ByteSize(stats.duplicateSize), rootsName())
+	if err := sendMail(subject, formatDigest(groups, previous)); err != nil {
+		return err
+	}
+	state := tDigestState{Time: time.Now().Unix(), DuplicateSize: stats.duplicateSize}
+	for _, group := range groups {
+		state.Groups = append(state.Groups, group.hash)
+	}
+	data, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	dir, err := ensureTargetDir()
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filepath.Join(dir, digestStateFile), data, 0644)
+}
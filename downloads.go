@@ -0,0 +1,55 @@
+package main
+
+import (
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// A preset for Downloads folders: browsers save the same file again as
+// "name (1).ext" and leave .crdownload or .part files behind when a download
+// is interrupted. With -downloads a numbered copy is never the original, the
+// partial downloads are reported instead of hashed, and deleted duplicates go
+// to a trash in target-dir unless -recycle-dir is set.
+
+var (
+	downloadsPreset bool
+	// partial downloads found by the walk, guarded by walkLock
+	partialDownloads []string
+)
+
+var downloadCopyPattern = regexp.MustCompile(`^.+ \(\d+\)(\.[^.]*)?$`)
+
+var partialDownloadExtensions = map[string]bool{
+	".crdownload": true,
+	".part":       true,
+	".partial":    true,
+	".download":   true,
+	".opdownload": true,
+}
+
+func isDownloadCopy(path string) bool {
+	return downloadCopyPattern.MatchString(filepath.Base(path))
+}
+
+// visitDownload records a partial download, it's never hashed
+func visitDownload(path string) bool {
+	if !downloadsPreset || !partialDownloadExtensions[strings.ToLower(filepath.Ext(path))] {
+		return false
+	}
+	partialDownloads = append(partialDownloads, path)
+	return true
+}
+
+func reportPartialDownloads() {
+	for _, path := range partialDownloads {
+		addNotice("Partial download, delete it unless it's still downloading: %s", path)
+	}
+}
+
+// setDownloadsPreset keeps deleted duplicates in a trash by default
+func setDownloadsPreset() {
+	if downloadsPreset && recycleDir == "" {
+		recycleDir = filepath.Join(targetDir, "trash")
+	}
+}
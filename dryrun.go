@@ -0,0 +1,42 @@
+package main
+
+import (
+	"fmt"
+	"io"
+
+	"code.cloudfoundry.org/bytefmt"
+)
+
+// -dry-run goes through every destructive action (delete, move, the links
+// and clones, clearing caches, apply) up to the last check and reports what
+// it would do to each file, the filesystem is never touched.
+
+var dryRun bool
+
+// dryRunOperations describes the action op on the listed duplicates
+func dryRunOperations(op string, list []string) []tOperation {
+	originals := duplicateOriginals()
+	sizes := duplicateSizes()
+	ops := make([]tOperation, 0, len(list))
+	for _, path := range list {
+		ops = append(ops, tOperation{Op: op, Path: path, Original: originals[path], Size: sizes[path]})
+	}
+	return ops
+}
+
+// reportDryRun prints one line per operation and the bytes they reclaim
+func reportDryRun(out io.Writer, ops []tOperation) {
+	total := uint64(0)
+	for _, op := range ops {
+		if op.Op != opTag {
+			// a tagged file stays
+			total += uint64(op.Size)
+		}
+		line := formatter.Sprintf("would %-8s %8s  %s", op.Op, bytefmt.ByteSize(uint64(op.Size)), op.Path)
+		if op.Original != "" {
+			line += " (copy of " + op.Original + ")"
+		}
+		fmt.Fprintln(out, line)
+	}
+	fmt.Fprintln(out, formatter.Sprintf("would reclaim %s in %d file(s)", bytefmt.ByteSize(total), len(ops)))
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gdamore/tcell"
+	"github.com/rivo/tview"
+)
+
+// Nothing stops dup-fu halfway: a file or directory that can't be read,
+// deleted or moved is skipped and its error published. publishError keeps
+// them for the Errors view (Ctrl+x) and writes them to -error-log, which
+// holds the errors of the last run that had any. The Stats panel counts them.
+
+const errorLogFile = "errors.log"
+
+var (
+	errorLogPath    string
+	collectedErrors []tEvent
+	errorsLock      sync.Mutex
+	errorLog        *os.File
+	// files an action skipped on an error, its outcome tells how many
+	actionFailures int64
+)
+
+// defaultErrorLog is errors.log next to the history
+func defaultErrorLog() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "dup-fu", errorLogFile)
+}
+
+// publishError records the error before publishing it, the log is complete
+// even when a headless command exits right after
+func publishError(path string, err error) {
+	e := tEvent{kind: eventError, path: path, err: err}
+	errorsLock.Lock()
+	collectedErrors = append(collectedErrors, e)
+	writeErrorLog(e)
+	errorsLock.Unlock()
+	publish(e)
+}
+
+// writeErrorLog rewrites the log on the first error of the run
+func writeErrorLog(e tEvent) {
+	if errorLogPath == "" {
+		return
+	}
+	if errorLog == nil {
+		var err error
+		if err = os.MkdirAll(filepath.Dir(errorLogPath), os.ModePerm); err == nil {
+			errorLog, err = os.Create(errorLogPath)
+		}
+		if err != nil {
+			addNotice("couldn't write the error log: %v", err)
+			errorLogPath = ""
+			return
+		}
+	}
+	fmt.Fprintf(errorLog, "%s  %s: %v\n", time.Now().Format(time.RFC3339), e.path, e.err)
+}
+
+func errorCount() int {
+	errorsLock.Lock()
+	defer errorsLock.Unlock()
+	return len(collectedErrors)
+}
+
+// actionFailed skips a file an action couldn't act on
+func actionFailed(path string, err error) {
+	atomic.AddInt64(&actionFailures, 1)
+	publishError(path, err)
+}
+
+// logFailures adds the files skipped since before to the outcome
+func logFailures(o *tOutcome, before int64) {
+	failed := atomic.LoadInt64(&actionFailures) - before
+	if failed == 0 {
+		return
+	}
+	where := "the errors (Ctrl+x)"
+	if errorLogPath != "" {
+		where = errorLogPath
+	}
+	o.logf("Skipped %d file(s) on an error, see %s", failed, where)
+}
+
+func formatErrorCount() string {
+	if count := errorCount(); count > 0 {
+		return formatter.Sprintf("[red]Errors: %d (Ctrl+x)[-]", count)
+	}
+	return ""
+}
+
+func formatErrors() string {
+	errorsLock.Lock()
+	defer errorsLock.Unlock()
+	if len(collectedErrors) == 0 {
+		return "No errors."
+	}
+	var text strings.Builder
+	for _, e := range collectedErrors {
+		fmt.Fprintf(&text, "%s: %v\n", e.path, e.err)
+	}
+	return text.String()
+}
+
+// showErrors lists every error of the run
+func showErrors(app *tview.Application) {
+	view := newTextView("Errors (Esc to close)", formatErrors()).SetScrollable(true)
+	view.SetDoneFunc(func(tcell.Key) {
+		pages.RemovePage("errors")
+		app.SetFocus(pages)
+	})
+	pages.AddPage("errors", view, true, true)
+	app.SetFocus(view)
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"code.cloudfoundry.org/bytefmt"
+)
+
+// -estimate hashes a random sample of the files before the full scan and
+// extrapolates the duplicate share and the scan duration, a cold archive can
+// take hours to find out it has nothing to clean. A thousand files keep the
+// error within a few percent whatever the number of files.
+
+const (
+	estimateSample = 1000
+	// same-size files hashed per sampled file, bigger groups are scaled up
+	estimatePeers = 100
+)
+
+var estimate bool
+
+type tEstimate struct {
+	files         int
+	size          uint64
+	sampled       int
+	duplicateSize uint64
+	duration      time.Duration
+}
+
+// listFiles walks the roots for regular files only, none of the scan's rules apply
+func listFiles() ([]tFileData, error) {
+	var files []tFileData
+	for _, root := range scanRoots() {
+		err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+			if err == nil && info.Mode().IsRegular() && info.Size() > 0 {
+				files = append(files, tFileData{path: path, size: info.Size()})
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return files, nil
+}
+
+func makeEstimate() (tEstimate, error) {
+	files, err := listFiles()
+	if err != nil {
+		return tEstimate{}, err
+	}
+	e := tEstimate{files: len(files)}
+	bySize := make(map[int64][]tFileData)
+	for _, f := range files {
+		e.size += uint64(f.size)
+		bySize[f.size] = append(bySize[f.size], f)
+	}
+	sample := rand.Perm(len(files))
+	if len(sample) > estimateSample {
+		sample = sample[:estimateSample]
+	}
+	e.sampled = len(sample)
+	var read uint64
+	var share float64
+	start := time.Now()
+	for _, i := range sample {
+		file := files[i]
+		peers := bySize[file.size]
+		if len(peers) < 2 {
+			continue
+		}
+		sum, size, err := checksum(file.path)
+		if err != nil {
+			return e, err
+		}
+		read += uint64(size)
+		same, checked := 0, 0
+		for _, j := range rand.Perm(len(peers)) {
+			if checked == estimatePeers {
+				break
+			}
+			if peers[j].path == file.path {
+				continue
+			}
+			peerSum, size, err := checksum(peers[j].path)
+			if err != nil {
+				return e, err
+			}
+			read += uint64(size)
+			checked++
+			if string(peerSum) == string(sum) {
+				same++
+			}
+		}
+		copies := 1 + float64(same)*float64(len(peers)-1)/float64(checked)
+		// a group of k copies keeps one, every member carries (k-1)/k of it
+		share += (copies - 1) / copies * float64(file.size)
+	}
+	if e.sampled > 0 {
+		e.duplicateSize = uint64(share * float64(e.files) / float64(e.sampled))
+	}
+	if elapsed := time.Since(start); read > 0 {
+		e.duration = time.Duration(float64(elapsed) * float64(e.size) / float64(read))
+	}
+	return e, nil
+}
+
+// confirmEstimate prints the estimate and asks whether to run the full scan
+func confirmEstimate(in io.Reader, out io.Writer) (bool, error) {
+	fmt.Fprintf(out, "Sampling %s...\n", rootsName())
+	e, err := makeEstimate()
+	if err != nil {
+		return false, err
+	}
+	percent := 0.0
+	if e.size > 0 {
+		percent = float64(e.duplicateSize) / float64(e.size) * 100
+	}
+	fmt.Fprint(out, formatter.Sprintf("Estimate from %d of %d files (%s):\n", e.sampled, e.files, bytefmt.ByteSize(e.size)))
+	fmt.Fprintf(out, "  duplicates: about %.1f%% (%s)\n", percent, bytefmt.ByteSize(e.duplicateSize))
+	if e.duration > 0 {
+		fmt.Fprintf(out, "  full scan: about %s\n", e.duration.Round(time.Second))
+	}
+	fmt.Fprint(out, "Proceed with the full scan? [y/N] ")
+	answer, _ := bufio.NewReader(in).ReadString('\n')
+	return strings.EqualFold(strings.TrimSpace(answer), "y"), nil
+}
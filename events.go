@@ -0,0 +1,76 @@
+package main
+
+import (
+	"sync"
+)
+
+// The pipeline publishes what happens as events and every front-end (the
+// TUI, the headless modes, the RPC server) subscribes to the kinds it
+// shows, instead of the pipeline goroutines changing widgets directly.
+
+type tEventKind int
+
+const (
+	// a file is hashed and grouped
+	eventFileScanned tEventKind = iota
+	// a group got another copy
+	eventGroupUpdated
+	eventPhaseChanged
+	// a file or directory couldn't be read or acted on, the scan or the
+	// action goes on
+	eventError
+)
+
+const (
+	phaseWalking  = "walking"
+	phaseHashing  = "hashing"
+	phaseFinished = "finished"
+)
+
+type tEvent struct {
+	kind  tEventKind
+	file  tFileData
+	hash  string
+	group []tFileData
+	phase string
+	path  string
+	err   error
+}
+
+type tSubscriber struct {
+	kinds  map[tEventKind]bool
+	events chan tEvent
+}
+
+var (
+	subscribersLock sync.Mutex
+	subscribers     []tSubscriber
+)
+
+// subscribe returns the events of the given kinds, publish blocks while the
+// channel is full so subscribers keep reading until the scan is finished
+func subscribe(kinds ...tEventKind) <-chan tEvent {
+	s := tSubscriber{make(map[tEventKind]bool), make(chan tEvent, 100)}
+	for _, kind := range kinds {
+		s.kinds[kind] = true
+	}
+	subscribersLock.Lock()
+	subscribers = append(subscribers, s)
+	subscribersLock.Unlock()
+	return s.events
+}
+
+func publish(e tEvent) {
+	subscribersLock.Lock()
+	list := subscribers
+	subscribersLock.Unlock()
+	for _, s := range list {
+		if s.kinds[e.kind] {
+			s.events <- e
+		}
+	}
+}
+
+func publishPhase(phase string) {
+	publish(tEvent{kind: eventPhaseChanged, phase: phase})
+}
@@ -0,0 +1,217 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// -exclude takes gitignore patterns, -exclude-regex regular expressions
+// matched against the whole path. A .dupfuignore file in any scanned
+// directory adds gitignore rules relative to that directory, the last
+// matching rule wins and an excluded directory isn't walked at all.
+// -include is the other way around: only the files whose name matches one
+// of its patterns are scanned, every directory is still walked.
+
+const ignoreFile = ".dupfuignore"
+
+type tPatterns []string
+
+func (p *tPatterns) String() string {
+	return strings.Join(*p, ",")
+}
+
+func (p *tPatterns) Set(pattern string) error {
+	*p = append(*p, pattern)
+	return nil
+}
+
+type tIgnoreRule struct {
+	base string
+	// matches the slash separated path relative to base
+	pattern *regexp.Regexp
+	negate  bool
+	dirOnly bool
+}
+
+var (
+	includePatterns tPatterns
+	excludePatterns tPatterns
+	excludeRegexps  tPatterns
+	excludeCompiled []*regexp.Regexp
+	// from -exclude and the ignore files found so far, guarded by walkLock
+	ignoreRules []tIgnoreRule
+)
+
+// setIncludes splits the comma separated -include lists, names are matched
+// ignoring case: cameras write .JPG
+func setIncludes() error {
+	var patterns tPatterns
+	for _, list := range includePatterns {
+		for _, pattern := range strings.Split(list, ",") {
+			pattern = strings.ToLower(strings.TrimSpace(pattern))
+			if _, err := filepath.Match(pattern, ""); err != nil {
+				return fmt.Errorf("invalid -include pattern: %s", pattern)
+			}
+			patterns = append(patterns, pattern)
+		}
+	}
+	includePatterns = patterns
+	return nil
+}
+
+// isIncluded tells whether -include lets the file at path in
+func isIncluded(path string) bool {
+	if len(includePatterns) == 0 {
+		return true
+	}
+	name := strings.ToLower(filepath.Base(path))
+	for _, pattern := range includePatterns {
+		if matched, _ := filepath.Match(pattern, name); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// setExcludes compiles the patterns, -exclude applies from every root
+func setExcludes() error {
+	for _, expr := range excludeRegexps {
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return err
+		}
+		excludeCompiled = append(excludeCompiled, re)
+	}
+	for _, root := range scanRoots() {
+		for _, pattern := range excludePatterns {
+			if rule, ok := parseIgnoreRule(root, pattern); ok {
+				ignoreRules = append(ignoreRules, rule)
+			}
+		}
+	}
+	return nil
+}
+
+// parseIgnoreRule reads a line of gitignore syntax, blank lines and
+// comments are no rule
+func parseIgnoreRule(base, line string) (tIgnoreRule, bool) {
+	rule := tIgnoreRule{base: base}
+	if !strings.HasSuffix(line, "\\ ") {
+		line = strings.TrimRight(line, " ")
+	}
+	if line == "" || strings.HasPrefix(line, "#") {
+		return rule, false
+	}
+	if strings.HasPrefix(line, "!") {
+		rule.negate = true
+		line = line[1:]
+	} else if strings.HasPrefix(line, "\\!") || strings.HasPrefix(line, "\\#") {
+		line = line[1:]
+	}
+	if strings.HasSuffix(line, "/") {
+		rule.dirOnly = true
+		line = strings.TrimSuffix(line, "/")
+	}
+	// a pattern without a slash matches at any depth
+	anchored := strings.Contains(line, "/")
+	line = strings.TrimPrefix(line, "/")
+	if line == "" {
+		return rule, false
+	}
+	var expr strings.Builder
+	expr.WriteString("^")
+	if !anchored {
+		expr.WriteString("(?:.*/)?")
+	}
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		switch {
+		case strings.HasPrefix(line[i:], "**/") && (i == 0 || line[i-1] == '/'):
+			expr.WriteString("(?:.*/)?")
+			i += 2
+		case strings.HasPrefix(line[i:], "**"):
+			expr.WriteString(".*")
+			i++
+		case c == '*':
+			expr.WriteString("[^/]*")
+		case c == '?':
+			expr.WriteString("[^/]")
+		case c == '[':
+			end := strings.IndexByte(line[i+1:], ']')
+			if end < 0 {
+				expr.WriteString(`\[`)
+				continue
+			}
+			class := line[i+1 : i+1+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			expr.WriteString("[" + strings.Replace(class, `\`, `\\`, -1) + "]")
+			i += end + 1
+		case c == '\\' && i+1 < len(line):
+			i++
+			expr.WriteString(regexp.QuoteMeta(line[i : i+1]))
+		default:
+			expr.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	expr.WriteString("$")
+	re, err := regexp.Compile(expr.String())
+	if err != nil {
+		return rule, false
+	}
+	rule.pattern = re
+	return rule, true
+}
+
+func (r tIgnoreRule) matches(path string, isDir bool) bool {
+	if r.dirOnly && !isDir {
+		return false
+	}
+	rel, err := filepath.Rel(r.base, path)
+	if err != nil {
+		return false
+	}
+	rel = filepath.ToSlash(rel)
+	if rel == "." || rel == ".." || strings.HasPrefix(rel, "../") {
+		return false
+	}
+	return r.pattern.MatchString(rel)
+}
+
+// loadIgnoreFile adds the rules of the ignore file of dir, if it has one
+func loadIgnoreFile(dir string) {
+	f, err := os.Open(filepath.Join(dir, ignoreFile))
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	lines := bufio.NewScanner(f)
+	for lines.Scan() {
+		if rule, ok := parseIgnoreRule(dir, lines.Text()); ok {
+			ignoreRules = append(ignoreRules, rule)
+		}
+	}
+}
+
+// isExcluded tells whether the walk leaves path out, called with walkLock
+// held
+func isExcluded(path string, isDir bool) bool {
+	slashed := filepath.ToSlash(path)
+	for _, re := range excludeCompiled {
+		if re.MatchString(slashed) {
+			return true
+		}
+	}
+	excluded := false
+	for _, rule := range ignoreRules {
+		if rule.matches(path, isDir) {
+			excluded = !rule.negate
+		}
+	}
+	return excluded
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/rivo/tview"
+)
+
+// Ctrl+e exports in the -output format, the duplicate paths one per line by
+// default, Ctrl+j as JSON. A form asks for the directory and the file name,
+// the TUI goes on after the export.
+
+// exportName is the default file name of an export
+func exportName(format string) string {
+	if format == outputText {
+		return "duplicates.txt"
+	}
+	return "duplicates." + format
+}
+
+func writeDuplicateList(out io.Writer) error {
+	for _, path := range listDuplicates() {
+		if _, err := fmt.Fprintln(out, path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func writeExport(path, format string) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	switch format {
+	case outputJSON:
+		err = writeJSONReport(file)
+	case outputCSV:
+		err = writeCSVReport(file)
+	default:
+		err = writeDuplicateList(file)
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+func showExportForm(app *tview.Application, format string) {
+	dir, name := targetDir, exportName(format)
+	closeForm := func() {
+		pages.RemovePage("export")
+		app.SetFocus(pages)
+	}
+	form := tview.NewForm()
+	form.AddInputField("Directory", dir, 50, nil, func(text string) { dir = text })
+	form.AddInputField("File name", name, 50, nil, func(text string) { name = text })
+	form.AddButton("Export", func() {
+		closeForm()
+		path := filepath.Join(dir, name)
+		err := os.MkdirAll(dir, os.ModePerm)
+		if err == nil {
+			err = writeExport(path, format)
+		}
+		if err != nil {
+			setStatus("Export failed: " + err.Error())
+			return
+		}
+		setStatus("Exported the duplicates to: " + path)
+	})
+	form.AddButton("Cancel", closeForm)
+	form.SetCancelFunc(closeForm)
+	form.SetBorder(true).SetTitle("Export").SetTitleAlign(tview.AlignLeft)
+	// centered, the size of the fields and the buttons
+	row := tview.NewFlex().AddItem(nil, 0, 1, false).AddItem(form, 66, 0, true).AddItem(nil, 0, 1, false)
+	layout := tview.NewFlex().SetDirection(tview.FlexRow).AddItem(nil, 0, 1, false).AddItem(row, 9, 0, true).AddItem(nil, 0, 1, false)
+	pages.AddPage("export", layout, true, true)
+	app.SetFocus(form)
+}
@@ -0,0 +1,28 @@
+package main
+
+import (
+	"fmt"
+	"sync/atomic"
+)
+
+// A file that can't be hashed, unreadable or gone since the walk, is left
+// out of the results instead of stopping the scan.
+
+// recoverHash turns a panic while hashing one file into its error, the
+// worker goes on with the next file
+func recoverHash(err *error) {
+	if r := recover(); r != nil {
+		*err = fmt.Errorf("%v", r)
+	}
+}
+
+// hashFailed records a file that won't reach findDuplicates
+func hashFailed(data tFileData, err error) {
+	if hashThroughHelper(data, err) {
+		return
+	}
+	publishError(data.path, err)
+	addSkipped()
+	atomic.AddInt64(&pending, -1)
+	addPending(data.path, false)
+}
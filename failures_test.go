@@ -0,0 +1,118 @@
+package main
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// newPipeline resets the state a hashing worker reports to
+func newPipeline(t *testing.T) {
+	t.Helper()
+	if err := setHash("crc32"); err != nil {
+		t.Fatal(err)
+	}
+	errorLogPath = ""
+	collectedErrors = nil
+	stats = tStats{}
+	pending = 0
+	checksumChannel = make(chan tFileData, 10)
+}
+
+func writeFile(t *testing.T, dir, name, content string) tFileData {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pending++
+	return tFileData{path: path, size: info.Size(), modified: info.ModTime().UnixNano()}
+}
+
+// hashAll runs one worker over the files and returns those it hashed
+func hashAll(t *testing.T, files ...tFileData) []tFileData {
+	t.Helper()
+	options, err := newOptions(withRetries(0, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	queue := make(chan tFileData, len(files))
+	for _, data := range files {
+		queue <- data
+	}
+	close(queue)
+	hashers.Add(1)
+	calculateChecksum(queue, &tWorker{}, options)
+	close(checksumChannel)
+	var hashed []tFileData
+	for data := range checksumChannel {
+		hashed = append(hashed, data)
+	}
+	return hashed
+}
+
+func checkFailed(t *testing.T, path string, kind error) {
+	t.Helper()
+	if len(collectedErrors) != 1 {
+		t.Fatalf("got %d errors, want 1", len(collectedErrors))
+	}
+	if e := collectedErrors[0]; e.path != path || !errors.Is(e.err, kind) {
+		t.Errorf("got %s: %v, want %s: %v", e.path, e.err, path, kind)
+	}
+	if stats.skipped != 1 {
+		t.Errorf("got %d skipped files, want 1", stats.skipped)
+	}
+	// the good file is still pending, findDuplicates counts it down
+	if pending != 1 {
+		t.Errorf("got %d pending files, want 1", pending)
+	}
+}
+
+func TestHashVanishedFile(t *testing.T) {
+	newPipeline(t)
+	dir := t.TempDir()
+	gone := writeFile(t, dir, "gone", "deleted between the walk and the hash")
+	kept := writeFile(t, dir, "kept", "still there")
+	if err := os.Remove(gone.path); err != nil {
+		t.Fatal(err)
+	}
+	hashed := hashAll(t, gone, kept)
+	if len(hashed) != 1 || hashed[0].path != kept.path || hashed[0].hash == nil {
+		t.Fatalf("got %v, want the hash of %s only", hashed, kept.path)
+	}
+	checkFailed(t, gone.path, os.ErrNotExist)
+}
+
+func TestHashUnreadableFile(t *testing.T) {
+	if os.Geteuid() == 0 {
+		t.Skip("root reads every file")
+	}
+	newPipeline(t)
+	dir := t.TempDir()
+	denied := writeFile(t, dir, "denied", "nobody may read this")
+	kept := writeFile(t, dir, "kept", "still readable")
+	if err := os.Chmod(denied.path, 0); err != nil {
+		t.Fatal(err)
+	}
+	hashed := hashAll(t, denied, kept)
+	if len(hashed) != 1 || hashed[0].path != kept.path || hashed[0].hash == nil {
+		t.Fatalf("got %v, want the hash of %s only", hashed, kept.path)
+	}
+	checkFailed(t, denied.path, os.ErrPermission)
+}
+
+func TestRecoverHash(t *testing.T) {
+	hash := func() (err error) {
+		defer recoverHash(&err)
+		panic("corrupt read")
+	}
+	if err := hash(); err == nil || err.Error() != "corrupt read" {
+		t.Errorf("got %v, want the panic as an error", err)
+	}
+}
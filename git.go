@@ -0,0 +1,49 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+var (
+	scanGitDirs    bool
+	skipGitTracked bool
+	// files tracked by git, they can be restored from the repository anyway
+	gitTracked = make(map[string]bool)
+)
+
+// visitGitDir skips .git directories, and loads the tracked files of a work
+// tree when -skip-git-tracked is set
+func visitGitDir(path string) error {
+	if filepath.Base(path) == ".git" {
+		if scanGitDirs {
+			return nil
+		}
+		return filepath.SkipDir
+	}
+	if skipGitTracked {
+		if _, err := os.Lstat(filepath.Join(path, ".git")); err == nil {
+			loadGitTracked(path)
+		}
+	}
+	return nil
+}
+
+func loadGitTracked(dir string) {
+	out, err := exec.Command("git", "-C", dir, "ls-files", "-z").Output()
+	if err != nil {
+		// git is not installed or dir is not a work tree
+		return
+	}
+	for _, name := range strings.Split(string(out), "\x00") {
+		if name != "" {
+			gitTracked[filepath.Join(dir, filepath.FromSlash(name))] = true
+		}
+	}
+}
+
+func isGitTracked(path string) bool {
+	return gitTracked[path]
+}
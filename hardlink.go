@@ -0,0 +1,110 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"sync/atomic"
+
+	"code.cloudfoundry.org/bytefmt"
+	"github.com/rivo/tview"
+)
+
+// A duplicate replaced by a hardlink to its original frees its space without
+// removing a path: every copy stays where it was and reads the same. Links
+// only work within a filesystem, copies on another device are left alone.
+
+var errCrossDevice = errors.New("not on the same filesystem as the original")
+
+// sameFilesystem compares the devices of both files
+func sameFilesystem(a, b string) bool {
+	return deviceID(a) == deviceID(b)
+}
+
+// hardlinkFile replaces path with a link to original, the link is made next
+// to path and renamed over it so path is never missing
+func hardlinkFile(original, path string) error {
+	if !sameFilesystem(original, path) {
+		return errCrossDevice
+	}
+	if dryRun {
+		return nil
+	}
+	tmp := path + ".dup-fu-link"
+	if err := os.Link(original, tmp); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return nil
+}
+
+// linkDuplicates replaces every duplicate but the originals with a hardlink
+// and returns the linked files and the number of copies on another
+// filesystem
+func linkDuplicates() ([]string, int, error) {
+	list := withoutMismatches(listDuplicates())
+	originals := duplicateOriginals()
+	linked := make([]string, 0, len(list))
+	crossDevice := 0
+	for _, path := range list {
+		original := originals[path]
+		a, errA := os.Stat(original)
+		b, errB := os.Stat(path)
+		if errA == nil && errB == nil && os.SameFile(a, b) {
+			// already a link to the original
+			continue
+		}
+		err := hardlinkFile(original, path)
+		if err == errCrossDevice {
+			crossDevice++
+			continue
+		}
+		if err != nil {
+			actionFailed(path, err)
+			continue
+		}
+		linked = append(linked, path)
+	}
+	return linked, crossDevice, nil
+}
+
+func hardlinkDuplicates() tOutcome {
+	before := atomic.LoadInt64(&actionFailures)
+	linked, crossDevice, err := linkDuplicates()
+	o := newOutcome(opHardlink, linked, err)
+	if !dryRun {
+		o.logf("Replaced %d duplicate file(s) with hardlinks", len(linked))
+	}
+	if crossDevice > 0 {
+		o.logf("Kept %d duplicate file(s) on another filesystem than their original", crossDevice)
+	}
+	logMismatches(&o)
+	logFailures(&o, before)
+	tagOriginals(keptOriginals(linked))
+	return o
+}
+
+func linksQuestion(kind string, list []string) string {
+	return formatter.Sprintf("Replace %d duplicate file(s) with %ss, %s?", len(list), kind, bytefmt.ByteSize(duplicatesSize(list)))
+}
+
+// confirmLinks is confirmRemoval without the par2 warning, a linked file
+// keeps its content. kind is hardlink, symlink or clone.
+func confirmLinks(app *tview.Application, kind string, action func()) {
+	if !scanFinished() {
+		setStatus("Wait for the scan to finish")
+		return
+	}
+	list := listDuplicates()
+	if len(list) == 0 {
+		setStatus("No duplicates to " + kind)
+		return
+	}
+	showModal(app, "confirm", linksQuestion(kind, list), []string{"Yes", "No"}, func(label string) {
+		if label == "Yes" {
+			action()
+		}
+	})
+}
@@ -0,0 +1,397 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"io/ioutil"
+	"log"
+	"math"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"code.cloudfoundry.org/bytefmt"
+)
+
+// The cache commands maintain the checksum lists read by -hashes-from, the
+// hashes dup-fu reuses between runs:
+//
+//	dup-fu cache stats sums.txt
+//	dup-fu cache prune sums.txt
+//	dup-fu cache verify -sample 1% sums.txt
+//	dup-fu cache migrate -hash blake3 sums.txt > sums.b3
+//	dup-fu cache export sums.txt > portable.txt
+//	dup-fu cache import portable.txt /mnt/photos > sums.txt
+//
+// An entry is current while its file is there and not modified since the
+// list was written. prune drops the others and keeps the modification time of
+// the list, -hashes-from trusts the entries by it. migrate carries the list
+// over to another algorithm instead of dropping it for a full rescan: the
+// current files are read once for both algorithms, an entry is kept when its
+// old digest still matches. export writes the paths relative to the
+// directory they share, import puts them under the root of the copied dataset
+// on another machine. The lists dup-fu writes keep the time of their entries
+// in a header, whatever the modification time of their copy.
+
+const (
+	cacheStats   = "stats"
+	cachePrune   = "prune"
+	cacheVerify  = "verify"
+	cacheMigrate = "migrate"
+	cacheExport  = "export"
+	cacheImport  = "import"
+)
+
+var (
+	cacheAction string
+	// algorithm of the list, guessed from its digest length when unset
+	listHash string
+	// percent of the current entries cache verify reads, "1%" or "1"
+	cacheSample   string
+	samplePercent float64
+)
+
+// cacheOptions returns the options after the cache action
+func cacheOptions() []string {
+	if len(os.Args) < 3 {
+		return nil
+	}
+	cacheAction = os.Args[2]
+	return os.Args[3:]
+}
+
+func validateCache(args []string) error {
+	switch cacheAction {
+	case cacheStats, cachePrune, cacheVerify, cacheMigrate, cacheExport:
+	case cacheImport:
+		if len(args) != 2 {
+			return errors.New("usage: dup-fu cache import [options] exported-list new-root")
+		}
+	default:
+		return errors.New("usage: dup-fu cache stats|prune|verify|migrate|export|import [options] hash-list")
+	}
+	if len(args) != 1 && cacheAction != cacheImport {
+		return fmt.Errorf("usage: dup-fu cache %s [options] hash-list", cacheAction)
+	}
+	if _, exist := hashesByName[listHash]; listHash != "" && !exist {
+		return fmt.Errorf("invalid -list-hash value: %s", listHash)
+	}
+	percent, err := strconv.ParseFloat(strings.TrimSuffix(cacheSample, "%"), 64)
+	if err != nil || percent <= 0 || percent > 100 {
+		return fmt.Errorf("invalid -sample value: %s", cacheSample)
+	}
+	samplePercent = percent
+	return nil
+}
+
+func runCache(out io.Writer, args []string) error {
+	list := args[0]
+	switch cacheAction {
+	case cacheExport:
+		return runCacheExport(out, list)
+	case cacheImport:
+		return runCacheImport(out, list, args[1])
+	case cacheStats:
+		return runCacheStats(out, list)
+	case cachePrune:
+		return runCachePrune(list)
+	case cacheVerify:
+		return runCacheVerify(out, list)
+	}
+	return runCacheMigrate(out, list)
+}
+
+// sameAlgorithm compares the digests of nothing
+func sameAlgorithm(a, b func() hash.Hash) bool {
+	return bytes.Equal(a().Sum(nil), b().Sum(nil))
+}
+
+// hashNameOf names a -hash algorithm, sha1 and sha512 lists aren't one
+func hashNameOf(algorithm func() hash.Hash) (string, bool) {
+	for name, h := range hashesByName {
+		if sameAlgorithm(h, algorithm) {
+			return name, true
+		}
+	}
+	return "", false
+}
+
+func algorithmName(algorithm func() hash.Hash) string {
+	if name, ok := hashNameOf(algorithm); ok {
+		return name
+	}
+	return fmt.Sprintf("a %d bit hash", algorithm().Size()*8)
+}
+
+// writeListHeader dates the entries of a list, root is left out when empty
+func writeListHeader(out io.Writer, algorithm func() hash.Hash, written int64, root string) error {
+	if name, ok := hashNameOf(algorithm); ok {
+		fmt.Fprintf(out, "%s%s\n", listHeaderHash, name)
+	}
+	if root != "" {
+		fmt.Fprintf(out, "%s%s\n", listHeaderRoot, root)
+	}
+	_, err := fmt.Fprintf(out, "%s%s\n", listHeaderWritten, time.Unix(0, written).Format(time.RFC3339Nano))
+	return err
+}
+
+// loadHashList reads the list into knownHashes and returns its algorithm,
+// -hash is left as it was
+func loadHashList(list string) (func() hash.Hash, error) {
+	preferred := newCRC32
+	if listHash != "" {
+		preferred = hashesByName[listHash]
+	}
+	algorithm, err := readHashList(list, preferred)
+	if err != nil {
+		return nil, err
+	}
+	if algorithm == nil {
+		// nothing listed, nothing to compare
+		algorithm = preferred
+	}
+	if listHash != "" && algorithm().Size() != preferred().Size() {
+		return nil, fmt.Errorf("%s: the digests aren't %s ones", list, listHash)
+	}
+	return algorithm, nil
+}
+
+// tListEntries sorts the paths of a list by the state of their file
+type tListEntries struct {
+	current, modified, missing []string
+	// of the current files
+	size uint64
+}
+
+func listEntries() tListEntries {
+	var entries tListEntries
+	for path := range knownHashes {
+		info, err := os.Stat(path)
+		switch {
+		case err != nil || !info.Mode().IsRegular():
+			entries.missing = append(entries.missing, path)
+		case info.ModTime().UnixNano() > knownHashesTime:
+			entries.modified = append(entries.modified, path)
+		default:
+			entries.current = append(entries.current, path)
+			entries.size += uint64(info.Size())
+		}
+	}
+	sort.Strings(entries.current)
+	return entries
+}
+
+// hashWith reads path through every algorithm at once
+func hashWith(path string, algorithms ...func() hash.Hash) ([][]byte, error) {
+	f, err := openFile(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	hashes := make([]hash.Hash, len(algorithms))
+	writers := make([]io.Writer, len(algorithms))
+	for i, algorithm := range algorithms {
+		hashes[i] = algorithm()
+		writers[i] = hashes[i]
+	}
+	if _, err := io.CopyBuffer(io.MultiWriter(writers...), f, make([]byte, 2*1024*1024)); err != nil {
+		return nil, err
+	}
+	sums := make([][]byte, len(hashes))
+	for i, h := range hashes {
+		sums[i] = h.Sum(nil)
+	}
+	return sums, nil
+}
+
+func runCacheStats(out io.Writer, list string) error {
+	algorithm, err := loadHashList(list)
+	if err != nil {
+		return err
+	}
+	entries := listEntries()
+	fmt.Fprintf(out, "%s: %s, written %s\n", list, algorithmName(algorithm), time.Unix(0, knownHashesTime).Format("2006-01-02 15:04"))
+	fmt.Fprint(out, formatter.Sprintf("  %8d entries\n", len(knownHashes)))
+	fmt.Fprint(out, formatter.Sprintf("  %8d current, %s\n", len(entries.current), bytefmt.ByteSize(entries.size)))
+	fmt.Fprint(out, formatter.Sprintf("  %8d of files modified since\n", len(entries.modified)))
+	_, err = fmt.Fprint(out, formatter.Sprintf("  %8d of missing files\n", len(entries.missing)))
+	return err
+}
+
+// runCachePrune rewrites the list with its current entries only
+func runCachePrune(list string) error {
+	algorithm, err := loadHashList(list)
+	if err != nil {
+		return err
+	}
+	entries := listEntries()
+	var data bytes.Buffer
+	writeListHeader(&data, algorithm, knownHashesTime, "")
+	for _, path := range entries.current {
+		fmt.Fprintf(&data, "%x  %s\n", knownHashes[path], path)
+	}
+	written := time.Unix(0, knownHashesTime)
+	tmp := list + ".tmp"
+	if err := ioutil.WriteFile(tmp, data.Bytes(), 0644); err != nil {
+		return err
+	}
+	// the entries are still as old as the list
+	if err := os.Chtimes(tmp, written, written); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	if err := os.Rename(tmp, list); err != nil {
+		return err
+	}
+	log.Print(formatter.Sprintf("Kept %d entries, dropped %d of modified and %d of missing files",
+		len(entries.current), len(entries.modified), len(entries.missing)))
+	return nil
+}
+
+// runCacheVerify reads a random sample of the current entries and prints
+// those not matching their file
+func runCacheVerify(out io.Writer, list string) error {
+	algorithm, err := loadHashList(list)
+	if err != nil {
+		return err
+	}
+	sample := listEntries().current
+	current := len(sample)
+	rand.Seed(time.Now().UnixNano())
+	rand.Shuffle(len(sample), func(i, j int) {
+		sample[i], sample[j] = sample[j], sample[i]
+	})
+	sample = sample[:int(math.Ceil(float64(len(sample))*samplePercent/100))]
+	mismatched := 0
+	for _, path := range sample {
+		sums, err := hashWith(path, algorithm)
+		if err != nil {
+			fmt.Fprintf(out, "unreadable  %s: %v\n", path, err)
+			mismatched++
+			continue
+		}
+		if !bytes.Equal(sums[0], knownHashes[path]) {
+			fmt.Fprintf(out, "mismatch    %s\n", path)
+			mismatched++
+		}
+	}
+	log.Print(formatter.Sprintf("Verified %d of %d current entries", len(sample), current))
+	if mismatched > 0 {
+		return fmt.Errorf("%s: %d sampled entries don't match their file", filepath.Base(list), mismatched)
+	}
+	return nil
+}
+
+// runCacheMigrate prints the list rehashed with -hash, in the GNU format
+func runCacheMigrate(out io.Writer, list string) error {
+	source, err := loadHashList(list)
+	if err != nil {
+		return err
+	}
+	if sameAlgorithm(source, newHash) {
+		return fmt.Errorf("%s: the list already uses -hash %s", list, hashName)
+	}
+	entries := listEntries()
+	// the entries are as old as the list, their files haven't changed since
+	if err := writeListHeader(out, newHash, knownHashesTime, ""); err != nil {
+		return err
+	}
+	migrated, unreadable, mismatched := 0, 0, 0
+	for _, path := range entries.current {
+		sums, err := hashWith(path, source, newHash)
+		if err != nil {
+			unreadable++
+			continue
+		}
+		if !bytes.Equal(sums[0], knownHashes[path]) {
+			mismatched++
+			continue
+		}
+		if _, err := fmt.Fprintf(out, "%x  %s\n", sums[1], path); err != nil {
+			return err
+		}
+		migrated++
+	}
+	log.Print(formatter.Sprintf("Migrated %d hash(es), dropped %d of missing, modified or unreadable files",
+		migrated, len(entries.modified)+len(entries.missing)+unreadable))
+	if mismatched > 0 {
+		log.Print(formatter.Sprintf("Dropped %d hash(es) not matching their file, set -list-hash if the list isn't %s", mismatched, algorithmName(source)))
+	}
+	return nil
+}
+
+// runCacheExport prints the list with the paths relative to the directory
+// they share, named in the header
+func runCacheExport(out io.Writer, list string) error {
+	algorithm, err := loadHashList(list)
+	if err != nil {
+		return err
+	}
+	paths := make([]string, 0, len(knownHashes))
+	for path := range knownHashes {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	root := commonDir(paths)
+	if root == "" && len(paths) > 0 {
+		return fmt.Errorf("%s: the files don't share a directory", list)
+	}
+	if err := writeListHeader(out, algorithm, knownHashesTime, root); err != nil {
+		return err
+	}
+	for _, path := range paths {
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		if _, err := fmt.Fprintf(out, "%x  %s\n", knownHashes[path], filepath.ToSlash(rel)); err != nil {
+			return err
+		}
+	}
+	log.Print(formatter.Sprintf("Exported %d entries relative to %s", len(paths), root))
+	return nil
+}
+
+// runCacheImport prints an exported list with its paths under root
+func runCacheImport(out io.Writer, exported, root string) error {
+	f, err := os.Open(exported)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	root = absPath(root)
+	count := 0
+	lines := bufio.NewScanner(f)
+	for lineNo := 1; lines.Scan(); lineNo++ {
+		line := strings.TrimSpace(lines.Text())
+		if strings.HasPrefix(line, listHeaderRoot) || line == "" {
+			// the old root means nothing here
+			continue
+		}
+		if strings.HasPrefix(line, "#") {
+			fmt.Fprintln(out, line)
+			continue
+		}
+		digest, path, ok := parseHashLine(line)
+		if !ok || filepath.IsAbs(path) || filepath.IsAbs(filepath.FromSlash(path)) {
+			return fmt.Errorf("%s:%d: not a line of cache export", exported, lineNo)
+		}
+		if _, err := fmt.Fprintf(out, "%s  %s\n", digest, filepath.Join(root, filepath.FromSlash(path))); err != nil {
+			return err
+		}
+		count++
+	}
+	if err := lines.Err(); err != nil {
+		return err
+	}
+	log.Print(formatter.Sprintf("Imported %d entries under %s", count, root))
+	return nil
+}
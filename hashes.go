@@ -0,0 +1,234 @@
+package main
+
+import (
+	"bufio"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/cespare/xxhash"
+	"github.com/zeebo/blake3"
+)
+
+var (
+	hashesFrom string
+	hashName   string
+	newHash    = newCRC32
+	// hashes computed by external tools (sha256sum, rclone hashsum), keyed by absolute path
+	knownHashes = make(map[string][]byte)
+	// files modified after the hash list was written are hashed again
+	knownHashesTime int64
+)
+
+// headers of the lists dup-fu writes: a copied list keeps its time, and
+// names the algorithm sha256 and blake3 digests don't tell apart
+const (
+	listHeaderWritten = "# dup-fu written: "
+	listHeaderHash    = "# dup-fu hash: "
+	listHeaderRoot    = "# dup-fu root: "
+)
+
+// -hash algorithms, crc32 is the fastest but collides on large collections
+var hashesByName = map[string]func() hash.Hash{
+	"crc32":    newCRC32,
+	"xxhash64": func() hash.Hash { return xxhash.New() },
+	"md5":      md5.New,
+	"sha256":   sha256.New,
+	"blake3":   func() hash.Hash { return blake3.New() },
+}
+
+// hash algorithms by the length of their hex digest
+var hashesByLength = map[int]func() hash.Hash{
+	8:   newCRC32,
+	32:  md5.New,
+	40:  sha1.New,
+	64:  sha256.New,
+	128: sha512.New,
+}
+
+func newCRC32() hash.Hash {
+	return crc32.New(crc32.IEEETable)
+}
+
+func setHash(name string) error {
+	algorithm, exist := hashesByName[name]
+	if !exist {
+		return fmt.Errorf("invalid -hash value: %s", name)
+	}
+	newHash = algorithm
+	return nil
+}
+
+// parseHashLine supports the GNU ("<hex>  <path>", "<hex> *<path>") and BSD
+// ("SHA256 (<path>) = <hex>") formats
+func parseHashLine(line string) (string, string, bool) {
+	if i := strings.LastIndex(line, ") = "); i > 0 && strings.Contains(line[:i], " (") {
+		j := strings.Index(line, " (")
+		return line[i+4:], line[j+2 : i], true
+	}
+	fields := strings.SplitN(line, " ", 2)
+	if len(fields) != 2 {
+		return "", "", false
+	}
+	return fields[0], strings.TrimPrefix(strings.TrimPrefix(fields[1], " "), "*"), true
+}
+
+// loadHashes reads the -hashes-from list. Its algorithm is used for the
+// whole scan so every file stays comparable: without -hash it's taken over,
+// a -hash of another algorithm is an error rather than a silent switch.
+func loadHashes(file string) error {
+	algorithm, err := readHashList(file, newHash)
+	if err != nil {
+		return err
+	}
+	if algorithm == nil || sameAlgorithm(algorithm, newHash) {
+		return nil
+	}
+	if hashGiven() {
+		return fmt.Errorf("%s holds %s digests, -hash is %s: drop -hash, or carry the list over with cache migrate",
+			file, algorithmName(algorithm), hashName)
+	}
+	log.Printf("Hashing with %s, the algorithm of %s", algorithmName(algorithm), file)
+	newHash = algorithm
+	return nil
+}
+
+// hashGiven tells whether -hash was set, on the command line or in the
+// config file
+func hashGiven() bool {
+	given := false
+	flag.Visit(func(f *flag.Flag) {
+		given = given || f.Name == "hash"
+	})
+	return given
+}
+
+// readHashList reads a checksum list into knownHashes and returns its
+// algorithm, nil for an empty list. Relative paths are resolved against the
+// list's directory. preferred is the algorithm when the digests are as long
+// and the list doesn't name one, b3sum and sha256sum lists look the same.
+func readHashList(file string, preferred func() hash.Hash) (func() hash.Hash, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	knownHashesTime = info.ModTime().UnixNano()
+	dir := absPath(filepath.Dir(file))
+	length := 0
+	var named func() hash.Hash
+	scanner := bufio.NewScanner(f)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := strings.TrimSpace(scanner.Text())
+		if written := strings.TrimPrefix(line, listHeaderWritten); written != line {
+			t, err := time.Parse(time.RFC3339Nano, written)
+			if err != nil {
+				return nil, fmt.Errorf("%s:%d: %v", file, lineNo, err)
+			}
+			knownHashesTime = t.UnixNano()
+		} else if name := strings.TrimPrefix(line, listHeaderHash); name != line {
+			named = hashesByName[name]
+		}
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		digest, path, ok := parseHashLine(line)
+		if !ok {
+			return nil, fmt.Errorf("%s:%d: invalid line", file, lineNo)
+		}
+		sum, err := hex.DecodeString(digest)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %v", file, lineNo, err)
+		}
+		if length == 0 {
+			length = len(digest)
+		} else if len(digest) != length {
+			return nil, fmt.Errorf("%s:%d: mixed hash algorithms", file, lineNo)
+		}
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(dir, path)
+		}
+		knownHashes[remapPath(filepath.Clean(path))] = sum
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	switch {
+	case length == 0:
+		return nil, nil
+	case named != nil && named().Size()*2 == length:
+		return named, nil
+	case preferred().Size()*2 == length:
+		return preferred, nil
+	}
+	algorithm, exist := hashesByLength[length]
+	if !exist {
+		return nil, fmt.Errorf("%s: unknown hash algorithm", file)
+	}
+	return algorithm, nil
+}
+
+// knownHash fills in the hash of a file the interrupted scan, the store or
+// the hash list has, and
+// its content kind; the hash list doesn't know it, the head of the file is
+// sniffed then
+func knownHash(data tFileData) (tFileData, bool) {
+	if sum, content, exist := resumedHash(data); exist {
+		data.hash, data.content = sum, content
+		return data, true
+	}
+	if data.partial {
+		return data, false
+	}
+	if sum, content, exist := storedHash(data); exist {
+		data.hash, data.content = sum, content
+		return data, true
+	}
+	sum, exist := listedHash(data)
+	if !exist {
+		return data, false
+	}
+	data.hash, data.content = sum, sniffFile(data.path)
+	return data, true
+}
+
+// hashKnown tells whether knownHash has the hash of the file, without
+// reading it
+func hashKnown(data tFileData) bool {
+	if _, _, exist := resumedHash(data); exist {
+		return true
+	}
+	if data.partial {
+		return false
+	}
+	if _, _, exist := storedHash(data); exist {
+		return true
+	}
+	_, exist := listedHash(data)
+	return exist
+}
+
+// listedHash returns the hash of the -hashes-from list for a file that
+// didn't change since the list was written
+func listedHash(data tFileData) ([]byte, bool) {
+	if len(knownHashes) == 0 || data.modified > knownHashesTime {
+		return nil, false
+	}
+	sum, exist := knownHashes[absPath(data.path)]
+	return sum, exist
+}
@@ -0,0 +1,225 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// The hashes of a scan are kept in -cache-dir for the next one: a file with
+// the same identity (device and inode, its path on Windows), size and
+// modification time isn't read again, its content kind is kept next to the
+// hash. Every algorithm has its own store, switching -hash keeps the other.
+// Rescanning an unchanged archive only walks it. A finished scan drops the
+// entries of the files under its roots it didn't find anymore.
+//
+// The store isn't a hash list: the cache commands work on -hashes-from lists,
+// this store only ever grows and shrinks with the scans.
+
+const hashStoreVersion = "# dup-fu hash store 2"
+
+type tStoredHash struct {
+	size     int64
+	modified int64
+	// nil for a file settled without a content hash, only its kind is known
+	hash    []byte
+	content int
+	// absolute, for pruning the files gone from the scanned roots
+	path string
+}
+
+var (
+	cacheDir string
+	// by file identity, guarded by hashStoreLock
+	storedHashes  = make(map[string]tStoredHash)
+	hashStoreLock sync.Mutex
+	// a hash was added or replaced since the store was loaded
+	hashStoreChanged bool
+	// identities grouped by this scan
+	storeSeen = make(map[string]bool)
+)
+
+func defaultCacheDir() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "dup-fu")
+}
+
+// hashStorePath is empty when there's no store for the algorithm
+func hashStorePath() string {
+	name, ok := hashNameOf(newHash)
+	if cacheDir == "" || !ok {
+		return ""
+	}
+	return filepath.Join(cacheDir, "hashes-"+name+".txt")
+}
+
+// loadHashStore reads the store of the scan algorithm, the one the hash
+// lists settled on
+func loadHashStore() error {
+	path := hashStorePath()
+	if path == "" {
+		return nil
+	}
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	lines := bufio.NewScanner(f)
+	if !lines.Scan() || lines.Text() != hashStoreVersion {
+		// written by another version, rebuilt by this scan
+		return lines.Err()
+	}
+	for lines.Scan() {
+		// the identity and the path are last, Windows paths have spaces
+		fields := strings.SplitN(lines.Text(), " ", 5)
+		if len(fields) != 5 {
+			continue
+		}
+		ids := strings.SplitN(fields[4], "\t", 2)
+		if len(ids) != 2 {
+			continue
+		}
+		var sum []byte
+		if fields[0] != "-" {
+			if sum, err = hex.DecodeString(fields[0]); err != nil {
+				continue
+			}
+		}
+		size, _ := strconv.ParseInt(fields[1], 10, 64)
+		modified, _ := strconv.ParseInt(fields[2], 10, 64)
+		content, _ := strconv.Atoi(fields[3])
+		if content < 0 || content >= contentKinds {
+			content = contentUnknown
+		}
+		storedHashes[ids[0]] = tStoredHash{size, modified, sum, content, ids[1]}
+	}
+	return lines.Err()
+}
+
+// storedFile returns the entry of the file when it hasn't changed since
+func storedFile(data tFileData) (tStoredHash, bool) {
+	if data.id == "" {
+		return tStoredHash{}, false
+	}
+	hashStoreLock.Lock()
+	stored, exist := storedHashes[data.id]
+	hashStoreLock.Unlock()
+	if !exist || stored.size != data.size || stored.modified != data.modified {
+		return tStoredHash{}, false
+	}
+	return stored, true
+}
+
+// storedHash returns the hash and the content kind of the file when it
+// hasn't changed since
+func storedHash(data tFileData) ([]byte, int, bool) {
+	stored, exist := storedFile(data)
+	if !exist || stored.hash == nil {
+		return nil, contentUnknown, false
+	}
+	return stored.hash, stored.content, true
+}
+
+// storeHash keeps the content hash and kind of a grouped file, only the kind
+// of a settled one
+func storeHash(data tFileData) {
+	if data.id == "" || data.partial {
+		return
+	}
+	hash := data.hash
+	if data.settled != "" {
+		hash = nil
+	}
+	path := absPath(data.path)
+	hashStoreLock.Lock()
+	defer hashStoreLock.Unlock()
+	storeSeen[data.id] = true
+	content := data.content
+	if stored, exist := storedHashes[data.id]; exist && stored.size == data.size && stored.modified == data.modified {
+		if hash == nil {
+			// a settled file keeps the hash of an earlier scan
+			hash = stored.hash
+		}
+		if content == contentUnknown {
+			content = stored.content
+		}
+		if bytes.Equal(hash, stored.hash) && content == stored.content {
+			return
+		}
+	}
+	storedHashes[data.id] = tStoredHash{data.size, data.modified, hash, content, path}
+	hashStoreChanged = true
+}
+
+// pruneHashStore drops the entries of the files under the roots the
+// finished scan didn't group, they were deleted or are excluded now
+func pruneHashStore(roots []string) {
+	dirs := make([]string, 0, len(roots))
+	for _, root := range roots {
+		dirs = append(dirs, absPath(root))
+	}
+	hashStoreLock.Lock()
+	defer hashStoreLock.Unlock()
+	for id, stored := range storedHashes {
+		if storeSeen[id] {
+			continue
+		}
+		for _, dir := range dirs {
+			if stored.path == dir || isWithin(stored.path, dir) {
+				delete(storedHashes, id)
+				hashStoreChanged = true
+				break
+			}
+		}
+	}
+}
+
+// saveHashStore rewrites the store once the scan is done, the files of the
+// other roots keep their entries
+func saveHashStore() error {
+	path := hashStorePath()
+	hashStoreLock.Lock()
+	defer hashStoreLock.Unlock()
+	if path == "" || !hashStoreChanged {
+		return nil
+	}
+	if err := os.MkdirAll(cacheDir, os.ModePerm); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	out := bufio.NewWriter(f)
+	fmt.Fprintln(out, hashStoreVersion)
+	for id, stored := range storedHashes {
+		hash := "-"
+		if stored.hash != nil {
+			hash = hex.EncodeToString(stored.hash)
+		}
+		fmt.Fprintf(out, "%s %d %d %d %s\t%s\n", hash, stored.size, stored.modified, stored.content, id, stored.path)
+	}
+	if err := out.Flush(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	hashStoreChanged = false
+	return os.Rename(tmp, path)
+}
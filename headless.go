@@ -0,0 +1,60 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"strings"
+)
+
+// -no-tui runs the scan for scripts, cron jobs and sessions without a
+// terminal: the groups are printed like the report command does, or -action
+// is applied to every duplicate without asking when it's set. Without a
+// terminal on stdout it's the default, but acting then takes -action and
+// -no-tui or -yes on the command line: a pipe to less or an action line of
+// the config file never deletes anything.
+
+var noTUI bool
+
+// headlessCommand is the command run instead of the TUI, if any
+func headlessCommand(command string) (string, error) {
+	if (command != "" && command != "scan") || digest || accessible || simpleUI || rpcMode {
+		return command, nil
+	}
+	if !noTUI && isTerminal(os.Stdout) {
+		return command, nil
+	}
+	if !givenOnCommandLine("action") {
+		return "report", nil
+	}
+	if !givenOnCommandLine("no-tui") && !givenOnCommandLine("yes") {
+		return "", errors.New("-action without a terminal acts without asking, add -no-tui or -yes to apply it")
+	}
+	assumeYes = true
+	return "clean", nil
+}
+
+// givenOnCommandLine tells whether the option is on the command line, the
+// flag package doesn't tell it from one of the config file
+func givenOnCommandLine(name string) bool {
+	for _, arg := range os.Args[1:] {
+		if arg == "--" {
+			break
+		}
+		if !strings.HasPrefix(arg, "-") {
+			continue
+		}
+		arg = strings.TrimLeft(arg, "-")
+		if i := strings.Index(arg, "="); i >= 0 {
+			arg = arg[:i]
+		}
+		if arg == name {
+			return true
+		}
+	}
+	return false
+}
+
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
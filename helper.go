@@ -0,0 +1,308 @@
+//go:build !windows
+// +build !windows
+
+package main
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// A scan run by a normal user can't read the root-only parts of the system.
+// With -helper sudo it starts `sudo dup-fu hash-helper` before the TUI and
+// asks it only for what it can't do itself: the listing of a directory and
+// the hash of a file. No content ever crosses the pipe, and the helper does
+// nothing else. sudo or doas ask the user for the right to run it; a setuid
+// copy of dup-fu would list and hash any file for any user of the machine,
+// and refuses to start.
+
+const helperVersion = 1
+
+type tHelperRequest struct {
+	Op   string `json:"op"`
+	Path string `json:"path,omitempty"`
+}
+
+type tHelperReply struct {
+	Version  int    `json:"version,omitempty"`
+	Path     string `json:"path,omitempty"`
+	Dir      bool   `json:"dir,omitempty"`
+	Size     int64  `json:"size,omitempty"`
+	Modified int64  `json:"modified,omitempty"`
+	ID       string `json:"id,omitempty"`
+	Hash     string `json:"hash,omitempty"`
+	Error    string `json:"error,omitempty"`
+	// the last reply of a walk
+	End bool `json:"end,omitempty"`
+}
+
+type tHelper struct {
+	lock    sync.Mutex
+	cmd     *exec.Cmd
+	in      io.WriteCloser
+	encoder *json.Encoder
+	decoder *json.Decoder
+}
+
+var (
+	// sudo or doas
+	helperCommand string
+	helper        *tHelper
+)
+
+// startHelper runs the helper before the TUI, sudo asks for the password on
+// the terminal
+func startHelper() error {
+	if helperCommand == "" {
+		return nil
+	}
+	name, ok := hashNameOf(newHash)
+	if !ok {
+		return errors.New("the helper hashes with a -hash algorithm only")
+	}
+	exe, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	cmd := exec.Command(helperCommand, exe, "hash-helper", "-hash", name)
+	cmd.Stderr = os.Stderr
+	in, err := cmd.StdinPipe()
+	if err != nil {
+		return err
+	}
+	out, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	h := &tHelper{cmd: cmd, in: in, encoder: json.NewEncoder(in), decoder: json.NewDecoder(out)}
+	hello, err := h.call(tHelperRequest{Op: "hello"})
+	if err != nil {
+		h.stop()
+		return fmt.Errorf("the helper didn't start: %v", err)
+	}
+	if hello.Version != helperVersion {
+		h.stop()
+		return fmt.Errorf("the helper speaks version %d, not %d", hello.Version, helperVersion)
+	}
+	helper = h
+	return nil
+}
+
+func stopHelper() {
+	if helper != nil {
+		helper.stop()
+		helper = nil
+	}
+}
+
+func (h *tHelper) stop() {
+	h.in.Close()
+	h.cmd.Wait()
+}
+
+func (h *tHelper) call(request tHelperRequest) (tHelperReply, error) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	var reply tHelperReply
+	if err := h.encoder.Encode(request); err != nil {
+		return reply, err
+	}
+	if err := h.decoder.Decode(&reply); err != nil {
+		return reply, err
+	}
+	if reply.Error != "" {
+		return reply, errors.New(reply.Error)
+	}
+	return reply, nil
+}
+
+// walk returns the directories and regular files under dir, walk order
+func (h *tHelper) walk(dir string) ([]tHelperReply, error) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	if err := h.encoder.Encode(tHelperRequest{Op: "walk", Path: dir}); err != nil {
+		return nil, err
+	}
+	var entries []tHelperReply
+	for {
+		var reply tHelperReply
+		if err := h.decoder.Decode(&reply); err != nil {
+			return nil, err
+		}
+		if reply.End {
+			if reply.Error != "" {
+				return nil, errors.New(reply.Error)
+			}
+			return entries, nil
+		}
+		entries = append(entries, reply)
+	}
+}
+
+// tHelperInfo is the os.FileInfo of an entry listed by the helper
+type tHelperInfo struct {
+	entry tHelperReply
+}
+
+func (i tHelperInfo) Name() string       { return filepath.Base(i.entry.Path) }
+func (i tHelperInfo) Size() int64        { return i.entry.Size }
+func (i tHelperInfo) ModTime() time.Time { return time.Unix(0, i.entry.Modified) }
+func (i tHelperInfo) IsDir() bool        { return i.entry.Dir }
+func (i tHelperInfo) Sys() interface{}   { return nil }
+
+func (i tHelperInfo) Mode() os.FileMode {
+	if i.entry.Dir {
+		return os.ModeDir | 0500
+	}
+	return 0400
+}
+
+// visitThroughHelper visits the directory the user can't list through the
+// helper, the files it holds are hashed by the helper too
+func visitThroughHelper(path string, info os.FileInfo, err error, options tOptions) ([]tFileData, bool) {
+	if helper == nil || info == nil || !info.IsDir() || !errors.Is(err, os.ErrPermission) {
+		return nil, false
+	}
+	abs := absPath(path)
+	entries, err := helper.walk(abs)
+	if err != nil {
+		return nil, false
+	}
+	var ready []tFileData
+	var skipped []string
+	for _, entry := range entries {
+		rel, err := filepath.Rel(abs, entry.Path)
+		if err != nil {
+			continue
+		}
+		child := filepath.Join(path, rel)
+		if withinAny(child, skipped) {
+			continue
+		}
+		files, err := visit(child, tHelperInfo{entry}, nil, options)
+		if err == filepath.SkipDir && entry.Dir {
+			skipped = append(skipped, child)
+		}
+		ready = append(ready, files...)
+	}
+	return ready, true
+}
+
+func withinAny(path string, dirs []string) bool {
+	for _, dir := range dirs {
+		if isWithin(path, dir) {
+			return true
+		}
+	}
+	return false
+}
+
+// hashThroughHelper hashes the file the user can't read with the helper,
+// the file goes on to findDuplicates when it could
+func hashThroughHelper(data tFileData, err error) bool {
+	if helper == nil || !errors.Is(err, os.ErrPermission) {
+		return false
+	}
+	reply, err := helper.call(tHelperRequest{Op: "hash", Path: absPath(data.path)})
+	if err != nil {
+		return false
+	}
+	sum, err := hex.DecodeString(reply.Hash)
+	if err != nil {
+		return false
+	}
+	// the helper always hashes the whole file
+	data.hash, data.partial = sum, false
+	checksumChannel <- data
+	return true
+}
+
+// runHashHelper answers the requests of a scan until it closes the pipe
+func runHashHelper(in io.Reader, out io.Writer) error {
+	decoder, encoder := json.NewDecoder(in), json.NewEncoder(out)
+	for {
+		var request tHelperRequest
+		if err := decoder.Decode(&request); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		if request.Op != "hello" && !filepath.IsAbs(request.Path) {
+			encoder.Encode(tHelperReply{Error: "not an absolute path", End: request.Op == "walk"})
+			continue
+		}
+		var err error
+		switch request.Op {
+		case "hello":
+			err = encoder.Encode(tHelperReply{Version: helperVersion})
+		case "walk":
+			err = serveWalk(encoder, request.Path)
+		case "hash":
+			reply := tHelperReply{}
+			if sum, hashErr := serveHash(request.Path); hashErr != nil {
+				reply.Error = hashErr.Error()
+			} else {
+				reply.Hash = hex.EncodeToString(sum)
+			}
+			err = encoder.Encode(reply)
+		default:
+			err = encoder.Encode(tHelperReply{Error: "unknown request: " + request.Op})
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// serveWalk lists the directories and regular files under dir, symlinks
+// aren't followed
+func serveWalk(encoder *json.Encoder, dir string) error {
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || path == dir {
+			return nil
+		}
+		if !info.IsDir() && !info.Mode().IsRegular() {
+			return nil
+		}
+		return encoder.Encode(tHelperReply{Path: path, Dir: info.IsDir(), Size: info.Size(),
+			Modified: info.ModTime().UnixNano(), ID: fileID(path, info)})
+	})
+	reply := tHelperReply{End: true}
+	if err != nil {
+		reply.Error = err.Error()
+	}
+	return encoder.Encode(reply)
+}
+
+// serveHash hashes a regular file, never through a symlink
+func serveHash(path string) ([]byte, error) {
+	f, err := os.OpenFile(path, os.O_RDONLY|syscall.O_NOFOLLOW, 0)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	if !info.Mode().IsRegular() {
+		return nil, errors.New("not a regular file")
+	}
+	h := newHash()
+	if _, err := io.Copy(h, f); err != nil {
+		return nil, err
+	}
+	return h.Sum(nil), nil
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"os"
+)
+
+var (
+	helperCommand string
+
+	errHelperUnsupported = errors.New("-helper is for Unix, an administrator reads every file through the backup privilege")
+)
+
+func startHelper() error {
+	if helperCommand == "" {
+		return nil
+	}
+	return errHelperUnsupported
+}
+
+func stopHelper() {}
+
+func visitThroughHelper(path string, info os.FileInfo, err error, options tOptions) ([]tFileData, bool) {
+	return nil, false
+}
+
+func hashThroughHelper(data tFileData, err error) bool {
+	return false
+}
+
+func runHashHelper(in io.Reader, out io.Writer) error {
+	return errHelperUnsupported
+}
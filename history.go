@@ -0,0 +1,200 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"code.cloudfoundry.org/bytefmt"
+	"github.com/gdamore/tcell"
+	"github.com/rivo/tview"
+)
+
+// Every finished scan appends its summary to a history kept in the user
+// config directory, whatever the target directory. `dup-fu history` and
+// Ctrl+d graph the duplicate bytes of each root over time, to see a cleanup
+// job actually keeps them down.
+
+const (
+	historyFile  = "history.jsonl"
+	historyWidth = 40
+)
+
+var (
+	historyDays int
+	noHistory   bool
+)
+
+type tHistoryEntry struct {
+	Time          int64  `json:"time"`
+	Root          string `json:"root"`
+	Scanned       uint32 `json:"scanned"`
+	Size          uint64 `json:"size"`
+	Duplicates    uint32 `json:"duplicates"`
+	DuplicateSize uint64 `json:"duplicateSize"`
+}
+
+func historyPath() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "dup-fu", historyFile), nil
+}
+
+// historyRoot names the scanned roots, the key of their history
+func historyRoot() string {
+	roots := scanRoots()
+	for i, root := range roots {
+		roots[i] = absPath(root)
+	}
+	return strings.Join(roots, ", ")
+}
+
+func readHistory(path string) ([]tHistoryEntry, error) {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var entries []tHistoryEntry
+	lines := bufio.NewScanner(f)
+	for lines.Scan() {
+		var entry tHistoryEntry
+		if err := json.Unmarshal(lines.Bytes(), &entry); err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+	return entries, lines.Err()
+}
+
+// writeHistory rewrites the history without the entries older than
+// -history-days
+func writeHistory(path string, entries []tHistoryEntry) error {
+	if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+		return err
+	}
+	var data bytes.Buffer
+	oldest := time.Now().AddDate(0, 0, -historyDays).Unix()
+	encoder := json.NewEncoder(&data)
+	for _, entry := range entries {
+		if entry.Time < oldest {
+			continue
+		}
+		if err := encoder.Encode(entry); err != nil {
+			return err
+		}
+	}
+	tmp := path + ".tmp"
+	if err := ioutil.WriteFile(tmp, data.Bytes(), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// recordHistory appends the summary of the finished scan
+func recordHistory() {
+	if noHistory {
+		return
+	}
+	path, err := historyPath()
+	if err == nil {
+		var entries []tHistoryEntry
+		if entries, err = readHistory(path); err == nil {
+			duplicatesLock.Lock()
+			entry := tHistoryEntry{time.Now().Unix(), historyRoot(), stats.count, stats.size, stats.duplicates, stats.duplicateSize}
+			duplicatesLock.Unlock()
+			err = writeHistory(path, append(entries, entry))
+		}
+	}
+	if err != nil {
+		addNotice("couldn't record the scan history: %v", err)
+	}
+}
+
+// formatHistory graphs the duplicate bytes of every root, or only of root
+// when it's set, oldest scan first
+func formatHistory(root string) (string, error) {
+	path, err := historyPath()
+	if err != nil {
+		return "", err
+	}
+	entries, err := readHistory(path)
+	if err != nil {
+		return "", err
+	}
+	byRoot := make(map[string][]tHistoryEntry)
+	var roots []string
+	for _, entry := range entries {
+		if root != "" && entry.Root != root {
+			continue
+		}
+		if byRoot[entry.Root] == nil {
+			roots = append(roots, entry.Root)
+		}
+		byRoot[entry.Root] = append(byRoot[entry.Root], entry)
+	}
+	if len(roots) == 0 {
+		return "No scan recorded yet.\n", nil
+	}
+	sort.Strings(roots)
+	var report bytes.Buffer
+	for _, r := range roots {
+		list := byRoot[r]
+		largest := uint64(1)
+		for _, entry := range list {
+			if entry.DuplicateSize > largest {
+				largest = entry.DuplicateSize
+			}
+		}
+		report.WriteString(r + "\n")
+		for _, entry := range list {
+			bar := int(entry.DuplicateSize * historyWidth / largest)
+			report.WriteString(formatter.Sprintf("  %s  %s%s %8s  %d file(s)\n",
+				time.Unix(entry.Time, 0).Format("2006-01-02 15:04"), strings.Repeat("█", bar), strings.Repeat(" ", historyWidth-bar),
+				bytefmt.ByteSize(entry.DuplicateSize), entry.Duplicates))
+		}
+		report.WriteString("\n")
+	}
+	return report.String(), nil
+}
+
+// runHistory prints the history of the given root, of every root without one
+func runHistory(out io.Writer, args []string) error {
+	root := ""
+	if len(args) > 0 {
+		root = absPath(args[0])
+	}
+	report, err := formatHistory(root)
+	if err != nil {
+		return err
+	}
+	_, err = io.WriteString(out, report)
+	return err
+}
+
+// showHistory graphs the history of every root
+func showHistory(app *tview.Application) {
+	report, err := formatHistory("")
+	if err != nil {
+		report = err.Error()
+	}
+	view := newTextView("Duplicate bytes over time (Esc to close)", report).SetScrollable(true)
+	view.SetDoneFunc(func(tcell.Key) {
+		pages.RemovePage("history")
+		app.SetFocus(pages)
+	})
+	pages.AddPage("history", view, true, true)
+	app.SetFocus(view)
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"time"
+
+	"code.cloudfoundry.org/bytefmt"
+)
+
+const insightTimeFormat = "2006-01-02 15:04"
+
+// commonDir returns the deepest directory containing every path
+func commonDir(paths []string) string {
+	if len(paths) == 0 {
+		return ""
+	}
+	common := filepath.Dir(paths[0])
+	for _, path := range paths[1:] {
+		for common != filepath.Dir(common) && path != common && !isWithin(path, common) {
+			common = filepath.Dir(common)
+		}
+		if path != common && !isWithin(path, common) {
+			// different volumes or relative and absolute paths
+			return ""
+		}
+	}
+	return common
+}
+
+// groupInsights describes how a group spreads, which often tells how the
+// duplication happened
+func groupInsights(list []tFileData) string {
+	dirs := make(map[string]bool)
+	paths := make([]string, 0, len(list))
+	oldest, newest := list[0].modified, list[0].modified
+	for _, d := range list {
+		dirs[filepath.Dir(d.path)] = true
+		paths = append(paths, d.path)
+		if d.modified < oldest {
+			oldest = d.modified
+		}
+		if d.modified > newest {
+			newest = d.modified
+		}
+	}
+	ancestor := commonDir(paths)
+	if ancestor == "" {
+		ancestor = "-"
+	}
+	lines := []string{
+		formatter.Sprintf("Copies: %d in %d director(ies), %s reclaimable", len(list), len(dirs), bytefmt.ByteSize(uint64(list[0].size)*uint64(len(list)-1))),
+		"Modified: " + time.Unix(0, oldest).Format(insightTimeFormat) + " - " + time.Unix(0, newest).Format(insightTimeFormat),
+		"Common ancestor: " + ancestor,
+	}
+	return strings.Join(lines, "\n")
+}
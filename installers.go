@@ -0,0 +1,77 @@
+package main
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+
+	"code.cloudfoundry.org/bytefmt"
+	"github.com/gdamore/tcell"
+	"github.com/rivo/tview"
+)
+
+// Disk images and installers are downloaded again for every machine and
+// every reinstall, a copy of one is the easiest win: nobody edits them and
+// the original is one download away anyway.
+
+const installerMinSize = 50 * 1024 * 1024
+
+var installerExtensions = map[string]bool{
+	".iso":      true,
+	".img":      true,
+	".dmg":      true,
+	".pkg":      true,
+	".exe":      true,
+	".msi":      true,
+	".deb":      true,
+	".rpm":      true,
+	".appimage": true,
+}
+
+func isInstaller(d tFileData) bool {
+	return d.size >= installerMinSize && installerExtensions[strings.ToLower(filepath.Ext(d.path))]
+}
+
+// redundantInstallers returns the groups of large installers, in the
+// duplicateGroups order
+func redundantInstallers() []tGroup {
+	var result []tGroup
+	for _, group := range duplicateGroups() {
+		for _, d := range group.files {
+			if isInstaller(d) {
+				result = append(result, group)
+				break
+			}
+		}
+	}
+	return result
+}
+
+func formatInstallers() string {
+	var report bytes.Buffer
+	list := redundantInstallers()
+	if len(list) == 0 {
+		return "No redundant installers found yet."
+	}
+	total := uint64(0)
+	for _, group := range list {
+		total += group.reclaimable()
+		report.WriteString(formatter.Sprintf("%8s  %s (%d copies)\n", bytefmt.ByteSize(group.reclaimable()), group.files[0].path, len(group.files)))
+		for _, dup := range group.files[1:] {
+			report.WriteString("          " + dup.path + "\n")
+		}
+	}
+	report.WriteString(formatter.Sprintf("%8s  reclaimable in %d installer(s)\n", bytefmt.ByteSize(total), len(list)))
+	return report.String()
+}
+
+// showInstallers lists the large installers and disk images found twice
+func showInstallers(app *tview.Application) {
+	view := newTextView("Large redundant installers (Esc to close)", formatInstallers()).SetScrollable(true)
+	view.SetDoneFunc(func(tcell.Key) {
+		pages.RemovePage("installers")
+		app.SetFocus(pages)
+	})
+	pages.AddPage("installers", view, true, true)
+	app.SetFocus(view)
+}
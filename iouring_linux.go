@@ -0,0 +1,235 @@
+//go:build linux && iouring
+// +build linux,iouring
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"sync/atomic"
+	"syscall"
+	"unsafe"
+)
+
+// Hashing reads through io_uring: every worker owns a ring and keeps
+// ringDepth reads of ringBlockSize in flight, so the drive always has queued
+// work while the previous block is hashed.
+
+const (
+	ioUringSupported = true
+
+	sysIOUringSetup = 425
+	sysIOUringEnter = 426
+
+	ioringOffSQRing = 0
+	ioringOffCQRing = 0x8000000
+	ioringOffSQEs   = 0x10000000
+
+	ioringOpRead          = 22
+	ioringEnterGetEvents  = 1
+	ringDepth             = 4
+	ringBlockSize         = 1024 * 1024
+	ioUringSQESize        = 64
+	ioUringCQESize        = 16
+	ioUringSQArrayEntrySz = 4
+)
+
+type tSQRingOffsets struct {
+	head, tail, ringMask, ringEntries, flags, dropped, array, resv1 uint32
+	resv2                                                           uint64
+}
+
+type tCQRingOffsets struct {
+	head, tail, ringMask, ringEntries, overflow, cqes, flags, resv1 uint32
+	resv2                                                           uint64
+}
+
+type tIOUringParams struct {
+	sqEntries, cqEntries, flags, sqThreadCPU, sqThreadIdle, features, wqFd uint32
+	resv                                                                   [3]uint32
+	sqOff                                                                  tSQRingOffsets
+	cqOff                                                                  tCQRingOffsets
+}
+
+type tIOUringSQE struct {
+	opcode      uint8
+	flags       uint8
+	ioprio      uint16
+	fd          int32
+	off         uint64
+	addr        uint64
+	len         uint32
+	opFlags     uint32
+	userData    uint64
+	bufIndex    uint16
+	personality uint16
+	spliceFdIn  int32
+	pad         [2]uint64
+}
+
+type tIOUringCQE struct {
+	userData uint64
+	res      int32
+	flags    uint32
+}
+
+type tRing struct {
+	fd     int
+	sqRing []byte
+	cqRing []byte
+	sqeMem []byte
+
+	sqHead, sqTail, sqMask *uint32
+	sqArray                []uint32
+	sqes                   []tIOUringSQE
+	cqHead, cqTail, cqMask *uint32
+	cqes                   []tIOUringCQE
+
+	bufs [ringDepth][]byte
+}
+
+func ringUint32(mem []byte, off uint32) *uint32 {
+	return (*uint32)(unsafe.Pointer(&mem[off]))
+}
+
+func newRing() (*tRing, error) {
+	var params tIOUringParams
+	fd, _, errno := syscall.Syscall(sysIOUringSetup, ringDepth, uintptr(unsafe.Pointer(&params)), 0)
+	if errno != 0 {
+		return nil, fmt.Errorf("io_uring_setup: %v", errno)
+	}
+	r := &tRing{fd: int(fd)}
+	var err error
+	sqSize := int(params.sqOff.array + params.sqEntries*ioUringSQArrayEntrySz)
+	if r.sqRing, err = syscall.Mmap(r.fd, ioringOffSQRing, sqSize, syscall.PROT_READ|syscall.PROT_WRITE, syscall.MAP_SHARED|syscall.MAP_POPULATE); err != nil {
+		r.close()
+		return nil, err
+	}
+	cqSize := int(params.cqOff.cqes + params.cqEntries*ioUringCQESize)
+	if r.cqRing, err = syscall.Mmap(r.fd, ioringOffCQRing, cqSize, syscall.PROT_READ|syscall.PROT_WRITE, syscall.MAP_SHARED|syscall.MAP_POPULATE); err != nil {
+		r.close()
+		return nil, err
+	}
+	sqeSize := int(params.sqEntries * ioUringSQESize)
+	if r.sqeMem, err = syscall.Mmap(r.fd, ioringOffSQEs, sqeSize, syscall.PROT_READ|syscall.PROT_WRITE, syscall.MAP_SHARED|syscall.MAP_POPULATE); err != nil {
+		r.close()
+		return nil, err
+	}
+	r.sqHead = ringUint32(r.sqRing, params.sqOff.head)
+	r.sqTail = ringUint32(r.sqRing, params.sqOff.tail)
+	r.sqMask = ringUint32(r.sqRing, params.sqOff.ringMask)
+	r.sqArray = (*[1 << 16]uint32)(unsafe.Pointer(&r.sqRing[params.sqOff.array]))[:params.sqEntries:params.sqEntries]
+	r.sqes = (*[1 << 16]tIOUringSQE)(unsafe.Pointer(&r.sqeMem[0]))[:params.sqEntries:params.sqEntries]
+	r.cqHead = ringUint32(r.cqRing, params.cqOff.head)
+	r.cqTail = ringUint32(r.cqRing, params.cqOff.tail)
+	r.cqMask = ringUint32(r.cqRing, params.cqOff.ringMask)
+	r.cqes = (*[1 << 16]tIOUringCQE)(unsafe.Pointer(&r.cqRing[params.cqOff.cqes]))[:params.cqEntries:params.cqEntries]
+	for i := range r.bufs {
+		r.bufs[i] = make([]byte, ringBlockSize)
+	}
+	return r, nil
+}
+
+func (r *tRing) close() {
+	for _, mem := range [][]byte{r.sqRing, r.cqRing, r.sqeMem} {
+		if mem != nil {
+			syscall.Munmap(mem)
+		}
+	}
+	syscall.Close(r.fd)
+}
+
+// queueRead prepares a read of block into its buffer, submitted by enter
+func (r *tRing) queueRead(fd int, block int64) {
+	tail := atomic.LoadUint32(r.sqTail)
+	index := tail & *r.sqMask
+	buf := r.bufs[block%ringDepth]
+	r.sqes[index] = tIOUringSQE{
+		opcode:   ioringOpRead,
+		fd:       int32(fd),
+		off:      uint64(block * ringBlockSize),
+		addr:     uint64(uintptr(unsafe.Pointer(&buf[0]))),
+		len:      ringBlockSize,
+		userData: uint64(block),
+	}
+	r.sqArray[index] = index
+	atomic.StoreUint32(r.sqTail, tail+1)
+}
+
+func (r *tRing) enter(submit, wait uint32) error {
+	for {
+		_, _, errno := syscall.Syscall6(sysIOUringEnter, uintptr(r.fd), uintptr(submit), uintptr(wait), ioringEnterGetEvents, 0, 0)
+		if errno == syscall.EINTR {
+			continue
+		}
+		if errno != 0 {
+			return fmt.Errorf("io_uring_enter: %v", errno)
+		}
+		return nil
+	}
+}
+
+// reap collects the completed reads, keyed by block
+func (r *tRing) reap(done map[int64]int32) {
+	head := atomic.LoadUint32(r.cqHead)
+	tail := atomic.LoadUint32(r.cqTail)
+	for ; head != tail; head++ {
+		cqe := r.cqes[head&*r.cqMask]
+		done[int64(cqe.userData)] = cqe.res
+	}
+	atomic.StoreUint32(r.cqHead, head)
+}
+
+// checksum hashes the file in order while the next blocks are being read
+func (r *tRing) checksum(path string) ([]byte, error) {
+	f, err := openFile(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	fd := int(f.Fd())
+	blocks := (info.Size() + ringBlockSize - 1) / ringBlockSize
+	h := newHash()
+	done := make(map[int64]int32)
+	var submitted, hashed int64
+	var readErr error
+	for hashed < submitted || submitted < blocks {
+		queued := uint32(0)
+		// a buffer is reused only once its block is hashed
+		for readErr == nil && submitted < blocks && submitted-hashed < ringDepth {
+			r.queueRead(fd, submitted)
+			submitted++
+			queued++
+		}
+		if err := r.enter(queued, 1); err != nil {
+			return nil, err
+		}
+		r.reap(done)
+		for {
+			res, exist := done[hashed]
+			if !exist {
+				break
+			}
+			delete(done, hashed)
+			if res < 0 && readErr == nil {
+				readErr = &os.PathError{Op: "read", Path: path, Err: syscall.Errno(-res)}
+			}
+			if readErr == nil {
+				h.Write(r.bufs[hashed%ringDepth][:res])
+				if res < ringBlockSize && hashed < blocks-1 {
+					readErr = fmt.Errorf("%s: file shrunk while hashing", path)
+				}
+			}
+			hashed++
+		}
+		if readErr != nil && hashed == submitted {
+			// every read in flight is drained, the buffers are free again
+			return nil, readErr
+		}
+	}
+	return h.Sum(nil), nil
+}
@@ -0,0 +1,21 @@
+//go:build !linux || !iouring
+// +build !linux !iouring
+
+package main
+
+import "errors"
+
+// io_uring needs Linux and the iouring build tag: go build -tags iouring
+const ioUringSupported = false
+
+type tRing struct{}
+
+func newRing() (*tRing, error) {
+	return nil, errors.New("built without io_uring support")
+}
+
+func (r *tRing) checksum(path string) ([]byte, error) {
+	return nil, errors.New("built without io_uring support")
+}
+
+func (r *tRing) close() {}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// The original of a group is the file kept when the others are removed,
+// -keep picks it: the oldest file by default, or by path for workflows where
+// the canonical copy lives in a known place.
+
+const (
+	keepOldest       = "oldest"
+	keepNewest       = "newest"
+	keepShortest     = "shortest-path"
+	keepLongest      = "longest-path"
+	keepAlphabetical = "first-alphabetical"
+	keepPriority     = "path-priority"
+	keepRootOrder    = "root-order"
+)
+
+var (
+	keepStrategy string
+	keepDirList  string
+	// keepDirList parsed by setKeepStrategy, most wanted first
+	keepDirs []string
+)
+
+func setKeepStrategy() error {
+	switch keepStrategy {
+	case keepOldest, keepNewest, keepShortest, keepLongest, keepAlphabetical:
+		return nil
+	case keepRootOrder:
+		for _, root := range scanRoots() {
+			keepDirs = append(keepDirs, absPath(root))
+		}
+		return nil
+	case keepPriority:
+		if keepDirList == "" {
+			return fmt.Errorf("-keep-dirs is required with -keep %s", keepPriority)
+		}
+		for _, dir := range strings.Split(keepDirList, ",") {
+			keepDirs = append(keepDirs, absPath(strings.TrimSpace(dir)))
+		}
+		return nil
+	}
+	return fmt.Errorf("invalid -keep value: %s", keepStrategy)
+}
+
+// dirRank is the position of the -keep-dirs directory (or root) path is in,
+// after them all when it's in none
+func dirRank(path string) int {
+	abs := absPath(path)
+	for i, dir := range keepDirs {
+		if abs == dir || isWithin(abs, dir) {
+			return i
+		}
+	}
+	return len(keepDirs)
+}
+
+// originalBefore orders a group, the original first. The original tagged by
+// an earlier run stays first, a numbered download copy never comes first with
+// -downloads, the oldest file breaks the ties.
+func originalBefore(a, b tFileData) bool {
+	if a.tagged != b.tagged {
+		return a.tagged
+	}
+	if downloadsPreset {
+		if copyA, copyB := isDownloadCopy(a.path), isDownloadCopy(b.path); copyA != copyB {
+			return copyB
+		}
+	}
+	switch keepStrategy {
+	case keepNewest:
+		if a.modified != b.modified {
+			return a.modified > b.modified
+		}
+	case keepShortest:
+		if len(a.path) != len(b.path) {
+			return len(a.path) < len(b.path)
+		}
+	case keepLongest:
+		if len(a.path) != len(b.path) {
+			return len(a.path) > len(b.path)
+		}
+	case keepAlphabetical:
+		if a.path != b.path {
+			return a.path < b.path
+		}
+	case keepPriority, keepRootOrder:
+		if rankA, rankB := dirRank(a.path), dirRank(b.path); rankA != rankB {
+			return rankA < rankB
+		}
+	}
+	return a.modified < b.modified
+}
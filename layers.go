@@ -0,0 +1,162 @@
+package main
+
+import (
+	"archive/tar"
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"code.cloudfoundry.org/bytefmt"
+)
+
+// The layers command finds container image layers stored more than once:
+// the same layer in several `docker save` or OCI archives, or an archive
+// layer also lying around extracted from one. Blobs of OCI layouts and of
+// the containerd content store are regular files, the scan groups them like
+// any other file.
+
+// tLayer is a layer blob, a file or an entry of an image archive
+type tLayer struct {
+	place string
+	size  int64
+	sum   string
+}
+
+// isLayerEntry tells the layer entries of `docker save` (<id>/layer.tar) and
+// OCI (blobs/sha256/<digest>) archives apart from their metadata
+func isLayerEntry(name string) bool {
+	return strings.HasPrefix(name, "blobs/") || strings.HasSuffix(name, "/layer.tar")
+}
+
+// archiveLayers hashes the layers of an image archive, none for other tar files
+func archiveLayers(path string) ([]tLayer, error) {
+	f, err := openFile(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var layers []tLayer
+	r := tar.NewReader(f)
+	for {
+		header, err := r.Next()
+		if err == io.EOF {
+			return layers, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if header.Typeflag != tar.TypeReg || header.Size == 0 || !isLayerEntry(header.Name) {
+			continue
+		}
+		h := newHash()
+		if _, err := io.Copy(h, r); err != nil {
+			return nil, err
+		}
+		layers = append(layers, tLayer{path + ":" + header.Name, header.Size, fmt.Sprintf("%x", h.Sum(nil))})
+	}
+}
+
+// imageArchives returns the scanned tar files
+func imageArchives() []string {
+	duplicatesLock.Lock()
+	defer duplicatesLock.Unlock()
+	var result []string
+	for _, list := range duplicates {
+		for _, d := range list {
+			if strings.EqualFold(filepath.Ext(d.path), ".tar") {
+				result = append(result, d.path)
+			}
+		}
+	}
+	sort.Strings(result)
+	return result
+}
+
+// looseLayers hashes the scanned files as large as an archive layer, the
+// scan keys files alone in their size without a content hash
+func looseLayers(sizes map[int64]bool) ([]tLayer, error) {
+	duplicatesLock.Lock()
+	var files []tFileData
+	for _, list := range duplicates {
+		for _, d := range list {
+			if sizes[d.size] && !strings.EqualFold(filepath.Ext(d.path), ".tar") {
+				files = append(files, d)
+			}
+		}
+	}
+	duplicatesLock.Unlock()
+	layers := make([]tLayer, 0, len(files))
+	for _, d := range files {
+		sum, _, err := checksum(d.path)
+		if err != nil {
+			return nil, err
+		}
+		layers = append(layers, tLayer{d.path, d.size, fmt.Sprintf("%x", sum)})
+	}
+	return layers, nil
+}
+
+// sharedLayers groups the layers found more than once, largest waste first
+func sharedLayers() ([][]tLayer, error) {
+	var layers []tLayer
+	sizes := make(map[int64]bool)
+	for _, path := range imageArchives() {
+		found, err := archiveLayers(path)
+		if err != nil {
+			publishError(path, err)
+			continue
+		}
+		for _, l := range found {
+			sizes[l.size] = true
+		}
+		layers = append(layers, found...)
+	}
+	if len(layers) == 0 {
+		return nil, nil
+	}
+	loose, err := looseLayers(sizes)
+	if err != nil {
+		return nil, err
+	}
+	groups := make(map[string][]tLayer)
+	for _, l := range append(layers, loose...) {
+		key := formatter.Sprintf("%d:%s", l.size, l.sum)
+		groups[key] = append(groups[key], l)
+	}
+	var result [][]tLayer
+	for _, group := range groups {
+		if len(group) > 1 {
+			result = append(result, group)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i][0].size*int64(len(result[i])-1) > result[j][0].size*int64(len(result[j])-1)
+	})
+	return result, nil
+}
+
+func runLayers(out io.Writer, options tOptions) error {
+	go findDuplicates()
+	waitForScan(options)
+	groups, err := sharedLayers()
+	if err != nil {
+		return err
+	}
+	if len(groups) == 0 {
+		fmt.Fprintln(out, "No layer stored more than once.")
+		return nil
+	}
+	total := uint64(0)
+	for _, group := range groups {
+		wasted := uint64(group[0].size) * uint64(len(group)-1)
+		total += wasted
+		fmt.Fprintf(out, "%8s  layer of %s stored %d times:\n", bytefmt.ByteSize(wasted), bytefmt.ByteSize(uint64(group[0].size)), len(group))
+		for _, l := range group {
+			fmt.Fprintf(out, "          %s\n", l.place)
+		}
+	}
+	fmt.Fprintf(out, "%8s  reclaimable in %d layer(s)\n", bytefmt.ByteSize(total), len(groups))
+	return nil
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/rivo/tview"
+)
+
+type tLibrary struct {
+	app    string
+	suffix string
+}
+
+// Directories managed by an application, deleting "duplicates" inside them
+// corrupts the application's database.
+var appLibraries = []tLibrary{
+	{"Photos", ".photoslibrary"},
+	{"iPhoto", ".photolibrary"},
+	{"Aperture", ".aplibrary"},
+	{"Lightroom", ".lrdata"},
+	{"iTunes", "iTunes Media"},
+	{"Steam", "steamapps"},
+}
+
+var scanLibraries bool
+
+func matchLibrary(libs []tLibrary, name string) string {
+	for _, lib := range libs {
+		if strings.HasSuffix(name, lib.suffix) {
+			return lib.app
+		}
+	}
+	return ""
+}
+
+func libraryApp(name string) string {
+	return matchLibrary(appLibraries, name)
+}
+
+// visitLibraryDir skips application libraries unless -scan-libraries is set
+func visitLibraryDir(path string) error {
+	if scanLibraries {
+		return nil
+	}
+	if app := libraryApp(filepath.Base(path)); app != "" {
+		addNotice("Skipped %s library: %s", app, path)
+		return filepath.SkipDir
+	}
+	return nil
+}
+
+// libraryOf returns the application managing path or one of its parents
+func libraryOf(path string) (string, string) {
+	for dir := absPath(path); ; dir = filepath.Dir(dir) {
+		if app := libraryApp(filepath.Base(dir)); app != "" {
+			return app, dir
+		}
+		if filepath.Dir(dir) == dir {
+			return "", ""
+		}
+	}
+}
+
+// confirmLibraryScan warns before scanning inside an application library and
+// calls start only if the user accepts
+func confirmLibraryScan(app *tview.Application, start func()) {
+	name, dir := libraryOf(scanDir)
+	if name == "" {
+		start()
+		return
+	}
+	text := fmt.Sprintf("%s is managed by %s.\n\nRemoving duplicates inside it corrupts the %s database, scan anyway?", dir, name, name)
+	showModal(app, "library", text, []string{"Yes", "No"}, func(label string) {
+		if label != "Yes" {
+			app.Stop()
+			return
+		}
+		scanLibraries = true
+		start()
+	})
+}
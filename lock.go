@@ -0,0 +1,233 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// A run that can delete or move files locks its roots with a lock file at
+// their top, so two runs (an overlapping cron job, a second terminal) never
+// act on the same tree at once. A root inside the root of another run, or
+// holding one, is the same tree: the locks above a root are looked up on
+// disk, those below in the roots the user has locked on this host, listed
+// in the temp directory. A lock left by a process that's gone from this host
+// is stale and taken over, as is a lock file left empty or cut by a crash
+// once it's older than lockWriteLimit; -lock-wait queues behind a live one.
+// Roots that can't be written to aren't locked, nothing can be removed from
+// them either.
+
+const (
+	lockFile = ".dup-fu.lock"
+	// a lock file is written at once, one still unreadable after this was
+	// left by a crash
+	lockWriteLimit = time.Minute
+)
+
+var (
+	lockWait time.Duration
+	// the lock files this run holds
+	heldLocks []string
+)
+
+type tLock struct {
+	PID     int    `json:"pid"`
+	Host    string `json:"host"`
+	Started int64  `json:"started"`
+}
+
+func (l tLock) String() string {
+	if l.PID == 0 {
+		return "a lock file still being written"
+	}
+	return fmt.Sprintf("pid %d on %s since %s", l.PID, l.Host, time.Unix(l.Started, 0).Format("2006-01-02 15:04"))
+}
+
+func readLock(path string) (tLock, error) {
+	var lock tLock
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return lock, err
+	}
+	return lock, json.Unmarshal(data, &lock)
+}
+
+// stale tells whether the process holding the lock is gone, only known for
+// this host
+func (l tLock) stale() bool {
+	host, _ := os.Hostname()
+	return l.Host == host && !processAlive(l.PID)
+}
+
+// heldBy tells whether another live run holds the lock file
+func heldBy(path string) (tLock, bool) {
+	for _, held := range heldLocks {
+		if held == path {
+			return tLock{}, false
+		}
+	}
+	holder, err := readLock(path)
+	if os.IsNotExist(err) {
+		return holder, false
+	}
+	if err != nil {
+		info, err := os.Stat(path)
+		// being written by its holder, unless it's been too long
+		return holder, err == nil && time.Since(info.ModTime()) < lockWriteLimit
+	}
+	return holder, !holder.stale()
+}
+
+// tryLock creates the lock file of root, false with the holder when another
+// run has it
+func tryLock(path string) (bool, tLock, error) {
+	host, _ := os.Hostname()
+	data, err := json.Marshal(tLock{os.Getpid(), host, time.Now().Unix()})
+	if err != nil {
+		return false, tLock{}, err
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if os.IsExist(err) {
+		if holder, held := heldBy(path); held {
+			return false, holder, nil
+		}
+		return takeOver(path)
+	}
+	if err != nil {
+		return false, tLock{}, err
+	}
+	_, err = f.Write(data)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	return err == nil, tLock{}, err
+}
+
+// takeOver replaces a stale lock file. The runs finding it stale take turns
+// through a second file and look at the lock again, so none removes the one
+// another has just taken.
+func takeOver(path string) (bool, tLock, error) {
+	turn := path + ".takeover"
+	f, err := os.OpenFile(turn, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if os.IsExist(err) {
+		if info, err := os.Stat(turn); err == nil && time.Since(info.ModTime()) > lockWriteLimit {
+			// left by a run that crashed taking over
+			os.Remove(turn)
+		}
+		holder, _ := readLock(path)
+		return false, holder, nil
+	}
+	if err != nil {
+		return false, tLock{}, err
+	}
+	f.Close()
+	defer os.Remove(turn)
+	if holder, held := heldBy(path); held {
+		return false, holder, nil
+	}
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return false, tLock{}, err
+	}
+	return tryLock(path)
+}
+
+// lockedRootsDir lists the roots locked on this host, by the hash of their
+// path
+func lockedRootsDir() string {
+	return filepath.Join(os.TempDir(), fmt.Sprintf("dup-fu-locks-%d", os.Getuid()))
+}
+
+// overlapping returns the lock file and the holder of another run's root
+// above or below root
+func overlapping(root string) (string, tLock, bool) {
+	for dir := filepath.Dir(root); ; dir = filepath.Dir(dir) {
+		path := filepath.Join(dir, lockFile)
+		if holder, held := heldBy(path); held {
+			return path, holder, true
+		}
+		if filepath.Dir(dir) == dir {
+			break
+		}
+	}
+	entries, err := ioutil.ReadDir(lockedRootsDir())
+	if err != nil {
+		return "", tLock{}, false
+	}
+	for _, entry := range entries {
+		entryPath := filepath.Join(lockedRootsDir(), entry.Name())
+		data, err := ioutil.ReadFile(entryPath)
+		if err != nil {
+			continue
+		}
+		locked := string(data)
+		if !isWithin(locked, root) {
+			continue
+		}
+		path := filepath.Join(locked, lockFile)
+		if holder, held := heldBy(path); held {
+			return path, holder, true
+		}
+		if _, err := os.Stat(path); os.IsNotExist(err) {
+			// unlocked by a run that crashed before forgetting it
+			os.Remove(entryPath)
+		}
+	}
+	return "", tLock{}, false
+}
+
+func lockedRootPath(root string) string {
+	sum := sha256.Sum256([]byte(root))
+	return filepath.Join(lockedRootsDir(), hex.EncodeToString(sum[:8]))
+}
+
+// lockRoots locks every root, waiting up to -lock-wait for the runs holding
+// them or a tree around them
+func lockRoots(roots []string) error {
+	deadline := time.Now().Add(lockWait)
+	for _, root := range roots {
+		root := absPath(root)
+		path := filepath.Join(root, lockFile)
+		for {
+			lockPath, holder, busy := overlapping(root)
+			if !busy {
+				var locked bool
+				var err error
+				locked, holder, err = tryLock(path)
+				if os.IsPermission(err) || isReadOnly(err) {
+					break
+				}
+				if err != nil {
+					unlockRoots()
+					return err
+				}
+				if locked {
+					heldLocks = append(heldLocks, path)
+					if os.MkdirAll(lockedRootsDir(), 0700) == nil {
+						ioutil.WriteFile(lockedRootPath(root), []byte(root), 0600)
+					}
+					break
+				}
+				lockPath = path
+			}
+			if time.Now().After(deadline) {
+				unlockRoots()
+				return fmt.Errorf("%s is locked by another dup-fu run at %s, %v; -lock-wait queues behind it", root, filepath.Dir(lockPath), holder)
+			}
+			time.Sleep(time.Second)
+		}
+	}
+	return nil
+}
+
+func unlockRoots() {
+	for _, path := range heldLocks {
+		os.Remove(lockedRootPath(filepath.Dir(path)))
+		os.Remove(path)
+	}
+	heldLocks = nil
+}
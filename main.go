@@ -1,13 +1,18 @@
 package main
 
 import (
+	"flag"
 	"fmt"
-	"hash/crc32"
 	"io"
 	"log"
 	"os"
+	"os/signal"
 	"path/filepath"
 	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
 	"time"
 
 	"code.cloudfoundry.org/bytefmt"
@@ -22,6 +27,14 @@ type tFileData struct {
 	size     int64
 	hash     []byte
 	modified int64
+	partial  bool
+	// group key of a file settled without a full content hash
+	settled string
+	content int
+	// tagged as the original of its content by an earlier run
+	tagged bool
+	// device and inode, the key of the hash store
+	id string
 }
 
 type tStats struct {
@@ -30,143 +43,468 @@ type tStats struct {
 	size          uint64
 	duplicates    uint32
 	duplicateSize uint64
+	skipped       uint32
 	complted      bool
+	// every file is hashed and grouped, the pipeline is drained
+	finished bool
+	// duplicates in caches, counted when they join a group
+	cacheDuplicates    uint32
+	cacheDuplicateSize uint64
+	// by content kind
+	contentCount         [contentKinds]uint32
+	contentDuplicateSize [contentKinds]uint64
 }
 
 var (
-	fileChannel     chan tFileData
 	checksumChannel chan tFileData
 	duplicates      map[string][]tFileData
+	duplicatesLock  sync.Mutex // guards stats as well, snapshots see both at once
 	scanDir         string
 	targetDir       string
 	stats           tStats
 	formatter       *message.Printer
+	vmDisks         string
+	// the working directory relative paths are resolved against
+	workDir string
+	// groups with fewer files are left out of the lists and the actions
+	minCopies int
+	// hashing workers, checksumChannel is closed once they're all done
+	hashers sync.WaitGroup
+	// files sent to the hashing workers but not grouped yet
+	pending int64
+	pages   *tview.Pages
+	// one line feedback of the last action
+	statusView *tview.TextView
 )
 
-func panicErr(err error) {
+// visit does the bookkeeping of the walk and returns the files to hash
+func visit(path string, info os.FileInfo, err error, options tOptions) ([]tFileData, error) {
 	if err != nil {
-		log.Panicln(err)
+		if ready, ok := visitThroughHelper(path, info, err, options); ok {
+			return ready, nil
+		}
+		publishError(path, err)
+		addSkipped()
+		return nil, nil
 	}
-}
-
-func walk(path string, info os.FileInfo, err error) error {
-	if err != nil {
-		// TODO: log err to a file
+	if isExcluded(path, info.IsDir()) {
+		if info.IsDir() {
+			return nil, filepath.SkipDir
+		}
+		addSkipped()
+		return nil, nil
 	}
-	if !info.Mode().IsRegular() {
-		return nil
+	if !info.IsDir() && !isIncluded(path) {
+		addSkipped()
+		return nil, nil
 	}
 	if info.IsDir() {
-		return nil
+		loadIgnoreFile(path)
+		if err := visitLibraryDir(path); err != nil {
+			return nil, err
+		}
+		if err := visitBackupDir(path); err != nil {
+			return nil, err
+		}
+		if err := visitRecycleDir(path); err != nil {
+			return nil, err
+		}
+		if err := visitTrashDir(path); err != nil {
+			return nil, err
+		}
+		if err := visitThumbnailDir(path); err != nil {
+			return nil, err
+		}
+		visitTreeRoot(path)
+		return nil, visitGitDir(path)
+	}
+	if !info.Mode().IsRegular() || info.Name() == lockFile {
+		return nil, nil
+	}
+	if visitPlaceholder(info) {
+		addSkipped()
+		return nil, nil
+	}
+	if info.IsDir() {
+		return nil, nil
+	}
+	visitConflict(path)
+	visitArchive(path)
+	if visitDownload(path) {
+		addSkipped()
+		return nil, nil
 	}
 	size := info.Size()
-	if size > 0 {
-		fileChannel <- tFileData{path, size, nil, info.ModTime().UnixNano()}
+	if size == 0 {
+		return nil, nil
+	}
+	if isParity(path) {
+		// parity volumes are bound to their data files, never duplicates
+		visitParity(path)
+		addSkipped()
+		return nil, nil
+	}
+	if isGitTracked(path) {
+		addSkipped()
+		return nil, nil
+	}
+	data := tFileData{path: path, size: size, modified: info.ModTime().UnixNano(), id: fileID(path, info)}
+	if isVMDisk(path) {
+		if vmDisks == vmDisksSkip {
+			addSkipped()
+			return nil, nil
+		}
+		data.partial = vmDisks == vmDisksPartial
 	}
-	return nil
+	atomic.AddInt64(&pending, 1)
+	addPending(path, true)
+	return holdBySize(data, options), nil
 }
 
-func checksum(file string) ([]byte, int64) {
-	f, err := os.Open(file)
-	panicErr(err)
+// walk runs for every device at once, the bookkeeping is serialized but a
+// slow device blocks only its own hashing channel
+func walk(path string, info os.FileInfo, err error, options tOptions) error {
+	walkLock.Lock()
+	ready, err := visit(path, info, err, options)
+	walkLock.Unlock()
+	for _, data := range ready {
+		sendToHashing(data, options)
+	}
+	return err
+}
+
+func sendToHashing(data tFileData, options tOptions) {
+	if options.sequential {
+		// the walker hashes the file itself, the disk never reads two at once
+		if hashed, err := hashFile(data, nil, options); err != nil {
+			hashFailed(data, err)
+		} else {
+			checksumChannel <- hashed
+		}
+		return
+	}
+	if isSmallFile(data) {
+		smallFileChannel <- data
+	} else {
+		hashingChannel(data.path) <- data
+	}
+}
+
+func checksum(file string) ([]byte, int64, error) {
+	f, err := openFile(file)
+	if err != nil {
+		return nil, 0, err
+	}
 	defer f.Close()
-	h := crc32.New(crc32.IEEETable)
+	h := newHash()
 	buf := make([]byte, 2*1024*1024)
 	size, err := io.CopyBuffer(h, f, buf)
 	if err != nil {
-		log.Panicln(err)
+		return nil, size, err
 	}
-	return h.Sum(nil), size
+	return h.Sum(nil), size, nil
+}
+
+func (s tStats) duplicatePercent() float64 {
+	return float64(s.duplicateSize) / float64(s.size) * 100
 }
 
-func formatPercent() string {
-	if stats.size < 1 {
+func (s tStats) formatPercent() string {
+	if s.size < 1 {
 		return "-"
 	}
-	percent := float64(stats.duplicateSize) / float64(stats.size) * 100
-	var color = "green"
-	if percent > 15 {
-		color = "red"
-	} else if percent > 5 {
-		color = "yellow"
+	percent := s.duplicatePercent()
+	color := theme.severity[percentSeverity(percent)]
+	return fmt.Sprintf("[%s]%.2f %s[-]", color, percent, formatSeverity(percent))
+}
+
+// actedOn returns the files of the group the actions remove: the copies but
+// the original and the files kept with Ctrl+o, without the allowed ones;
+// none when the group is below -min-confidence
+func actedOn(hash string, list []tFileData) []tFileData {
+	if !reportedGroup(list) || groupConfidence(hash) < minConfidenceLevel {
+		return nil
 	}
-	percentStr := fmt.Sprintf("[%s]%.2f[%s]", color, percent, color)
-	return percentStr
+	_, removed := splitGroup(list)
+	result := make([]tFileData, 0, len(removed))
+	for _, dup := range removed {
+		if !isAllowed(dup.path) {
+			result = append(result, dup)
+		}
+	}
+	return result
 }
 
 func listDuplicates() []string {
 	result := make([]string, 0)
-	for _, list := range duplicates {
-		if len(list) < 2 {
-			continue
-		}
-		for _, dup := range list[1:] {
+	for hash, list := range copyDuplicates() {
+		for _, dup := range actedOn(hash, list) {
 			result = append(result, dup.path)
 		}
 	}
 	return result
 }
 
-func deleteDuplicates(app *tview.Application) {
-	// TODO: show modal to confirm
-	count := 0
-	list := listDuplicates()
+// removeDuplicates deletes every duplicate but the originals and returns the
+// deleted files, files seeded by the torrent client are kept
+func removeDuplicates() ([]string, int, error) {
+	list, seeding, err := withoutSeeding(listDuplicates())
+	if err != nil {
+		return nil, 0, err
+	}
+	list = withoutMismatches(list)
+	hashes := duplicateHashes()
+	removed := make([]string, 0, len(list))
 	for _, path := range list {
-		err := os.Remove(path)
-		panicErr(err)
-		count++
+		if err := deleteDuplicate(path, hashes[path]); err != nil {
+			actionFailed(path, err)
+			continue
+		}
+		removed = append(removed, path)
+	}
+	if recycleEnabled() && !dryRun {
+		return removed, seeding, pruneRecycle()
 	}
-	app.Stop()
-	log.Printf("Deleted %d duplicate file(s)", count)
+	return removed, seeding, nil
 }
 
-func ensureTargetDir() string {
-	err := os.MkdirAll(targetDir, os.ModePerm)
-	panicErr(err)
-	return targetDir
+// copyDuplicates copies the groups under the lock, the scan and the actions
+// go on changing them
+func copyDuplicates() map[string][]tFileData {
+	duplicatesLock.Lock()
+	defer duplicatesLock.Unlock()
+	result := make(map[string][]tFileData, len(duplicates))
+	for hash, list := range duplicates {
+		result[hash] = append([]tFileData(nil), list...)
+	}
+	return result
 }
 
-func moveDuplicates(app *tview.Application) {
-	ensureTargetDir()
-	count := 0
-	list := listDuplicates()
+// duplicateSizes returns the size of every grouped file by path
+func duplicateSizes() map[string]int64 {
+	sizes := make(map[string]int64)
+	duplicatesLock.Lock()
+	defer duplicatesLock.Unlock()
+	for _, group := range duplicates {
+		for _, d := range group {
+			sizes[d.path] = d.size
+		}
+	}
+	return sizes
+}
+
+// duplicatesSize sums the sizes of the listed duplicates
+func duplicatesSize(list []string) uint64 {
+	sizes := duplicateSizes()
+	total := uint64(0)
 	for _, path := range list {
-		err := os.Rename(path, filepath.Join(targetDir, filepath.Base(path)))
-		panicErr(err)
-		count++
+		total += uint64(sizes[path])
+	}
+	return total
+}
+
+// removalQuestion asks before deleting or moving, with the number of files
+// and the space at stake, and a line about the data files of a par2 recovery
+// set
+func removalQuestion(verb string, list []string) string {
+	text := formatter.Sprintf("%s %d duplicate file(s), %s?", verb, len(list), bytefmt.ByteSize(duplicatesSize(list)))
+	if bound := countParityBound(list); bound > 0 {
+		text += formatter.Sprintf("\n%d of them belong to a par2 recovery set, removing them breaks the set.", bound)
 	}
-	app.Stop()
-	log.Printf("Moved %d duplicate file(s) to: %s", count, targetDir)
+	return text
 }
 
-func exportDuplicates(app *tview.Application) {
-	// TODO: show modal to enter export file name
-	path := filepath.Join(ensureTargetDir(), "duplicates.txt")
-	file, err := os.Create(path)
-	panicErr(err)
-	defer file.Close()
-	count := 0
+func confirmRemoval(app *tview.Application, verb string, action func()) {
+	if !scanFinished() {
+		setStatus("Wait for the scan to finish")
+		return
+	}
 	list := listDuplicates()
+	if len(list) == 0 {
+		setStatus("No duplicates to " + strings.ToLower(verb))
+		return
+	}
+	showModal(app, "confirm", removalQuestion(verb, list), []string{"Yes", "No"}, func(label string) {
+		if label == "Yes" {
+			action()
+		}
+	})
+}
+
+func deleteDuplicates() tOutcome {
+	before := atomic.LoadInt64(&actionFailures)
+	removed, seeding, err := removeDuplicates()
+	o := newOutcome(opDelete, removed, err)
+	if !dryRun {
+		o.logf("Deleted %d duplicate file(s)", len(removed))
+	}
+	logSeeding(&o, seeding)
+	logMismatches(&o)
+	logFailures(&o, before)
+	tagOriginals(keptOriginals(removed))
+	if err := afterMediaRemoved(removed); err != nil {
+		o.logf("Media library refresh failed: %v", err)
+	}
+	return o
+}
+
+// absPath is filepath.Abs against the working directory read at startup,
+// it can't fail halfway through a run
+func absPath(path string) string {
+	if filepath.IsAbs(path) {
+		return filepath.Clean(path)
+	}
+	return filepath.Join(workDir, path)
+}
+
+func ensureTargetDir() (string, error) {
+	return targetDir, os.MkdirAll(targetDir, os.ModePerm)
+}
+
+// moveToTarget moves path into dir under its name, or name.1, name.2... when
+// another moved duplicate has it already: the name is reserved with an empty
+// file the move replaces, none overwrites another
+func moveToTarget(dir, path string) (string, error) {
+	name := filepath.Base(path)
+	for i := 0; ; i++ {
+		target := filepath.Join(dir, name)
+		if i > 0 {
+			target = filepath.Join(dir, fmt.Sprintf("%s.%d", name, i))
+		}
+		f, err := os.OpenFile(target, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+		if os.IsExist(err) {
+			continue
+		}
+		if err != nil {
+			return "", err
+		}
+		f.Close()
+		if err := os.Rename(path, target); err != nil {
+			os.Remove(target)
+			return "", err
+		}
+		return target, nil
+	}
+}
+
+// relocateDuplicates moves every duplicate but the originals to targetDir and
+// returns the moved files
+func relocateDuplicates() ([]string, int, error) {
+	list, seeding, err := withoutSeeding(listDuplicates())
+	if err != nil {
+		return nil, 0, err
+	}
+	list = withoutMismatches(list)
+	if dryRun {
+		return list, seeding, nil
+	}
+	if _, err := ensureTargetDir(); err != nil {
+		return nil, seeding, err
+	}
+	moved := make([]string, 0, len(list))
 	for _, path := range list {
-		_, err := file.WriteString(path)
-		panicErr(err)
-		file.WriteString("\n")
-		count++
+		info, err := os.Lstat(path)
+		if err != nil {
+			actionFailed(path, err)
+			continue
+		}
+		target, err := moveToTarget(targetDir, path)
+		if err != nil {
+			actionFailed(path, err)
+			continue
+		}
+		moved = append(moved, path)
+		// without the journal the move can't be undone, stop there
+		err = appendJournal(tJournalEntry{time.Now().Unix(), journalMove, absPath(path), absPath(target), info.Size()})
+		if err != nil {
+			return moved, seeding, err
+		}
 	}
-	app.Stop()
-	log.Printf("Exported %d duplicate file(s) to: %s", count, path)
+	return moved, seeding, nil
 }
 
-func setupHotkeys(app *tview.Application) {
+func moveDuplicates() tOutcome {
+	before := atomic.LoadInt64(&actionFailures)
+	moved, seeding, err := relocateDuplicates()
+	o := newOutcome(opMove, moved, err)
+	if !dryRun {
+		o.logf("Moved %d duplicate file(s) to: %s", len(moved), targetDir)
+	}
+	logSeeding(&o, seeding)
+	logMismatches(&o)
+	logFailures(&o, before)
+	tagOriginals(keptOriginals(moved))
+	if err := afterMediaRemoved(moved); err != nil {
+		o.logf("Media library refresh failed: %v", err)
+	}
+	return o
+}
+
+// exportDuplicates writes the duplicates to target-dir in the -output format
+func exportDuplicates() tOutcome {
+	dir, err := ensureTargetDir()
+	if err != nil {
+		return tOutcome{err: err}
+	}
+	path := filepath.Join(dir, exportName(outputFormat))
+	if err := writeExport(path, outputFormat); err != nil {
+		return tOutcome{err: err}
+	}
+	return tOutcome{summary: []string{"Exported the duplicates to: " + path}}
+}
+
+func setupHotkeys(app *tview.Application, left *tview.TextView, right *tview.List) {
 	app.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		if name, _ := pages.GetFrontPage(); name != "main" {
+			// a modal is open, Ctrl+m is Enter for its buttons
+			return event
+		}
 		if event.Key() == tcell.KeyESC {
 			app.Stop()
 		} else if event.Key() == tcell.KeyCtrlE {
-			exportDuplicates(app)
+			showExportForm(app, outputFormat)
+		} else if event.Key() == tcell.KeyCtrlJ {
+			showExportForm(app, outputJSON)
 		} else if event.Key() == tcell.KeyCtrlM {
-			moveDuplicates(app)
+			confirmRemoval(app, "Move", func() { runAction(app, right, "Moving", moveDuplicates) })
 		} else if event.Key() == tcell.KeyCtrlUnderscore {
-			deleteDuplicates(app)
+			confirmRemoval(app, "Delete", func() { runAction(app, right, "Deleting", deleteDuplicates) })
+		} else if event.Key() == tcell.KeyCtrlH {
+			confirmLinks(app, opHardlink, func() { runAction(app, right, "Linking", hardlinkDuplicates) })
+		} else if event.Key() == tcell.KeyCtrlF {
+			confirmLinks(app, "clone", func() { runAction(app, right, "Cloning", reflinkDuplicates) })
+		} else if event.Key() == tcell.KeyCtrlS {
+			confirmLinks(app, opSymlink, func() { runAction(app, right, "Linking", symlinkDuplicates) })
+		} else if event.Key() == tcell.KeyCtrlB {
+			confirmTags(app, right)
+		} else if event.Key() == tcell.KeyCtrlL {
+			confirmTrees(app, right)
+		} else if event.Key() == tcell.KeyCtrlR {
+			resolveConflicts(app)
+		} else if event.Key() == tcell.KeyCtrlW {
+			toggleSelected(right)
+		} else if event.Key() == tcell.KeyCtrlY {
+			copySelected(right, false)
+		} else if event.Key() == tcell.KeyCtrlG {
+			copySelected(right, true)
+		} else if event.Key() == tcell.KeyCtrlT {
+			openShell(app, right)
+		} else if event.Key() == tcell.KeyCtrlP {
+			showProvenance(app)
+		} else if event.Key() == tcell.KeyCtrlA {
+			allowSelected(right)
+		} else if event.Key() == tcell.KeyCtrlN {
+			showInstallers(app)
+		} else if event.Key() == tcell.KeyCtrlV {
+			go verifyAll(left)
+		} else if event.Key() == tcell.KeyCtrlK {
+			confirmCacheClear(app, right)
+		} else if event.Key() == tcell.KeyCtrlD {
+			showHistory(app)
+		} else if event.Key() == tcell.KeyCtrlO {
+			showChoices(app, right)
+		} else if event.Key() == tcell.KeyCtrlX {
+			showErrors(app)
 		}
 		return event
 	})
@@ -177,134 +515,618 @@ func newTextView(title, text string) *tview.TextView {
 	return tv
 }
 
-func setupGui() (*tview.Application, *tview.Flex, *tview.TextView, *tview.List) {
+func showModal(app *tview.Application, name, text string, buttons []string, done func(label string)) {
+	modal := tview.NewModal().SetText(text).AddButtons(buttons).SetDoneFunc(func(_ int, label string) {
+		pages.RemovePage(name)
+		app.SetFocus(pages)
+		done(label)
+	})
+	pages.AddPage(name, modal, false, true)
+	app.SetFocus(modal)
+}
+
+func setStatus(text string) {
+	if statusView != nil {
+		statusView.SetText(text)
+	}
+}
+
+func setupGui() (*tview.Application, *tview.Pages, *tview.TextView, *tview.List) {
 	app := tview.NewApplication()
-	path := newTextView("Path", scanDir)
+	path := newTextView("Path", rootsName())
 	left := newTextView("Stats", "").SetDynamicColors(true)
 	right := tview.NewList()
 	right.SetBorder(true).SetTitle("Duplicates").SetTitleAlign(tview.AlignLeft)
+	right.SetChangedFunc(func(index int, _, _ string, _ rune) {
+		showSelected(index)
+	})
+	listColumn = tview.NewFlex().SetDirection(tview.FlexRow).
+		AddItem(right, 0, 2, true)
 	contextBox := tview.NewFlex().
 		AddItem(left, 0, 1, false).
-		AddItem(right, 0, 3, true)
+		AddItem(listColumn, 0, 3, true)
 
-	help := newTextView("Help", "Ctrl+e: Export\t Ctrl+m: Move\t Ctrl+_: Delete\t Ctrl+o: Open selected item")
+	help := newTextView("Help", "Ctrl+e: Export\t Ctrl+j: Export JSON\t Ctrl+m: Move\t Ctrl+_: Delete\t Ctrl+h: Hardlink\t Ctrl+s: Symlink\t Ctrl+f: Clone\t Ctrl+b: Tag\t Ctrl+l: Link trees\t Ctrl+r: Resolve conflicts\t Ctrl+w: Full paths\t Ctrl+y: Copy path\t Ctrl+g: Copy group\t Ctrl+t: Shell here\t Ctrl+p: Provenance\t Ctrl+a: Allow copies\t Ctrl+n: Installers\t Ctrl+v: Verify\t Ctrl+k: Clear caches\t Ctrl+o: Keep or remove files\t Ctrl+d: History\t Ctrl+x: Errors")
+	statusView = tview.NewTextView()
 	flex := tview.NewFlex().SetDirection(tview.FlexRow).
 		AddItem(path, 3, 1, false).
 		AddItem(contextBox, 0, 1, true).
-		AddItem(help, 3, 1, false)
+		AddItem(help, 3, 1, false).
+		AddItem(statusView, 1, 1, false)
+	pages = tview.NewPages().AddPage("main", flex, true, true)
 
-	return app, flex, left, right
+	return app, pages, left, right
 }
 
-func scan() {
-	err := filepath.Walk(scanDir, walk)
-	panicErr(err)
+// scan walks the roots and hashes their files with the options, the groups
+// come out of findDuplicates
+func scan(options tOptions) {
+	startCheckpoints()
+	publishPhase(phaseWalking)
+	for i := 0; i < options.smallWorkers; i++ {
+		hashers.Add(1)
+		go calculateSmallChecksum(options)
+	}
+	setupDevices(options)
+	walkRoot := func(path string, info os.FileInfo, err error) error {
+		return walk(path, info, err, options)
+	}
+	var walkers sync.WaitGroup
+	for _, d := range devices {
+		walkers.Add(1)
+		go func(d *tDevice) {
+			defer walkers.Done()
+			for _, root := range d.roots {
+				if err := walkSnapshot(root, options, walkRoot); err != nil {
+					publishError(root, err)
+				}
+			}
+		}(d)
+	}
+	walkers.Wait()
+	duplicatesLock.Lock()
 	stats.complted = true
+	duplicatesLock.Unlock()
+	publishPhase(phaseHashing)
+	settleHeld(options)
+	// nothing is sent to hashing anymore, let the workers drain and exit
+	for _, d := range devices {
+		close(d.files)
+	}
+	close(smallFileChannel)
+	hashers.Wait()
+	findExtractedArchives()
+	reportPartialDownloads()
+	reportPlaceholders()
+	close(checksumChannel)
+}
+
+func calculateChecksum(files chan tFileData, w *tWorker, options tOptions) {
+	defer hashers.Done()
+	var ring *tRing
+	if options.ioUring {
+		var err error
+		if ring, err = newRing(); err != nil {
+			addNotice("io_uring unavailable, using regular reads: %v", err)
+		} else {
+			// a ring per worker, its fd and mappings go with it
+			defer ring.close()
+		}
+	}
+	for data := range files {
+		if known, exist := knownHash(data); exist {
+			// not read, the throughput leaves it out
+			checksumChannel <- known
+			continue
+		}
+		if hashed, err := hashFile(data, ring, options); err != nil {
+			hashFailed(data, err)
+		} else {
+			atomic.AddInt64(&w.hashed, hashed.size)
+			checksumChannel <- hashed
+		}
+	}
 }
 
-func calculateChecksum() {
-	for data := range fileChannel {
-		data.hash, _ = checksum(data.path)
-		checksumChannel <- data
+func hashFile(data tFileData, ring *tRing, options tOptions) (_ tFileData, err error) {
+	defer recoverHash(&err)
+	if known, exist := knownHash(data); exist {
+		return known, nil
 	}
+	data.content = sniffFile(data.path)
+	err = options.retry(func() error {
+		var err error
+		if data.partial {
+			data.hash, err = partialChecksum(data.path, data.size, partialChunkSize)
+		} else if ring != nil {
+			data.hash, err = ring.checksum(data.path)
+		} else {
+			data.hash, _, err = checksum(data.path)
+		}
+		return err
+	})
+	return data, err
 }
 
-func findDuplicates(right *tview.List) {
+func findDuplicates() {
 	for d := range checksumChannel {
+		hash := fmt.Sprintf("%x", d.hash)
+		if d.partial {
+			// partial hashes must never match a full content hash
+			hash = fmt.Sprintf("partial:%d:%s", d.size, hash)
+		} else if d.settled != "" {
+			hash = d.settled
+		}
+		d.tagged = isTagged(d.path, hash)
+		storeHash(d)
+		checkpointFile(d)
+		addPending(d.path, false)
+		duplicatesLock.Lock()
 		stats.count++
 		stats.size += uint64(d.size)
-		hash := fmt.Sprintf("%x", d.hash)
+		stats.contentCount[d.content]++
 		list, exist := duplicates[hash]
 		if exist {
 			list = append(list, d)
-			// keep the oldes file always as head
+			// keep the original always as head
 			sort.Slice(list, func(i, j int) bool {
-				return list[i].modified < list[j].modified
+				return originalBefore(list[i], list[j])
 			})
 			stats.duplicates++
 			stats.duplicateSize += uint64(d.size)
-			dupFiles := list[1].path
-			if len(list[1:]) > 1 {
-				dupFiles += formatter.Sprintf(" (+%d more)", len(list[1:])-1)
-			}
-			currentIndex := -1
-			for i := 0; i < right.GetItemCount(); i++ {
-				path, _ := right.GetItemText(i)
-				if list[0].path == path {
-					currentIndex = i
-					break
-				}
-			}
-			if currentIndex == -1 {
-				right.AddItem(list[0].path, dupFiles, rune(stats.duplicates+32), nil)
-			} else {
-				right.SetItemText(currentIndex, list[0].path, dupFiles)
+			stats.contentDuplicateSize[d.content] += uint64(d.size)
+			if isCachePath(d.path) {
+				stats.cacheDuplicates++
+				stats.cacheDuplicateSize += uint64(d.size)
 			}
 		} else {
 			list = make([]tFileData, 0)
 			list = append(list, d)
 		}
 		duplicates[hash] = list
+		// a copy, the next file of the group sorts the list in place
+		group := append([]tFileData(nil), list...)
+		duplicatesLock.Unlock()
+		publish(tEvent{kind: eventFileScanned, file: d, hash: hash})
+		if reportedGroup(group) {
+			publish(tEvent{kind: eventGroupUpdated, hash: hash, group: group})
+		}
+		atomic.AddInt64(&pending, -1)
+	}
+	// the channel is closed after the last hash, every result is in
+	recordHistory()
+	if errorCount() == 0 {
+		// an unreadable directory isn't a deleted one
+		pruneHashStore(scanRoots())
 	}
+	if err := saveHashStore(); err != nil {
+		addNotice("couldn't save the hashes: %v", err)
+	}
+	finishCheckpoints()
+	duplicatesLock.Lock()
+	stats.finished = true
+	duplicatesLock.Unlock()
+	publishPhase(phaseFinished)
+}
+
+// showGroups keeps the Duplicates list in sync, from the UI goroutine
+func showGroups(app *tview.Application, right *tview.List, events <-chan tEvent) {
+	for e := range events {
+		e := e
+		app.QueueUpdateDraw(func() {
+			setListItem(right, e.hash, e.group)
+		})
+	}
+}
+
+// showErrorEvents puts every error in the status line as it happens, Ctrl+x
+// lists them all
+func showErrorEvents(app *tview.Application, events <-chan tEvent) {
+	for e := range events {
+		e := e
+		app.QueueUpdateDraw(func() {
+			setStatus(formatter.Sprintf("Error: %s: %v (Ctrl+x)", e.path, e.err))
+		})
+	}
+}
+
+// scanFinished reports whether the walk is done and every file is grouped
+func scanFinished() bool {
+	duplicatesLock.Lock()
+	defer duplicatesLock.Unlock()
+	return stats.finished
 }
 
 func updateStats(left *tview.TextView) {
 	for range time.Tick(time.Second * 1) {
+		duplicatesLock.Lock()
 		stats.seconds++
-		var done string
-		if done = "[red]No[red]"; stats.complted {
-			done = "[green]Yes[green]"
-		}
-		percent := formatPercent()
-		speed := float64(stats.size) / float64(stats.seconds)
-		left.SetText(
-			formatter.Sprintf(
-				"Elapsed: %d seconds\nScanned: %d\nSize: %s\nRead Speed: %s\nDuplicates: %d\nDuplicate Size: %s\nDuplicate Percent: %s\nFinished: %s",
-				stats.seconds,
-				stats.count, bytefmt.ByteSize(stats.size), bytefmt.ByteSize(uint64(speed)),
-				stats.duplicates, bytefmt.ByteSize(stats.duplicateSize),
-				percent,
-				done))
-		//right.SetText(strconv.FormatInt(counter, 10))
-		if stats.complted {
+		duplicatesLock.Unlock()
+		sampleWorkers()
+		if showStats(left) {
 			break
 		}
 	}
 }
 
+// showStats fills the Stats panel and tells whether the scan is finished
+func showStats(left *tview.TextView) bool {
+	snap := takeSnapshot(0)
+	var done string
+	if done = "[red]No[red]"; snap.finished {
+		done = "[green]Yes[green]"
+	} else if snap.stats.complted {
+		// the walk is done, the workers are not
+		done = formatter.Sprintf("[yellow]Hashing, %d left[-]", snap.pending)
+	}
+	percent := snap.stats.formatPercent()
+	speed := float64(snap.stats.size) / float64(snap.stats.seconds)
+	left.SetText(
+		formatter.Sprintf(
+			"Elapsed: %d seconds\nScanned: %d\nSkipped: %d\nSize: %s\nRead Speed: %s\nDuplicates: %d\nDuplicate Size: %s\nDuplicate Percent: %s\nIn Caches: %s\nContent: %s\nConflicts: %d\nFinished: %s",
+			snap.stats.seconds,
+			snap.stats.count, snap.stats.skipped, bytefmt.ByteSize(snap.stats.size), bytefmt.ByteSize(uint64(speed)),
+			snap.stats.duplicates, bytefmt.ByteSize(snap.stats.duplicateSize),
+			percent,
+			formatCacheDuplicates(snap.stats),
+			formatContent(snap.stats),
+			snap.conflicts,
+			done))
+	if failed := formatErrorCount(); failed != "" {
+		fmt.Fprintf(left, "\n%s", failed)
+	}
+	if verification := formatVerification(); verification != "" {
+		fmt.Fprintf(left, "\n%s", verification)
+	}
+	if workers := formatWorkers(); workers != "" && !snap.finished {
+		fmt.Fprintf(left, "\n%s", workers)
+	}
+	for _, notice := range snap.notices {
+		fmt.Fprintf(left, "\n%s", tview.Escape(notice))
+	}
+	return snap.finished
+}
+
 func main() {
-	fileChannel = make(chan tFileData, 200)
-	defer close(fileChannel)
+	if os.Geteuid() != os.Getuid() {
+		// a setuid copy would do anything it's asked for any user, -helper
+		// runs the hash-helper through sudo or doas instead
+		log.Fatalln("dup-fu doesn't run setuid, -helper sudo reads what the user can't")
+	}
+	smallFileChannel = make(chan tFileData, 200)
 	checksumChannel = make(chan tFileData, 100)
-	defer close(checksumChannel)
 
 	duplicates = make(map[string][]tFileData)
-	stats = tStats{0, 0, 0, 0, 0, false}
+	stats = tStats{}
 	formatter = message.NewPrinter(language.English)
-	if len(os.Args) > 2 {
-		scanDir = os.Args[1]
-		targetDir = os.Args[2]
+	var err error
+	if workDir, err = os.Getwd(); err != nil {
+		log.Fatalln(err)
+	}
+	if err := resumeArgs(); err != nil {
+		log.Fatalln(err)
+	}
+	command := commandName(os.Args[1:])
+	defaults := defaultOptions()
+	flag.Usage = usage
+	flag.BoolVar(&initConfig, "init", false, "write a starter config file and exit")
+	flag.StringVar(&profile, "profile", "", "apply the options and directories of this profile of the config file")
+	flag.StringVar(&vmDisks, "vm-disks", vmDisksSkip, "how to handle VM disk images (skip, partial or full)")
+	flag.BoolVar(&scanGitDirs, "git-dirs", false, "scan inside .git directories")
+	flag.BoolVar(&skipGitTracked, "skip-git-tracked", false, "skip files tracked by git")
+	flag.BoolVar(&scanLibraries, "scan-libraries", false, "scan inside application libraries (Photos, iTunes, Steam)")
+	flag.StringVar(&mediaServer, "media-server", "", "media server to refresh after removing media files (plex or jellyfin)")
+	flag.StringVar(&mediaURL, "media-url", "", "media server URL, e.g. http://localhost:32400")
+	flag.StringVar(&mediaToken, "media-token", "", "media server API token")
+	flag.StringVar(&hashName, "hash", "crc32", "hash algorithm (crc32, xxhash64, md5, sha256 or blake3)")
+	flag.StringVar(&hashesFrom, "hashes-from", "", "reuse hashes from a sha256sum/md5sum/rclone hashsum list")
+	flag.BoolVar(&resume, "resume", false, "run the interrupted scan of the directories that follow again, the files it grouped aren't read again")
+	flag.StringVar(&cacheDir, "cache-dir", defaultCacheDir(), "directory the hashes are kept in for the next scans, empty to hash every file again")
+	flag.StringVar(&listHash, "list-hash", "", "algorithm of the hash list given to the cache commands, guessed from its digest length when unset")
+	flag.StringVar(&cacheSample, "sample", "1%", "percent of the current entries cache verify reads")
+	flag.StringVar(&torrentClient, "torrent-client", "", "keep files seeded by this torrent client (qbittorrent or transmission)")
+	flag.StringVar(&torrentURL, "torrent-url", "", "torrent client web API URL, e.g. http://localhost:8080")
+	flag.StringVar(&torrentUser, "torrent-user", "", "torrent client user name")
+	flag.StringVar(&torrentPassword, "torrent-password", "", "torrent client password")
+	flag.BoolVar(&scanBackups, "scan-backups", false, "scan inside Time Machine and File History backups and restic, Borg and Kopia repositories")
+	flag.BoolVar(&dryRun, "dry-run", false, "report what delete, move, link and apply would do to every file without touching them")
+	flag.BoolVar(&permanentDelete, "permanent", false, "delete files for good instead of moving them to the trash")
+	flag.StringVar(&recycleDir, "recycle-dir", "", "keep deleted duplicates in this directory so they can be restored")
+	flag.IntVar(&recycleDays, "recycle-days", 30, "days deleted duplicates are kept in the recycle directory")
+	flag.StringVar(&recycleSize, "recycle-size", "10G", "maximum size of the recycle directory")
+	flag.StringVar(&restorePath, "restore", "", "restore deleted duplicates under this path from the recycle directory and exit")
+	flag.IntVar(&targetDays, "target-days", 0, "delete moved duplicates older than this many days from target-dir on startup")
+	flag.StringVar(&targetSize, "target-size", "", "delete the oldest moved duplicates on startup until target-dir fits in this size")
+	flag.BoolVar(&digest, "digest", false, "scan without the TUI and mail a digest of the duplicates")
+	flag.StringVar(&smtpServer, "smtp-server", "", "SMTP server for the digest, host:port")
+	flag.StringVar(&smtpUser, "smtp-user", "", "SMTP user name")
+	flag.StringVar(&smtpPassword, "smtp-password", "", "SMTP password")
+	flag.StringVar(&mailFrom, "mail-from", "", "digest sender address")
+	flag.DurationVar(&lockWait, "lock-wait", 0, "wait this long for another run acting on the same directories to finish, e.g. 30m")
+	flag.StringVar(&serviceTime, "service-time", "03:00", "time of day service install schedules the run at")
+	flag.StringVar(&serviceDay, "service-day", "mon", "day of the week service install schedules the run on")
+	flag.StringVar(&mailTo, "mail-to", "", "digest recipient addresses, comma separated")
+	flag.BoolVar(&rpcMode, "rpc", false, "serve JSON-RPC over stdin/stdout instead of the TUI")
+	flag.StringVar(&rpcRootList, "rpc-roots", "", "directories the rpc scan method may scan and move files to, comma separated")
+	flag.BoolVar(&rpcViewer, "rpc-viewer", false, "viewer role for the rpc front-end: scan and browse, every act is refused")
+	flag.StringVar(&outputFormat, "output", outputText, "format of the report command, -no-tui and the Ctrl+e export (text, json or csv)")
+	flag.BoolVar(&noTUI, "no-tui", false, "print the duplicates, or apply -action to them, without the TUI; the default without a terminal")
+	flag.BoolVar(&simpleUI, "simple-ui", false, "single column interface for minimal terminals")
+	flag.BoolVar(&accessible, "accessible", false, "screen reader friendly line mode, commands are single letters")
+	flag.StringVar(&themeName, "theme", "default", "color theme (default or colorblind)")
+	flag.Float64Var(&mediumPercent, "medium-percent", 5, "duplicate percent shown as medium severity above this")
+	flag.Float64Var(&highPercent, "high-percent", 15, "duplicate percent shown as high severity above this")
+	flag.Float64Var(&alertPercent, "alert-percent", 0, "alert when the duplicates exceed this percent of the scanned size")
+	flag.StringVar(&alertSize, "alert-size", "", "alert when the reclaimable size exceeds this, e.g. 100G")
+	flag.StringVar(&alertWebhook, "alert-webhook", "", "URL the alert is POSTed to as JSON")
+	flag.BoolVar(&relativePaths, "relative-paths", false, "show paths relative to scan-dir")
+	flag.BoolVar(&truncatePaths, "truncate-paths", false, "shorten long paths in the middle, keeping the file name")
+	flag.BoolVar(&estimate, "estimate", false, "sample the files first, estimate duplicates and scan time, then ask to proceed")
+	flag.BoolVar(&noXattr, "no-xattr", false, "don't tag the kept originals with extended attributes, for filesystems without them")
+	flag.BoolVar(&hydrate, "hydrate", false, "download and hash the online-only files of sync clients instead of skipping them")
+	flag.Var(&extraRoots, "root", "another directory to scan, can be repeated")
+	flag.BoolVar(&crossOnly, "cross-only", false, "only report duplicates with copies in more than one scan root")
+	flag.StringVar(&targetOption, "target", "", "target-dir, every directory argument is then a scan root")
+	flag.Var(&includePatterns, "include", "only scan the files whose name matches these patterns, comma separated, e.g. \"*.jpg,*.png\"")
+	flag.Var(&excludePatterns, "exclude", "skip the files and directories matching this gitignore pattern, can be repeated")
+	flag.Var(&snapshotOptions, "snapshot", "/live=/snapshot, walk and read a read-only snapshot of /live mounted at /snapshot, the files keep their live path, can be repeated")
+	flag.StringVar(&helperCommand, "helper", "", "read the directories and files the user can't through the hash-helper command run by sudo or doas")
+	flag.BoolVar(&useVSS, "vss", false, "scan a shadow copy of the volumes, made for the scan, to read locked files consistently (Windows, as an administrator)")
+	flag.Var(&remapOptions, "remap", "/old=/new, read the paths under /old from hash lists, plans, decisions and the move journal as under /new, can be repeated")
+	flag.Var(&excludeRegexps, "exclude-regex", "skip the paths matching this regular expression, can be repeated")
+	flag.IntVar(&dirWalkers, "walkers", defaults.walkers, "directories read at once per device, for network filesystems and huge trees; 1 walks one at a time")
+	flag.BoolVar(&sequential, "sequential", false, "read one file at a time per device in walk order, for spinning disks")
+	flag.IntVar(&workers, "workers", 0, "hashing workers per device for files of 64KB and more, 0 for 2 on spinning disks and one per CPU on others")
+	flag.IntVar(&smallWorkers, "small-workers", defaults.smallWorkers, "hashing workers for files smaller than 64KB")
+	flag.BoolVar(&comparePairs, "compare-pairs", false, "compare files sharing their size with only one other file instead of hashing them")
+	flag.Int64Var(&prefilterSize, "prefilter", 0, "hash the first and last this many KB of large same-size files before reading them whole")
+	flag.StringVar(&keepStrategy, "keep", keepOldest, "file kept as the original: oldest, newest, shortest-path, longest-path, first-alphabetical, path-priority or root-order")
+	flag.StringVar(&keepDirList, "keep-dirs", "", "directories ranked for -keep path-priority, comma separated, most wanted first")
+	flag.BoolVar(&verifyCopies, "verify", false, "compare every duplicate byte by byte with its original before deleting or moving it")
+	flag.BoolVar(&downloadsPreset, "downloads", false, "preset for Downloads folders: numbered copies are never the original, partial downloads are reported, deleted files go to a trash")
+	flag.BoolVar(&progressive, "progressive", false, "hash same-size files 4MB at a time, dropping them once they differ")
+	flag.IntVar(&retries, "retries", defaults.retries, "read attempts after a transient IO error (EIO, timeouts), e.g. on network shares")
+	flag.DurationVar(&retryDelay, "retry-delay", defaults.retryDelay, "wait before the first retry, doubled after every attempt")
+	flag.BoolVar(&useIOUring, "io-uring", false, "read files through io_uring (Linux, built with -tags iouring)")
+	flag.IntVar(&minCopies, "min-copies", 2, "only report content found at least this many times")
+	flag.StringVar(&contentTypes, "content", "", "only report these content kinds, comma separated (image, video, audio, archive, document, binary)")
+	flag.StringVar(&minConfidence, "min-confidence", "medium", "skip groups below this confidence when deleting or moving (low, medium or high); low acts on the partial hashes of VM disks too")
+	flag.StringVar(&planAction, "action", opDelete, "operation of the plan, clean and apply-decisions commands (delete, move, hardlink, symlink, reflink or tag)")
+	flag.BoolVar(&assumeYes, "yes", false, "don't ask before the clean command, or -action without a terminal, acts")
+	flag.StringVar(&linkStyle, "link-style", linkRelative, "symlinks made by Ctrl+s and -action symlink point to the original by a relative or absolute path")
+	flag.StringVar(&errorLogPath, "error-log", defaultErrorLog(), "file the errors of the run are written to, empty for none")
+	flag.IntVar(&historyDays, "history-days", 365, "days finished scans are kept in the history")
+	flag.BoolVar(&noHistory, "no-history", false, "don't record this scan in the history")
+	flag.DurationVar(&burstGap, "burst-gap", 2*time.Second, "longest time between two photos of a burst for the bursts command")
+	flag.IntVar(&similarMin, "similar-min", 50, "percent of the smaller file two files share to be reported by the similar command")
+	if err := loadConfig(os.Args[1:]); err != nil {
+		log.Fatalln(err)
+	}
+	if command == "service" {
+		flag.CommandLine.Parse(serviceOptions())
+	} else if command == "cache" {
+		flag.CommandLine.Parse(cacheOptions())
+	} else if command != "" {
+		flag.CommandLine.Parse(os.Args[2:])
 	} else {
-		if len(os.Args) == 2 {
-			scanDir = os.Args[1]
-		} else {
-			scanDir = "."
+		flag.Parse()
+	}
+	if command == "help" {
+		usage()
+		return
+	}
+	if initConfig {
+		path, err := writeStarterConfig()
+		if err != nil {
+			log.Fatalln(err)
+		}
+		log.Printf("Wrote the starter config: %s", path)
+		return
+	}
+	if err := validateOptions(command, commandArgs()); err != nil {
+		log.Fatalln(err)
+	}
+	options, err := flagOptions()
+	if err != nil {
+		log.Fatalln(err)
+	}
+	command, err = headlessCommand(command)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	if command == "hash-helper" {
+		if err := runHashHelper(os.Stdin, os.Stdout); err != nil {
+			log.Fatalln(err)
 		}
-		targetDir = filepath.Join(scanDir, ".dup-fu")
+		return
+	}
+	if command == "service" {
+		if err := runService(); err != nil {
+			log.Fatalln(err)
+		}
+		return
+	}
+	if command == "cache" {
+		if err := runCache(os.Stdout, flag.Args()); err != nil {
+			log.Fatalln(err)
+		}
+		return
+	}
+	if command == "history" {
+		if err := runHistory(os.Stdout, flag.Args()); err != nil {
+			log.Fatalln(err)
+		}
+		return
+	}
+	if recycleEnabled() && !dryRun {
+		if err := pruneRecycle(); err != nil {
+			log.Fatalln(err)
+		}
+	}
+	if command == "apply" {
+		if err := runApply(flag.Arg(0)); err != nil {
+			log.Fatalln(err)
+		}
+		return
+	}
+	if command == "apply-decisions" {
+		if err := runApplyDecisions(flag.Arg(0)); err != nil {
+			log.Fatalln(err)
+		}
+		return
+	}
+	if restorePath != "" {
+		count, err := restoreRecycled(restorePath)
+		if err != nil {
+			log.Fatalln(err)
+		}
+		log.Printf("Restored %d file(s)", count)
+		return
+	}
+	if err := loadAllowed(); err != nil {
+		log.Fatalln(err)
+	}
+	if hashesFrom != "" {
+		if err := loadHashes(hashesFrom); err != nil {
+			log.Fatalln(err)
+		}
+	}
+	if err := loadHashStore(); err != nil {
+		addNotice("couldn't load the stored hashes: %v", err)
+	}
+	if err := loadResumed(); err != nil {
+		addNotice("couldn't read the files of the interrupted scan: %v", err)
+	}
+	if !dryRun {
+		// retention deletes files too
+		if expired, err := applyRetention(); err != nil {
+			log.Fatalln(err)
+		} else if expired > 0 {
+			log.Printf("Deleted %d expired file(s) from: %s", expired, targetDir)
+		}
+	}
+	if estimate && !rpcMode {
+		proceed, err := confirmEstimate(os.Stdin, os.Stderr)
+		if err != nil {
+			log.Fatalln(err)
+		}
+		if !proceed {
+			return
+		}
+	}
+	if err := run(command, options); err == errAlerted {
+		os.Exit(alertExitCode)
+	} else if err != nil {
+		log.Fatalln(err)
+	}
+}
+
+// run acts on the scan once the options are set, every error comes back to
+// main so the helper, the shadow copies and the locks are released first
+func run(command string, options tOptions) error {
+	if err := enableBackupPrivilege(); err != nil && err != errPrivilegeNotHeld {
+		addNotice("couldn't enable the backup privilege: %v", err)
+	}
+	if err := startHelper(); err != nil {
+		return err
+	}
+	defer stopHelper()
+	if useVSS {
+		if err := createShadowCopies(); err != nil {
+			return err
+		}
+		defer removeShadowCopies()
+	}
+	releaseOnInterrupt()
+	if alertEnabled() {
+		go watchAlert(subscribe(eventPhaseChanged))
+	}
+	if command == "plan" {
+		if err := runPlan(os.Stdout, options); err != nil {
+			return err
+		}
+		return alertError()
+	}
+	if command == "similar" {
+		if err := runSimilar(os.Stdout, options); err != nil {
+			return err
+		}
+		return alertError()
+	}
+	if command == "review" {
+		return runReview(os.Stdout, options)
+	}
+	if command == "decisions" {
+		return runDecisions(os.Stdout, options)
+	}
+	if command == "report" {
+		if err := runReport(os.Stdout, options); err != nil {
+			return err
+		}
+		return alertError()
+	}
+	if command == "bursts" {
+		return runBursts(os.Stdout, options)
+	}
+	if command == "layers" {
+		return runLayers(os.Stdout, options)
+	}
+	if digest {
+		if err := runDigest(options); err != nil {
+			return err
+		}
+		return alertError()
+	}
+	if !rpcMode {
+		// the rpc front-end names its roots later, scan locks them
+		if err := lockRoots(scanRoots()); err != nil {
+			return err
+		}
+		defer unlockRoots()
+	}
+	if command == "clean" {
+		return runClean(os.Stdin, os.Stdout, options)
+	}
+	if accessible {
+		return runAccessible(os.Stdin, options)
+	}
+	if simpleUI {
+		return runSimpleUI(options)
+	}
+	if rpcMode {
+		return runRPC(options)
 	}
 
-	app, flex, left, right := setupGui()
-	setupHotkeys(app)
+	app, root, left, right := setupGui()
+	setupHotkeys(app, left, right)
 	left.SetChangedFunc(func() {
 		app.Draw()
 	})
 
-	go updateStats(left)
-	go scan()
-	go calculateChecksum()
-	go calculateChecksum()
-	go findDuplicates(right)
+	go showGroups(app, right, subscribe(eventGroupUpdated))
+	go showErrorEvents(app, subscribe(eventError))
+	go findDuplicates()
+	confirmLibraryScan(app, func() {
+		go updateStats(left)
+		go scan(options)
+	})
+
+	if err := app.SetRoot(root, true).SetFocus(root).Run(); err != nil {
+		return err
+	}
+	// quit during the scan, -resume goes on from here
+	return saveCheckpoint()
+}
 
-	err := app.SetRoot(flex, true).SetFocus(flex).Run()
-	panicErr(err)
+// releaseOnInterrupt deletes the shadow copies and the locks of a run
+// stopped by Ctrl-C or a kill, the deferred calls of run don't happen then
+func releaseOnInterrupt() {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-signals
+		removeShadowCopies()
+		unlockRoots()
+		os.Exit(1)
+	}()
 }
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	mediaServerPlex     = "plex"
+	mediaServerJellyfin = "jellyfin"
+)
+
+var (
+	mediaServer string
+	mediaURL    string
+	mediaToken  string
+)
+
+var mediaExtensions = map[string]bool{
+	".mkv": true, ".mp4": true, ".m4v": true, ".avi": true, ".mov": true, ".wmv": true, ".ts": true,
+	".mp3": true, ".flac": true, ".m4a": true, ".ogg": true, ".opus": true, ".wav": true,
+	".jpg": true, ".jpeg": true, ".png": true, ".heic": true,
+	".srt": true, ".sub": true, ".ass": true,
+}
+
+func validMediaServer(server string) bool {
+	return server == "" || server == mediaServerPlex || server == mediaServerJellyfin
+}
+
+func isMediaFile(path string) bool {
+	return mediaExtensions[strings.ToLower(filepath.Ext(path))]
+}
+
+func containsMedia(paths []string) bool {
+	for _, path := range paths {
+		if isMediaFile(path) {
+			return true
+		}
+	}
+	return false
+}
+
+// refreshMediaLibrary asks the configured media server to rescan its
+// libraries, so removed files don't show up as dead entries
+func refreshMediaLibrary() error {
+	var req *http.Request
+	var err error
+	base := strings.TrimRight(mediaURL, "/")
+	switch mediaServer {
+	case mediaServerPlex:
+		req, err = http.NewRequest(http.MethodGet, base+"/library/sections/all/refresh?X-Plex-Token="+url.QueryEscape(mediaToken), nil)
+	case mediaServerJellyfin:
+		req, err = http.NewRequest(http.MethodPost, base+"/Library/Refresh", nil)
+		if err == nil {
+			req.Header.Set("X-Emby-Token", mediaToken)
+		}
+	default:
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	client := http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("%s refresh failed: %s", mediaServer, resp.Status)
+	}
+	return nil
+}
+
+// afterMediaRemoved refreshes the media server if any of the removed files is media
+func afterMediaRemoved(removed []string) error {
+	if mediaServer == "" || dryRun || !containsMedia(removed) {
+		return nil
+	}
+	return refreshMediaLibrary()
+}
@@ -0,0 +1,21 @@
+package main
+
+import "sync"
+
+var (
+	noticesLock sync.Mutex
+	// things the user should know about the scan, e.g. skipped directories
+	notices []string
+)
+
+func addNotice(format string, a ...interface{}) {
+	noticesLock.Lock()
+	defer noticesLock.Unlock()
+	notices = append(notices, formatter.Sprintf(format, a...))
+}
+
+func listNotices() []string {
+	noticesLock.Lock()
+	defer noticesLock.Unlock()
+	return append([]string(nil), notices...)
+}
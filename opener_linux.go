@@ -0,0 +1,45 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"syscall"
+)
+
+// tOpener opens files relative to a cached directory descriptor, the walk
+// sends files directory by directory so most opens skip the path lookup
+type tOpener struct {
+	dir string
+	fd  int
+}
+
+func newOpener() *tOpener {
+	return &tOpener{fd: -1}
+}
+
+func (o *tOpener) open(path string) (*os.File, error) {
+	dir, name := filepath.Split(snapshotPath(path))
+	if dir == "" {
+		dir = "."
+	}
+	if dir != o.dir || o.fd < 0 {
+		o.close()
+		fd, err := syscall.Open(dir, syscall.O_RDONLY|syscall.O_DIRECTORY|syscall.O_CLOEXEC, 0)
+		if err != nil {
+			return nil, &os.PathError{Op: "open", Path: dir, Err: err}
+		}
+		o.dir, o.fd = dir, fd
+	}
+	fd, err := syscall.Openat(o.fd, name, syscall.O_RDONLY|syscall.O_CLOEXEC, 0)
+	if err != nil {
+		return nil, &os.PathError{Op: "openat", Path: path, Err: err}
+	}
+	return os.NewFile(uintptr(fd), path), nil
+}
+
+func (o *tOpener) close() {
+	if o.fd >= 0 {
+		syscall.Close(o.fd)
+		o.fd = -1
+	}
+}
@@ -0,0 +1,18 @@
+//go:build !linux
+// +build !linux
+
+package main
+
+import "os"
+
+type tOpener struct{}
+
+func newOpener() *tOpener {
+	return &tOpener{}
+}
+
+func (o *tOpener) open(path string) (*os.File, error) {
+	return openFile(path)
+}
+
+func (o *tOpener) close() {}
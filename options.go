@@ -0,0 +1,333 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	"code.cloudfoundry.org/bytefmt"
+)
+
+// tOptions are the walk and hashing settings of a scan: how the roots are
+// walked and how their files are read. The flags are turned into options by
+// flagOptions like any other caller would build them, checked by newOptions
+// before the first file is read, and the scan gets them as a value. The
+// roots, target-dir, filters, hash algorithm and actions are still package
+// globals set by validateOptions, the RPC scan call sets its roots there.
+type tOptions struct {
+	// directories read at once per device
+	walkers int
+	// one file at a time per device, in walk order
+	sequential bool
+	// hashing workers per device, 0 for as many as the device is worth
+	workers      int
+	smallWorkers int
+	// the ends hashed first, in KB, 0 disables the prefilter
+	prefilter  int64
+	retries    int
+	retryDelay time.Duration
+	ioUring    bool
+}
+
+type tOption func(*tOptions)
+
+// the flags of the engine, read by flagOptions only
+var (
+	dirWalkers    int
+	sequential    bool
+	workers       int
+	smallWorkers  int
+	prefilterSize int64
+	retries       int
+	retryDelay    time.Duration
+	useIOUring    bool
+)
+
+func defaultOptions() tOptions {
+	return tOptions{
+		walkers:      8,
+		smallWorkers: 2 * runtime.NumCPU(),
+		retries:      3,
+		retryDelay:   500 * time.Millisecond,
+	}
+}
+
+func withWalkers(count int) tOption {
+	return func(o *tOptions) { o.walkers = count }
+}
+
+func withSequential(sequential bool) tOption {
+	return func(o *tOptions) { o.sequential = sequential }
+}
+
+func withWorkers(count, small int) tOption {
+	return func(o *tOptions) { o.workers, o.smallWorkers = count, small }
+}
+
+func withPrefilter(kb int64) tOption {
+	return func(o *tOptions) { o.prefilter = kb }
+}
+
+func withRetries(count int, delay time.Duration) tOption {
+	return func(o *tOptions) { o.retries, o.retryDelay = count, delay }
+}
+
+func withIOUring(enabled bool) tOption {
+	return func(o *tOptions) { o.ioUring = enabled }
+}
+
+// newOptions applies opts to the defaults and checks the result
+func newOptions(opts ...tOption) (tOptions, error) {
+	o := defaultOptions()
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if o.walkers < 1 {
+		return o, fmt.Errorf("invalid -walkers value: %d", o.walkers)
+	}
+	if o.workers < 0 {
+		return o, fmt.Errorf("invalid -workers value: %d", o.workers)
+	}
+	if o.smallWorkers < 1 {
+		return o, fmt.Errorf("invalid -small-workers value: %d", o.smallWorkers)
+	}
+	if o.prefilter < 0 {
+		return o, fmt.Errorf("invalid -prefilter value: %d", o.prefilter)
+	}
+	if o.retries < 0 || o.retryDelay < 0 {
+		return o, errors.New("-retries and -retry-delay can't be negative")
+	}
+	if o.ioUring && !ioUringSupported {
+		return o, errors.New("-io-uring needs Linux and a build with -tags iouring")
+	}
+	return o, nil
+}
+
+func flagOptions() (tOptions, error) {
+	return newOptions(
+		withWalkers(dirWalkers),
+		withSequential(sequential),
+		withWorkers(workers, smallWorkers),
+		withPrefilter(prefilterSize),
+		withRetries(retries, retryDelay),
+		withIOUring(useIOUring),
+	)
+}
+
+// validateOptions checks every flag and the scan directories at once, so a
+// typo is reported before the scan starts instead of as a panic hours in
+func validateOptions(command string, args []string) error {
+	if err := setTheme(themeName); err != nil {
+		return err
+	}
+	if err := setMinConfidence(minConfidence); err != nil {
+		return err
+	}
+	if err := setHash(hashName); err != nil {
+		return err
+	}
+	if err := setContentFilter(contentTypes); err != nil {
+		return err
+	}
+	if mediumPercent < 0 || highPercent < mediumPercent || highPercent > 100 {
+		return fmt.Errorf("invalid -medium-percent and -high-percent values: %g and %g", mediumPercent, highPercent)
+	}
+	if alertPercent < 0 || alertPercent > 100 {
+		return fmt.Errorf("invalid -alert-percent value: %g", alertPercent)
+	}
+	if alertSize != "" {
+		limit, err := bytefmt.ToBytes(alertSize)
+		if err != nil {
+			return fmt.Errorf("invalid -alert-size value: %s", alertSize)
+		}
+		alertLimit = limit
+	}
+	if alertWebhook != "" && !alertEnabled() {
+		return errors.New("-alert-percent or -alert-size is required with -alert-webhook")
+	}
+	if minCopies < 2 {
+		return fmt.Errorf("invalid -min-copies value: %d", minCopies)
+	}
+	if outputFormat != outputText && outputFormat != outputJSON && outputFormat != outputCSV {
+		return fmt.Errorf("invalid -output value: %s", outputFormat)
+	}
+	if similarMin < 1 || similarMin > 100 {
+		return fmt.Errorf("invalid -similar-min value: %d", similarMin)
+	}
+	if linkStyle != linkRelative && linkStyle != linkAbsolute {
+		return fmt.Errorf("invalid -link-style value: %s", linkStyle)
+	}
+	if !validPlanAction(planAction) {
+		return fmt.Errorf("invalid -action value: %s", planAction)
+	}
+	if !validVMDisksMode(vmDisks) {
+		return fmt.Errorf("invalid -vm-disks value: %s", vmDisks)
+	}
+	if !validMediaServer(mediaServer) {
+		return fmt.Errorf("invalid -media-server value: %s", mediaServer)
+	}
+	if mediaServer != "" && mediaURL == "" {
+		return errors.New("-media-url is required with -media-server")
+	}
+	if !validTorrentClient(torrentClient) {
+		return fmt.Errorf("invalid -torrent-client value: %s", torrentClient)
+	}
+	if torrentClient != "" && torrentURL == "" {
+		return errors.New("-torrent-url is required with -torrent-client")
+	}
+	if digest && (smtpServer == "" || mailFrom == "" || mailTo == "") {
+		return errors.New("-smtp-server, -mail-from and -mail-to are required with -digest")
+	}
+	limit, err := bytefmt.ToBytes(recycleSize)
+	if err != nil {
+		return fmt.Errorf("invalid -recycle-size value: %s", recycleSize)
+	}
+	recycleLimit = limit
+	if targetSize != "" {
+		limit, err := bytefmt.ToBytes(targetSize)
+		if err != nil {
+			return fmt.Errorf("invalid -target-size value: %s", targetSize)
+		}
+		targetLimit = limit
+	}
+	if rpcRootList != "" && !rpcMode {
+		return errors.New("-rpc is required with -rpc-roots")
+	}
+	setRPCRoots(rpcRootList)
+	if rpcViewer && !rpcMode {
+		return errors.New("-rpc is required with -rpc-viewer")
+	}
+	if helperCommand != "" && helperCommand != "sudo" && helperCommand != "doas" {
+		return fmt.Errorf("invalid -helper value: %s, sudo or doas", helperCommand)
+	}
+	if historyDays < 1 {
+		return fmt.Errorf("invalid -history-days value: %d", historyDays)
+	}
+	if err := setRemaps(); err != nil {
+		return err
+	}
+	if err := setSnapshots(); err != nil {
+		return err
+	}
+	if command == "service" {
+		if err := validateService(args); err != nil || serviceAction != serviceInstall {
+			return err
+		}
+	}
+	if command == "cache" {
+		return validateCache(args)
+	}
+	if command == "history" {
+		if len(args) > 1 {
+			return errors.New("usage: dup-fu history [options] [scan-dir]")
+		}
+		return nil
+	}
+	if command == "apply" {
+		if len(args) != 1 {
+			return errors.New("usage: dup-fu apply [options] plan.json")
+		}
+		return nil
+	}
+	if command == "apply-decisions" {
+		if len(args) != 1 {
+			return errors.New("usage: dup-fu apply-decisions [options] decisions.csv")
+		}
+		return nil
+	}
+	if err := setScanDirs(args, rootsOnly(command)); err != nil {
+		return err
+	}
+	if crossOnly && len(extraRoots) == 0 {
+		return errors.New("-cross-only needs more than one scan root")
+	}
+	if err := setIncludes(); err != nil {
+		return err
+	}
+	if err := setExcludes(); err != nil {
+		return fmt.Errorf("invalid -exclude-regex value: %v", err)
+	}
+	if err := setKeepStrategy(); err != nil {
+		return err
+	}
+	setDownloadsPreset()
+	if restorePath != "" && !recycleEnabled() {
+		return errors.New("-recycle-dir is required with -restore")
+	}
+	return nil
+}
+
+// secretOptions are left out of the command lines dup-fu writes down, the
+// config file gives them to the runs started from those
+var secretOptions = map[string]bool{
+	"smtp-password":    true,
+	"torrent-password": true,
+	"media-token":      true,
+}
+
+// withoutSecrets drops the secret options and their values from args
+func withoutSecrets(args []string) []string {
+	result := make([]string, 0, len(args))
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if arg == "--" {
+			result = append(result, args[i:]...)
+			break
+		}
+		if !strings.HasPrefix(arg, "-") {
+			// the command, a directory or the value of another option
+			result = append(result, arg)
+			continue
+		}
+		name := strings.TrimLeft(arg, "-")
+		value := strings.Contains(name, "=")
+		if value {
+			name = name[:strings.Index(name, "=")]
+		}
+		if !secretOptions[name] {
+			result = append(result, arg)
+			continue
+		}
+		if !value {
+			// the value is the next argument
+			i++
+		}
+	}
+	return result
+}
+
+// setScanDirs reads the scan and target directories from the arguments,
+// all of them are roots with allRoots. Every scan root has to be an existing
+// directory.
+func setScanDirs(args []string, allRoots bool) error {
+	scanDir = "."
+	if len(args) > 0 {
+		scanDir = args[0]
+	}
+	targetDir = filepath.Join(scanDir, ".dup-fu")
+	if allRoots || targetOption != "" || crossOnly || len(args) > 2 {
+		// every directory is a root, a second one isn't the target-dir
+		if len(args) > 1 {
+			extraRoots = append(tRoots(args[1:]), extraRoots...)
+		}
+		if targetOption != "" {
+			targetDir = targetOption
+		}
+	} else if len(args) > 1 {
+		targetDir = args[1]
+	}
+	for _, root := range scanRoots() {
+		info, err := os.Stat(root)
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			return fmt.Errorf("not a directory: %s", root)
+		}
+	}
+	return checkRoots()
+}
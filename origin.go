@@ -0,0 +1,110 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// A busy server is scanned from a read-only snapshot (LVM, ZFS, Btrfs) of
+// its volume, so files written meanwhile don't race with the hashing:
+// -snapshot /data=/mnt/data-snap walks and reads the snapshot while the
+// files keep their /data path everywhere else, the roots, the list and the
+// actions. Before acting on a file or its original, it's checked to be
+// unchanged since the snapshot, same size and modification time.
+
+var (
+	snapshotOptions tPatterns
+	// live prefix to snapshot prefix
+	snapshots   []tRemap
+	changedLock sync.Mutex
+	// by duplicate path, changed since the snapshot with its original
+	changedSinceSnapshot = make(map[string]bool)
+)
+
+func setSnapshots() error {
+	var err error
+	if snapshots, err = parsePrefixes("-snapshot", snapshotOptions); err != nil {
+		return err
+	}
+	for _, s := range snapshots {
+		info, err := os.Stat(s.to)
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			return fmt.Errorf("not a directory: %s", s.to)
+		}
+	}
+	return nil
+}
+
+// snapshotPath is where the content of the live path is read
+func snapshotPath(path string) string {
+	if len(snapshots) == 0 {
+		return path
+	}
+	abs := absPath(path)
+	if mapped := mapPrefix(snapshots, abs); mapped != abs {
+		return mapped
+	}
+	return path
+}
+
+// walkSnapshot walks the snapshot of root when it has one, fn gets the live
+// paths
+func walkSnapshot(root string, options tOptions, fn filepath.WalkFunc) error {
+	snap := snapshotPath(root)
+	if snap == root {
+		return walkTree(root, options, fn)
+	}
+	return walkTree(snap, options, func(path string, info os.FileInfo, err error) error {
+		if rel, relErr := filepath.Rel(snap, path); relErr == nil {
+			path = filepath.Join(root, rel)
+		}
+		return fn(path, info, err)
+	})
+}
+
+// withoutChanged drops the duplicates that changed since the snapshot, or
+// whose original did
+func withoutChanged(list []string) []string {
+	if len(snapshots) == 0 {
+		return list
+	}
+	scanned := make(map[string]tFileData)
+	duplicatesLock.Lock()
+	for _, group := range duplicates {
+		for _, d := range group {
+			scanned[d.path] = d
+		}
+	}
+	duplicatesLock.Unlock()
+	originals := duplicateOriginals()
+	result := make([]string, 0, len(list))
+	for _, path := range list {
+		if unchangedSince(scanned[path]) && unchangedSince(scanned[originals[path]]) {
+			result = append(result, path)
+			continue
+		}
+		changedLock.Lock()
+		changedSinceSnapshot[path] = true
+		changedLock.Unlock()
+	}
+	return result
+}
+
+func unchangedSince(d tFileData) bool {
+	info, err := os.Lstat(d.path)
+	return err == nil && info.Size() == d.size && info.ModTime().UnixNano() == d.modified
+}
+
+func logChanged(o *tOutcome) {
+	changedLock.Lock()
+	count := len(changedSinceSnapshot)
+	changedLock.Unlock()
+	if count > 0 {
+		o.logf("Kept %d duplicate file(s) changed since the snapshot", count)
+	}
+}
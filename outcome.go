@@ -0,0 +1,172 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"sync/atomic"
+
+	"github.com/gdamore/tcell"
+	"github.com/rivo/tview"
+)
+
+// An action ends with its outcome: what it did, or would do on a dry run,
+// and its error. The commands and the line modes print it once their screen
+// is released, the TUI shows it and goes back to the list without the files
+// acted on, so an export, a delete and a move can follow in one session.
+
+type tOutcome struct {
+	// the dry run operations, printed to stdout
+	report string
+	// what was done, logged
+	summary []string
+	// files gone from their groups, deleted, moved or replaced by a link
+	gone []string
+	err  error
+}
+
+// acting is set while a TUI action runs, one at a time
+var acting int32
+
+// newOutcome starts the outcome of op on files
+func newOutcome(op string, files []string, err error) tOutcome {
+	o := tOutcome{err: err}
+	if dryRun {
+		var report strings.Builder
+		reportDryRun(&report, dryRunOperations(op, files))
+		o.report = report.String()
+	} else if op != opTag {
+		// a tagged duplicate stays one
+		o.gone = files
+	}
+	return o
+}
+
+func (o *tOutcome) logf(format string, a ...interface{}) {
+	o.summary = append(o.summary, formatter.Sprintf(format, a...))
+}
+
+// printOutcome ends an action outside the TUI, its error is left to the
+// caller
+func printOutcome(o tOutcome) error {
+	fmt.Print(o.report)
+	for _, line := range o.summary {
+		log.Println(line)
+	}
+	return o.err
+}
+
+// forgetFiles drops the files from their groups, the counts follow. The
+// original a group keeps, chosen with Ctrl+o or not, is never acted on, it
+// stays and leads the group.
+func forgetFiles(paths []string) {
+	if len(paths) == 0 {
+		return
+	}
+	gone := make(map[string]bool, len(paths))
+	for _, path := range paths {
+		gone[path] = true
+	}
+	duplicatesLock.Lock()
+	defer duplicatesLock.Unlock()
+	for hash, list := range duplicates {
+		if len(list) == 0 {
+			continue
+		}
+		original, _ := splitGroup(list)
+		kept := []tFileData{original}
+		for _, d := range list {
+			if d.path == original.path {
+				continue
+			}
+			if !gone[d.path] {
+				kept = append(kept, d)
+				continue
+			}
+			stats.duplicates--
+			stats.duplicateSize -= uint64(d.size)
+			stats.contentDuplicateSize[d.content] -= uint64(d.size)
+			if isCachePath(d.path) {
+				stats.cacheDuplicates--
+				stats.cacheDuplicateSize -= uint64(d.size)
+			}
+		}
+		if len(kept) < len(list) {
+			duplicates[hash] = kept
+		}
+	}
+}
+
+// refreshList rebuilds the Duplicates list without the groups left with no
+// duplicate
+func refreshList(right *tview.List) {
+	current := right.GetCurrentItem()
+	hashes := listHashes
+	right.Clear()
+	listIndex = make(map[string]int)
+	listHashes = nil
+	for _, hash := range hashes {
+		duplicatesLock.Lock()
+		list := append([]tFileData(nil), duplicates[hash]...)
+		duplicatesLock.Unlock()
+		if reportedGroup(list) {
+			setListItem(right, hash, list)
+		}
+	}
+	if current >= right.GetItemCount() {
+		current = right.GetItemCount() - 1
+	}
+	if current >= 0 {
+		right.SetCurrentItem(current)
+		showSelected(current)
+	}
+}
+
+// runAction runs an action off the UI goroutine, verb names it in the
+// status line meanwhile
+func runAction(app *tview.Application, right *tview.List, verb string, action func() tOutcome) {
+	if !atomic.CompareAndSwapInt32(&acting, 0, 1) {
+		setStatus("Another action is running")
+		return
+	}
+	setStatus(verb + "…")
+	go func() {
+		o := action()
+		forgetFiles(o.gone)
+		app.QueueUpdateDraw(func() {
+			atomic.StoreInt32(&acting, 0)
+			refreshList(right)
+			showOutcome(app, o)
+		})
+	}()
+}
+
+// showOutcome shows what the action did, the dry run operations in a
+// scrollable view
+func showOutcome(app *tview.Application, o tOutcome) {
+	text := strings.Join(o.summary, "\n")
+	if o.err != nil {
+		text = strings.TrimSpace(text + "\n\nFailed: " + o.err.Error())
+	}
+	status := "Done"
+	if len(o.summary) > 0 {
+		status = o.summary[0]
+	}
+	if o.err != nil {
+		status = "Failed: " + o.err.Error()
+	}
+	setStatus(status)
+	if o.report == "" {
+		if text != "" {
+			showModal(app, "outcome", text, []string{"OK"}, func(string) {})
+		}
+		return
+	}
+	view := newTextView("Dry run (Esc to close)", strings.TrimSpace(o.report+"\n"+text)).SetScrollable(true)
+	view.SetDoneFunc(func(tcell.Key) {
+		pages.RemovePage("outcome")
+		app.SetFocus(pages)
+	})
+	pages.AddPage("outcome", view, true, true)
+	app.SetFocus(view)
+}
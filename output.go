@@ -0,0 +1,117 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"strconv"
+	"time"
+)
+
+// `-output json` makes the report command print a JSON document instead of
+// text, Ctrl+j exports the same document to target-dir: every group with the
+// path, size, hash and modification time of its files, its confidence, and
+// the scan summary.
+// `-output csv` is a row per file for spreadsheets. The files kept are those
+// the actions leave: the original chosen with Ctrl+o, the allowed copies and
+// every file of a group below -min-confidence.
+
+const (
+	outputText = "text"
+	outputJSON = "json"
+	outputCSV  = "csv"
+)
+
+var outputFormat string
+
+type tReportFile struct {
+	Path     string    `json:"path"`
+	Size     int64     `json:"size"`
+	Modified time.Time `json:"modified"`
+	Original bool      `json:"original,omitempty"`
+	// left alone by the actions, the original included
+	Kept bool `json:"kept,omitempty"`
+}
+
+type tReportGroup struct {
+	Hash        string        `json:"hash"`
+	Confidence  string        `json:"confidence"`
+	Size        int64         `json:"size"`
+	Reclaimable uint64        `json:"reclaimable"`
+	Files       []tReportFile `json:"files"`
+}
+
+type tReportSummary struct {
+	Roots         []string `json:"roots"`
+	Finished      bool     `json:"finished"`
+	Scanned       uint32   `json:"scanned"`
+	Size          uint64   `json:"size"`
+	Skipped       uint32   `json:"skipped"`
+	Groups        int      `json:"groups"`
+	Duplicates    int      `json:"duplicates"`
+	DuplicateSize uint64   `json:"duplicateSize"`
+}
+
+type tReport struct {
+	Created time.Time      `json:"created"`
+	Summary tReportSummary `json:"summary"`
+	Groups  []tReportGroup `json:"groups"`
+}
+
+func makeReport() tReport {
+	snap := takeSnapshot(-1)
+	report := tReport{Created: time.Now(), Groups: make([]tReportGroup, 0, len(snap.groups))}
+	summary := &report.Summary
+	for _, root := range scanRoots() {
+		summary.Roots = append(summary.Roots, absPath(root))
+	}
+	summary.Finished = snap.finished
+	summary.Scanned, summary.Size, summary.Skipped = snap.stats.count, snap.stats.size, snap.stats.skipped
+	summary.Groups = len(snap.groups)
+	for _, g := range snap.groups {
+		group := tReportGroup{Hash: g.hash, Confidence: confidenceName(g.hash), Size: g.files[0].size, Reclaimable: g.reclaimable()}
+		original, _ := splitGroup(g.files)
+		removed := make(map[string]bool)
+		for _, d := range actedOn(g.hash, g.files) {
+			removed[d.path] = true
+		}
+		for _, d := range g.files {
+			group.Files = append(group.Files, tReportFile{absPath(d.path), d.size, time.Unix(0, d.modified), d.path == original.path, !removed[d.path]})
+		}
+		summary.Duplicates += len(g.files) - 1
+		summary.DuplicateSize += g.reclaimable()
+		report.Groups = append(report.Groups, group)
+	}
+	return report
+}
+
+func writeJSONReport(out io.Writer) error {
+	encoder := json.NewEncoder(out)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(makeReport())
+}
+
+var csvReportHeader = []string{"group", "hash", "confidence", "path", "size", "modified", "status"}
+
+// writeCSVReport writes a row per file, kept or duplicate as the actions see
+// it
+func writeCSVReport(out io.Writer) error {
+	w := csv.NewWriter(out)
+	if err := w.Write(csvReportHeader); err != nil {
+		return err
+	}
+	for i, group := range makeReport().Groups {
+		for _, f := range group.Files {
+			status := "duplicate"
+			if f.Kept {
+				status = "kept"
+			}
+			row := []string{strconv.Itoa(i + 1), group.Hash, group.Confidence, f.Path, strconv.FormatInt(f.Size, 10), f.Modified.Format(time.RFC3339), status}
+			if err := w.Write(row); err != nil {
+				return err
+			}
+		}
+	}
+	w.Flush()
+	return w.Error()
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"fmt"
+)
+
+// Files are grouped by size before anything is read: the first file of a
+// size waits until a second one shows up, a file still alone when the walk
+// ends can't have a duplicate and is never hashed, its head tells its
+// content kind.
+//
+// A size shared by exactly two files is settled by comparing the pair
+// directly: most same-size pairs differ in the first block, so neither file
+// is read to the end. A third file of the same size sends them all to
+// hashing, comparing every pair of a group costs more than hashing it.
+
+var (
+	comparePairs bool
+	// files held back by size until the walk ends, nil once the size is hashed
+	heldSizes = make(map[int64][]tFileData)
+	// numbers the keys of files settled without a full hash
+	settledCount int
+)
+
+// holdBySize returns the files to hash now, none while data waits for the
+// end of the walk
+func holdBySize(data tFileData, options tOptions) []tFileData {
+	held, seen := heldSizes[data.size]
+	if seen && held == nil {
+		return []tFileData{data}
+	}
+	// small files and VM disks are never compared nor refined
+	settled := !data.partial && !isSmallFile(data)
+	if len(held) == 0 || settled && (progressive || prefiltered(data, options) || comparePairs && len(held) < 2) {
+		heldSizes[data.size] = append(held, data)
+		return nil
+	}
+	heldSizes[data.size] = nil
+	return append(held, data)
+}
+
+// settleHeld skips the files left alone in their size, compares the held
+// pairs and refines or prefilters the larger groups, it runs once the walk
+// is done
+func settleHeld(options tOptions) {
+	for _, held := range heldSizes {
+		if len(held) > 1 && anyHashKnown(held) {
+			// a known hash only matches full hashes, the others are read whole
+			for _, d := range held {
+				sendToHashing(d, options)
+			}
+			continue
+		}
+		switch {
+		case len(held) == 1:
+			settleUnique(held[0])
+		case len(held) == 2 && comparePairs:
+			settlePair(held[0], held[1], options)
+		case len(held) > 1 && progressive:
+			refine(held)
+		case len(held) > 1:
+			prefilter(held, options)
+		}
+	}
+}
+
+func anyHashKnown(held []tFileData) bool {
+	for _, d := range held {
+		if hashKnown(d) {
+			return true
+		}
+	}
+	return false
+}
+
+// settleUnique groups a file alone in its size without hashing it, only its
+// head is read for -content
+func settleUnique(data tFileData) {
+	data.settled = settledKey("unique", data.size)
+	data.content = contentOf(data)
+	checksumChannel <- data
+}
+
+func settlePair(a, b tFileData, options tOptions) {
+	same, err := sameContent(a.path, b.path)
+	if err != nil {
+		// hashed one by one, the file that can't be read is skipped there
+		sendToHashing(a, options)
+		sendToHashing(b, options)
+		return
+	}
+	a.content, b.content = contentOf(a), contentOf(b)
+	a.settled = settledKey("compared", a.size)
+	b.settled = a.settled
+	if !same {
+		b.settled = settledKey("compared", b.size)
+	}
+	checksumChannel <- a
+	checksumChannel <- b
+}
+
+// settledKey is a unique group key, settled files never get a content hash
+func settledKey(kind string, size int64) string {
+	settledCount++
+	return fmt.Sprintf("%s:%d:%d", kind, size, settledCount)
+}
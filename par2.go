@@ -0,0 +1,99 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+const (
+	par2HeaderSize = 64
+	// larger packets are recovery slices, they are skipped without reading
+	par2MaxDescSize = 64 * 1024
+)
+
+var (
+	par2Magic    = []byte("PAR2\x00PKT")
+	par2FileDesc = []byte("PAR 2.0\x00FileDesc")
+	par2Volume   = regexp.MustCompile(`(?i)\.vol\d+[+-]\d+\.par2$`)
+	// data files protected by a par2 recovery set, mapped to the set's index file
+	parityBound = make(map[string]string)
+)
+
+func isParity(path string) bool {
+	return strings.ToLower(filepath.Ext(path)) == ".par2"
+}
+
+// visitParity binds the data files described by a par2 index file to it.
+// Volume files carry the same descriptions, the index file is enough.
+func visitParity(path string) {
+	if par2Volume.MatchString(path) {
+		return
+	}
+	names, err := par2FileNames(path)
+	if err != nil {
+		publishError(path, fmt.Errorf("couldn't read the par2 index, its data files aren't pointed out: %v", err))
+		return
+	}
+	dir := filepath.Dir(path)
+	for _, name := range names {
+		parityBound[filepath.Join(dir, filepath.FromSlash(name))] = path
+	}
+}
+
+// par2FileNames reads the names from the file description packets
+func par2FileNames(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	names := make([]string, 0)
+	header := make([]byte, par2HeaderSize)
+	for {
+		_, err := io.ReadFull(f, header)
+		if err == io.EOF {
+			return names, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if !bytes.Equal(header[:8], par2Magic) {
+			return nil, fmt.Errorf("%s: invalid par2 packet", path)
+		}
+		length := int64(binary.LittleEndian.Uint64(header[8:16]))
+		if length < par2HeaderSize || length%4 != 0 {
+			return nil, fmt.Errorf("%s: invalid par2 packet length", path)
+		}
+		bodySize := length - par2HeaderSize
+		if !bytes.Equal(header[48:64], par2FileDesc) || bodySize > par2MaxDescSize {
+			if _, err := f.Seek(bodySize, io.SeekCurrent); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		body := make([]byte, bodySize)
+		if _, err := io.ReadFull(f, body); err != nil {
+			return nil, err
+		}
+		// file id, md5, 16k md5 and length precede the null padded name
+		if len(body) > 56 {
+			names = append(names, string(bytes.TrimRight(body[56:], "\x00")))
+		}
+	}
+}
+
+func countParityBound(paths []string) int {
+	count := 0
+	for _, path := range paths {
+		if _, exist := parityBound[path]; exist {
+			count++
+		}
+	}
+	return count
+}
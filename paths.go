@@ -0,0 +1,104 @@
+package main
+
+import (
+	"path/filepath"
+
+	"github.com/rivo/tview"
+)
+
+const (
+	ellipsis         = "…"
+	defaultPathWidth = 80
+)
+
+var (
+	relativePaths bool
+	truncatePaths bool
+	// list item index of every duplicate group's hash, and the other way around
+	listIndex  = make(map[string]int)
+	listHashes []string
+	// full paths of the selected group, toggled with Ctrl+w
+	selectedView *tview.TextView
+	listColumn   *tview.Flex
+)
+
+// truncateMiddle shortens path to width runes, the file name is kept whole
+// as long as it fits
+func truncateMiddle(path string, width int) string {
+	runes := []rune(path)
+	if width < 5 || len(runes) <= width {
+		return path
+	}
+	tail := []rune(string(filepath.Separator) + filepath.Base(path))
+	head := width - 1 - len(tail)
+	if head < 1 {
+		return ellipsis + string(runes[len(runes)-(width-1):])
+	}
+	return string(runes[:head]) + ellipsis + string(tail)
+}
+
+// displayPath formats path for the Duplicates list
+func displayPath(path string, width int) string {
+	if relativePaths {
+		root := rootOf(path)
+		if rel, err := filepath.Rel(root, path); err == nil {
+			path = rel
+			if len(extraRoots) > 0 {
+				// the root tells the copies of different roots apart
+				path = filepath.Join(filepath.Base(absPath(root)), rel)
+			}
+		}
+	}
+	if truncatePaths {
+		if width <= 0 {
+			width = defaultPathWidth
+		}
+		path = truncateMiddle(path, width)
+	}
+	return path
+}
+
+// setListItem adds or updates the list item of a duplicate group
+func setListItem(right *tview.List, hash string, list []tFileData) {
+	_, _, width, _ := right.GetInnerRect()
+	more := ""
+	if len(list[1:]) > 1 {
+		more = formatter.Sprintf(" (+%d more)", len(list[1:])-1)
+	}
+	original := displayPath(list[0].path, width)
+	dupFiles := displayPath(list[1].path, width-len([]rune(more))) + more
+	if index, exist := listIndex[hash]; exist {
+		right.SetItemText(index, original, dupFiles)
+		return
+	}
+	listIndex[hash] = len(listHashes)
+	listHashes = append(listHashes, hash)
+	right.AddItem(original, dupFiles, rune(stats.duplicates+32), nil)
+}
+
+func showSelected(index int) {
+	if selectedView == nil || index < 0 || index >= len(listHashes) {
+		return
+	}
+	duplicatesLock.Lock()
+	hash := listHashes[index]
+	list := duplicates[hash]
+	duplicatesLock.Unlock()
+	text := groupInsights(list) + "\nConfidence: " + confidenceName(hash) + "\n\nOriginal: " + list[0].path
+	for _, dup := range list[1:] {
+		text += "\nDuplicate: " + dup.path
+	}
+	selectedView.SetText(text)
+}
+
+// toggleSelected shows or hides the wrapped full paths of the selected group
+func toggleSelected(right *tview.List) {
+	if selectedView != nil {
+		listColumn.RemoveItem(selectedView)
+		selectedView = nil
+		return
+	}
+	selectedView = newTextView("Selected", "").SetWrap(true)
+	listColumn.AddItem(selectedView, 0, 1, false)
+	showSelected(right.GetCurrentItem())
+}
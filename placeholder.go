@@ -0,0 +1,34 @@
+package main
+
+import "os"
+
+// Sync clients like OneDrive, Dropbox and iCloud Drive leave online-only
+// files as placeholders: the size is the real one but the content is on the
+// server, reading it downloads the file. They're skipped unless -hydrate is
+// set, a scan shouldn't download a whole cloud drive by surprise.
+
+var (
+	hydrate bool
+	// online-only files found by the walk, guarded by walkLock
+	placeholders int
+)
+
+// visitPlaceholder tells whether the file is left out as online-only
+func visitPlaceholder(info os.FileInfo) bool {
+	if !isPlaceholder(info) {
+		return false
+	}
+	placeholders++
+	return !hydrate
+}
+
+func reportPlaceholders() {
+	if placeholders == 0 {
+		return
+	}
+	if hydrate {
+		addNotice("Downloaded %d online-only file(s) to hash them", placeholders)
+	} else {
+		addNotice("Skipped %d online-only file(s), -hydrate downloads and hashes them", placeholders)
+	}
+}
@@ -0,0 +1,14 @@
+package main
+
+import (
+	"os"
+	"syscall"
+)
+
+// sfDataless flags a file whose content the file provider hasn't downloaded
+const sfDataless = 0x40000000
+
+func isPlaceholder(info os.FileInfo) bool {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	return ok && stat.Flags&sfDataless != 0
+}
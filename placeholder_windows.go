@@ -0,0 +1,19 @@
+package main
+
+import (
+	"os"
+	"syscall"
+)
+
+const (
+	fileAttributeOffline            = 0x1000
+	fileAttributeRecallOnOpen       = 0x40000
+	fileAttributeRecallOnDataAccess = 0x400000
+)
+
+// isPlaceholder checks the cloud files attributes, set on files whose
+// content is fetched when they're opened or read
+func isPlaceholder(info os.FileInfo) bool {
+	data, ok := info.Sys().(*syscall.Win32FileAttributeData)
+	return ok && data.FileAttributes&(fileAttributeOffline|fileAttributeRecallOnOpen|fileAttributeRecallOnDataAccess) != 0
+}
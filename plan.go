@@ -0,0 +1,224 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"time"
+)
+
+// A plan lists every operation a cleanup would do, so it can be reviewed
+// (or code reviewed) before `dup-fu apply` executes it.
+
+const (
+	planVersion = 1
+	opDelete    = "delete"
+	opMove      = "move"
+	opHardlink  = "hardlink"
+	opSymlink   = "symlink"
+	opReflink   = "reflink"
+	opTag       = "tag"
+)
+
+var planAction string
+
+type tOperation struct {
+	Op       string `json:"op"`
+	Path     string `json:"path"`
+	Original string `json:"original"`
+	Size     int64  `json:"size"`
+	Modified int64  `json:"modified"`
+	Hash     string `json:"hash"`
+	// low, medium or high, see groupConfidence
+	Confidence string `json:"confidence"`
+	// the file belongs to a par2 recovery set
+	Parity bool `json:"parity,omitempty"`
+}
+
+type tPlan struct {
+	Version    int          `json:"version"`
+	Created    int64        `json:"created"`
+	ScanDir    string       `json:"scanDir"`
+	Roots      []string     `json:"roots,omitempty"`
+	TargetDir  string       `json:"targetDir"`
+	Operations []tOperation `json:"operations"`
+}
+
+func validPlanAction(action string) bool {
+	return action == opDelete || action == opMove || action == opHardlink || action == opSymlink || action == opReflink || action == opTag
+}
+
+func makePlan() (tPlan, error) {
+	plan := tPlan{Version: planVersion, Created: time.Now().Unix(), ScanDir: absPath(scanDir), TargetDir: absPath(targetDir)}
+	for _, root := range extraRoots {
+		plan.Roots = append(plan.Roots, absPath(root))
+	}
+	list, _, err := withoutSeeding(listDuplicates())
+	if err != nil {
+		return plan, err
+	}
+	list = withoutMismatches(list)
+	kept := make(map[string]bool)
+	for _, path := range list {
+		kept[path] = true
+	}
+	for _, group := range duplicateGroups() {
+		original, removed := splitGroup(group.files)
+		for _, dup := range removed {
+			if !kept[dup.path] {
+				continue
+			}
+			_, parity := parityBound[dup.path]
+			plan.Operations = append(plan.Operations, tOperation{
+				Op:         planAction,
+				Path:       absPath(dup.path),
+				Original:   absPath(original.path),
+				Size:       dup.size,
+				Modified:   dup.modified,
+				Hash:       group.hash,
+				Confidence: confidenceName(group.hash),
+				Parity:     parity,
+			})
+		}
+	}
+	return plan, nil
+}
+
+// runPlan scans without the TUI and writes the plan to out
+func runPlan(out io.Writer, options tOptions) error {
+	go findDuplicates()
+	waitForScan(options)
+	plan, err := makePlan()
+	if err != nil {
+		return err
+	}
+	encoder := json.NewEncoder(out)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(plan)
+}
+
+// verifyOperation checks the files are still what the plan was made from
+func verifyOperation(op tOperation) error {
+	info, err := os.Lstat(op.Path)
+	if err != nil {
+		return err
+	}
+	if info.Size() != op.Size || info.ModTime().UnixNano() != op.Modified {
+		return errors.New("modified since the plan was made")
+	}
+	same, err := sameContent(op.Original, op.Path)
+	if err != nil {
+		return err
+	}
+	if !same {
+		return errors.New("content differs from the original")
+	}
+	return nil
+}
+
+// errNoJournal stops a plan, a move without its journal entry can't be
+// undone
+var errNoJournal = errors.New("the move isn't journaled")
+
+func applyOperation(op tOperation) error {
+	if dryRun {
+		return nil
+	}
+	switch op.Op {
+	case opDelete:
+		return deleteDuplicate(op.Path, op.Hash)
+	case opMove:
+		dir, err := ensureTargetDir()
+		if err != nil {
+			return err
+		}
+		target, err := moveToTarget(dir, op.Path)
+		if err != nil {
+			return err
+		}
+		if err := appendJournal(tJournalEntry{time.Now().Unix(), journalMove, op.Path, target, op.Size}); err != nil {
+			return fmt.Errorf("%w: %v", errNoJournal, err)
+		}
+		return nil
+	case opHardlink:
+		return hardlinkFile(op.Original, op.Path)
+	case opSymlink:
+		return symlinkFile(op.Original, op.Path)
+	case opReflink:
+		return reflinkFile(op.Original, op.Path)
+	case opTag:
+		return addFileTag(op.Path, duplicateTag)
+	}
+	return fmt.Errorf("unknown operation: %s", op.Op)
+}
+
+// runApply verifies and executes every operation of a plan file, operations
+// that fail verification are skipped
+func runApply(file string) error {
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	var plan tPlan
+	if err := json.NewDecoder(f).Decode(&plan); err != nil {
+		return err
+	}
+	if plan.Version != planVersion {
+		return fmt.Errorf("unsupported plan version: %d", plan.Version)
+	}
+	return applyPlan(plan)
+}
+
+// applyPlan locks the roots of the plan and applies it
+func applyPlan(plan tPlan) error {
+	remapPlan(&plan)
+	// moves and their journal go where the plan says
+	targetDir = plan.TargetDir
+	if err := lockRoots(append([]string{plan.ScanDir}, plan.Roots...)); err != nil {
+		return err
+	}
+	defer unlockRoots()
+	applied, skipped, failed := 0, 0, 0
+	done := make([]string, 0, len(plan.Operations))
+	originals := make(map[string]string)
+	var verified []tOperation
+	for _, op := range plan.Operations {
+		if err := verifyOperation(op); err != nil {
+			log.Printf("Skipped %s: %v", op.Path, err)
+			skipped++
+			continue
+		}
+		if err := applyOperation(op); errors.Is(err, errNoJournal) {
+			return err
+		} else if err != nil {
+			// reported, the other operations go on
+			actionFailed(op.Path, err)
+			failed++
+			continue
+		}
+		done = append(done, op.Path)
+		originals[op.Original] = op.Hash
+		verified = append(verified, op)
+		applied++
+	}
+	if dryRun {
+		reportDryRun(os.Stdout, verified)
+		log.Printf("Would apply %d operation(s), skip %d", applied, skipped)
+		return nil
+	}
+	if recycleEnabled() {
+		if err := pruneRecycle(); err != nil {
+			return err
+		}
+	}
+	log.Printf("Applied %d operation(s), skipped %d, failed %d", applied, skipped, failed)
+	tagOriginals(originals)
+	if err := afterMediaRemoved(done); err != nil {
+		log.Println("Media library refresh failed:", err)
+	}
+	return nil
+}
@@ -0,0 +1,45 @@
+package main
+
+// The prefilter hashes the head and the tail of large files sharing a size,
+// the way rmlint does: only the files whose ends match are read whole. The
+// held group is settled once the walk is done, like -progressive.
+
+// prefiltered tells whether the file is large enough for its ends to be
+// worth hashing separately
+func prefiltered(data tFileData, options tOptions) bool {
+	return options.prefilter > 0 && data.size > 2*options.prefilter*1024
+}
+
+func prefilter(held []tFileData, options tOptions) {
+	split := make(map[string][]tFileData)
+	var order []string
+	for _, d := range held {
+		var sum []byte
+		err := options.retry(func() error {
+			var err error
+			sum, err = partialChecksum(d.path, d.size, options.prefilter*1024)
+			return err
+		})
+		if err != nil {
+			hashFailed(d, err)
+			continue
+		}
+		if _, exist := split[string(sum)]; !exist {
+			order = append(order, string(sum))
+		}
+		split[string(sum)] = append(split[string(sum)], d)
+	}
+	for _, sum := range order {
+		part := split[sum]
+		if len(part) == 1 {
+			// its ends differ from every other file, it can't be a duplicate
+			part[0].settled = settledKey("prefilter", part[0].size)
+			part[0].content = contentOf(part[0])
+			checksumChannel <- part[0]
+			continue
+		}
+		for _, d := range part {
+			sendToHashing(d, options)
+		}
+	}
+}
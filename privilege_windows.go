@@ -0,0 +1,80 @@
+//go:build windows
+// +build windows
+
+package main
+
+import (
+	"errors"
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+// Administrators can read files their ACLs deny through the backup
+// privilege: it's enabled on the process token once and every file is opened
+// with backup semantics, which Windows honors only while the privilege is on.
+
+const (
+	fileFlagBackupSemantics = 0x02000000
+	sePrivilegeEnabled      = 0x2
+	errorNotAllAssigned     = 1300
+)
+
+var (
+	advapi32                  = syscall.NewLazyDLL("advapi32.dll")
+	procLookupPrivilegeValue  = advapi32.NewProc("LookupPrivilegeValueW")
+	procAdjustTokenPrivileges = advapi32.NewProc("AdjustTokenPrivileges")
+
+	errPrivilegeNotHeld = errors.New("SeBackupPrivilege not held")
+)
+
+// TOKEN_PRIVILEGES with a single LUID_AND_ATTRIBUTES
+type tTokenPrivileges struct {
+	count      uint32
+	lowPart    uint32
+	highPart   int32
+	attributes uint32
+}
+
+func enableBackupPrivilege() error {
+	process, err := syscall.GetCurrentProcess()
+	if err != nil {
+		return err
+	}
+	var token syscall.Token
+	if err := syscall.OpenProcessToken(process, syscall.TOKEN_ADJUST_PRIVILEGES|syscall.TOKEN_QUERY, &token); err != nil {
+		return err
+	}
+	defer token.Close()
+	name, err := syscall.UTF16PtrFromString("SeBackupPrivilege")
+	if err != nil {
+		return err
+	}
+	privileges := tTokenPrivileges{count: 1, attributes: sePrivilegeEnabled}
+	if r, _, err := procLookupPrivilegeValue.Call(0, uintptr(unsafe.Pointer(name)), uintptr(unsafe.Pointer(&privileges.lowPart))); r == 0 {
+		return err
+	}
+	r, _, err := procAdjustTokenPrivileges.Call(uintptr(token), 0, uintptr(unsafe.Pointer(&privileges)), 0, 0, 0)
+	if r == 0 {
+		return err
+	}
+	// the call succeeds for accounts without the privilege, only the last error tells
+	if errno, ok := err.(syscall.Errno); ok && errno == errorNotAllAssigned {
+		return errPrivilegeNotHeld
+	}
+	return nil
+}
+
+func openFile(path string) (*os.File, error) {
+	name, err := syscall.UTF16PtrFromString(snapshotPath(path))
+	if err != nil {
+		return nil, &os.PathError{Op: "open", Path: path, Err: err}
+	}
+	h, err := syscall.CreateFile(name, syscall.GENERIC_READ,
+		syscall.FILE_SHARE_READ|syscall.FILE_SHARE_WRITE|syscall.FILE_SHARE_DELETE,
+		nil, syscall.OPEN_EXISTING, syscall.FILE_ATTRIBUTE_NORMAL|fileFlagBackupSemantics, 0)
+	if err != nil {
+		return nil, &os.PathError{Op: "open", Path: path, Err: err}
+	}
+	return os.NewFile(uintptr(h), path), nil
+}
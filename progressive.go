@@ -0,0 +1,85 @@
+package main
+
+import (
+	"hash"
+	"io"
+)
+
+// Progressive hashing reads a group of same-size files refineBlockSize at a
+// time and splits it as soon as the prefixes diverge, so large files that
+// differ early are rejected after their first blocks. Files still grouped at
+// the end have read their whole content and get the regular hash.
+
+const refineBlockSize = 4 * 1024 * 1024
+
+var progressive bool
+
+type tCandidate struct {
+	data tFileData
+	hash hash.Hash
+}
+
+// readBlock reads the block at offset, opening the file every time keeps
+// large groups within the descriptor limit
+func readBlock(path string, offset int64, buf []byte) ([]byte, error) {
+	f, err := openFile(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	n, err := f.ReadAt(buf, offset)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	return buf[:n], nil
+}
+
+func refine(held []tFileData) {
+	group := make([]*tCandidate, 0, len(held))
+	for _, d := range held {
+		group = append(group, &tCandidate{d, newHash()})
+	}
+	size := held[0].size
+	buf := make([]byte, refineBlockSize)
+	groups := [][]*tCandidate{group}
+	for offset := int64(0); len(groups) > 0; offset += refineBlockSize {
+		var next [][]*tCandidate
+		for _, g := range groups {
+			// the running hash covers the whole prefix read so far
+			split := make(map[string][]*tCandidate)
+			var order []string
+			for _, c := range g {
+				block, err := readBlock(c.data.path, offset, buf)
+				if err != nil {
+					hashFailed(c.data, err)
+					continue
+				}
+				if offset == 0 {
+					c.data.content = sniffContent(block)
+				}
+				c.hash.Write(block)
+				prefix := string(c.hash.Sum(nil))
+				if _, exist := split[prefix]; !exist {
+					order = append(order, prefix)
+				}
+				split[prefix] = append(split[prefix], c)
+			}
+			for _, prefix := range order {
+				part := split[prefix]
+				if len(part) > 1 && offset+refineBlockSize < size {
+					next = append(next, part)
+					continue
+				}
+				for _, c := range part {
+					if len(part) > 1 {
+						c.data.hash = c.hash.Sum(nil)
+					} else {
+						c.data.settled = settledKey("prefix", size)
+					}
+					checksumChannel <- c.data
+				}
+			}
+		}
+		groups = next
+	}
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"bytes"
+	"path/filepath"
+	"sort"
+
+	"code.cloudfoundry.org/bytefmt"
+	"github.com/gdamore/tcell"
+	"github.com/rivo/tview"
+)
+
+const provenanceLimit = 50
+
+// tProvenance is a pair of directories the duplicates keep coming from,
+// usually a backup job or a copy gone wrong
+type tProvenance struct {
+	a, b  string
+	size  uint64
+	files int
+}
+
+// alignDirs climbs both directories while they share the same name, so
+// /photos/2019/jan and /backup/photos/2019/jan become / and /backup
+func alignDirs(a, b string) (string, string) {
+	for a != b && filepath.Base(a) == filepath.Base(b) && filepath.Dir(a) != a && filepath.Dir(b) != b {
+		a, b = filepath.Dir(a), filepath.Dir(b)
+	}
+	return a, b
+}
+
+func provenance() []tProvenance {
+	pairs := make(map[[2]string]*tProvenance)
+	for _, group := range duplicateGroups() {
+		original := filepath.Dir(group.files[0].path)
+		for _, dup := range group.files[1:] {
+			a, b := alignDirs(original, filepath.Dir(dup.path))
+			if a > b {
+				a, b = b, a
+			}
+			key := [2]string{a, b}
+			p, exist := pairs[key]
+			if !exist {
+				p = &tProvenance{a: a, b: b}
+				pairs[key] = p
+			}
+			p.size += uint64(dup.size)
+			p.files++
+		}
+	}
+	result := make([]tProvenance, 0, len(pairs))
+	for _, p := range pairs {
+		result = append(result, *p)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].size > result[j].size
+	})
+	return result
+}
+
+func formatProvenance() string {
+	var report bytes.Buffer
+	list := provenance()
+	if len(list) == 0 {
+		return "No duplicates found yet."
+	}
+	for i, p := range list {
+		if i == provenanceLimit {
+			report.WriteString(formatter.Sprintf("... and %d more\n", len(list)-provenanceLimit))
+			break
+		}
+		pair := p.a + "  <->  " + p.b
+		if p.a == p.b {
+			pair = "within " + p.a
+		}
+		report.WriteString(formatter.Sprintf("%8s  %s (%d files)\n", bytefmt.ByteSize(p.size), pair, p.files))
+	}
+	return report.String()
+}
+
+// showProvenance lists the directory pairs accounting for most duplicates
+func showProvenance(app *tview.Application) {
+	view := newTextView("Provenance (Esc to close)", formatProvenance()).SetScrollable(true)
+	view.SetDoneFunc(func(tcell.Key) {
+		pages.RemovePage("provenance")
+		app.SetFocus(pages)
+	})
+	pages.AddPage("provenance", view, true, true)
+	app.SetFocus(view)
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+const (
+	journalFile = "journal.jsonl"
+
+	journalMove   = "move"
+	journalExpire = "expire"
+)
+
+// Every file moved into targetDir is journaled, the retention policy only
+// ever removes journaled files.
+var (
+	targetDays  int
+	targetSize  string
+	targetLimit uint64
+)
+
+type tJournalEntry struct {
+	Time   int64  `json:"time"`
+	Action string `json:"action"`
+	Path   string `json:"path"`
+	Target string `json:"target"`
+	Size   int64  `json:"size"`
+}
+
+func appendJournal(entry tJournalEntry) error {
+	dir, err := ensureTargetDir()
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(filepath.Join(dir, journalFile), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	if err := json.NewEncoder(f).Encode(entry); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func readJournal() ([]tJournalEntry, error) {
+	entries := make([]tJournalEntry, 0)
+	f, err := os.Open(filepath.Join(targetDir, journalFile))
+	if os.IsNotExist(err) {
+		return entries, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var entry tJournalEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			return nil, err
+		}
+		entry.Path, entry.Target = remapPath(entry.Path), remapPath(entry.Target)
+		entries = append(entries, entry)
+	}
+	return entries, scanner.Err()
+}
+
+// quarantined returns the moved files still in targetDir, oldest first
+func quarantined() ([]tJournalEntry, error) {
+	entries, err := readJournal()
+	if err != nil {
+		return nil, err
+	}
+	live := make(map[string]tJournalEntry)
+	for _, entry := range entries {
+		switch entry.Action {
+		case journalMove:
+			live[entry.Target] = entry
+		case journalExpire:
+			delete(live, entry.Target)
+		}
+	}
+	result := make([]tJournalEntry, 0, len(live))
+	for target, entry := range live {
+		if _, err := os.Lstat(target); err == nil {
+			result = append(result, entry)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Time < result[j].Time
+	})
+	return result, nil
+}
+
+// applyRetention deletes quarantined files older than -target-days, then the
+// oldest ones until targetDir fits in -target-size
+func applyRetention() (int, error) {
+	if targetDays <= 0 && targetLimit == 0 {
+		return 0, nil
+	}
+	items, err := quarantined()
+	if err != nil {
+		return 0, err
+	}
+	var total uint64
+	for _, item := range items {
+		total += uint64(item.Size)
+	}
+	cutoff := time.Now().AddDate(0, 0, -targetDays).Unix()
+	count := 0
+	for _, item := range items {
+		expired := targetDays > 0 && item.Time < cutoff
+		if !expired && (targetLimit == 0 || total <= targetLimit) {
+			break
+		}
+		if err := os.Remove(item.Target); err != nil {
+			return count, err
+		}
+		total -= uint64(item.Size)
+		count++
+		err := appendJournal(tJournalEntry{time.Now().Unix(), journalExpire, item.Path, item.Target, item.Size})
+		if err != nil {
+			return count, err
+		}
+	}
+	return count, nil
+}
@@ -0,0 +1,258 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	recycleIndexFile = "index.jsonl"
+	recycleBlobsDir  = "blobs"
+)
+
+// Deleted duplicates can be restored from the recycle area, it keeps one blob
+// per content hash that can't collide, one per file otherwise, and a journal
+// of the deleted paths.
+var (
+	recycleDir   string
+	recycleDays  int
+	recycleSize  string
+	recycleLimit uint64
+	restorePath  string
+)
+
+type tRecycled struct {
+	Path    string `json:"path"`
+	Blob    string `json:"blob"`
+	Size    int64  `json:"size"`
+	Deleted int64  `json:"deleted"`
+}
+
+func recycleEnabled() bool {
+	return recycleDir != ""
+}
+
+// visitRecycleDir skips the recycle area, its blobs are copies of the kept
+// originals
+func visitRecycleDir(path string) error {
+	if recycleEnabled() && absPath(path) == absPath(recycleDir) {
+		return filepath.SkipDir
+	}
+	return nil
+}
+
+func readRecycleIndex() ([]tRecycled, error) {
+	records := make([]tRecycled, 0)
+	f, err := os.Open(filepath.Join(recycleDir, recycleIndexFile))
+	if os.IsNotExist(err) {
+		return records, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var record tRecycled
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			return nil, err
+		}
+		records = append(records, record)
+	}
+	return records, scanner.Err()
+}
+
+func writeRecycleIndex(records []tRecycled) error {
+	tmp := filepath.Join(recycleDir, recycleIndexFile+".tmp")
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	encoder := json.NewEncoder(f)
+	for _, record := range records {
+		if err := encoder.Encode(record); err != nil {
+			f.Close()
+			return err
+		}
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp, filepath.Join(recycleDir, recycleIndexFile))
+}
+
+func appendRecycleIndex(record tRecycled) error {
+	f, err := os.OpenFile(filepath.Join(recycleDir, recycleIndexFile), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	if err := json.NewEncoder(f).Encode(record); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := ioutil.TempFile(filepath.Dir(dst), ".dup-fu-")
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		os.Remove(out.Name())
+		return err
+	}
+	if err := out.Close(); err != nil {
+		os.Remove(out.Name())
+		return err
+	}
+	return os.Rename(out.Name(), dst)
+}
+
+// recycleBlob names the blob of a deleted file: a content hash of 128 bits or
+// more, with its algorithm, is shared by every copy of the content. A hash
+// that may collide (crc32, xxhash64) or a group key that isn't a content hash
+// gets a blob of its own, restoring it never gives back another content.
+func recycleBlob(hash string, size int64) string {
+	if strings.Contains(hash, ":") || groupConfidence(hash) < confidenceHigh {
+		return fmt.Sprintf("%s-%d-%d", strings.Replace(hash, ":", "-", -1), size, time.Now().UnixNano())
+	}
+	algorithm, ok := hashNameOf(newHash)
+	if !ok {
+		algorithm = fmt.Sprintf("%dbit", newHash().Size()*8)
+	}
+	return fmt.Sprintf("%s-%s-%d", algorithm, hash, size)
+}
+
+// recycle keeps the content of path in the recycle area before it's deleted
+func recycle(path, hash string) error {
+	info, err := os.Lstat(path)
+	if err != nil {
+		return err
+	}
+	abs := absPath(path)
+	blob := recycleBlob(hash, info.Size())
+	blobs := filepath.Join(recycleDir, recycleBlobsDir)
+	if err := os.MkdirAll(blobs, os.ModePerm); err != nil {
+		return err
+	}
+	blobPath := filepath.Join(blobs, blob)
+	if _, err := os.Stat(blobPath); os.IsNotExist(err) {
+		// a hard link costs nothing once the duplicate is unlinked
+		if err := os.Link(path, blobPath); err != nil {
+			if err := copyFile(path, blobPath); err != nil {
+				return err
+			}
+		}
+	}
+	return appendRecycleIndex(tRecycled{abs, blob, info.Size(), time.Now().Unix()})
+}
+
+// pruneRecycle drops records older than -recycle-days, then the oldest blobs
+// until the area fits in -recycle-size
+func pruneRecycle() error {
+	if _, err := os.Stat(recycleDir); os.IsNotExist(err) {
+		// nothing deleted yet
+		return nil
+	}
+	records, err := readRecycleIndex()
+	if err != nil {
+		return err
+	}
+	cutoff := time.Now().AddDate(0, 0, -recycleDays).Unix()
+	sort.SliceStable(records, func(i, j int) bool {
+		return records[i].Deleted > records[j].Deleted
+	})
+	kept := make([]tRecycled, 0, len(records))
+	blobs := make(map[string]bool)
+	var total uint64
+	for _, record := range records {
+		if recycleDays > 0 && record.Deleted < cutoff {
+			continue
+		}
+		if !blobs[record.Blob] {
+			if recycleLimit > 0 && total+uint64(record.Size) > recycleLimit {
+				continue
+			}
+			total += uint64(record.Size)
+			blobs[record.Blob] = true
+		}
+		kept = append(kept, record)
+	}
+	files, err := ioutil.ReadDir(filepath.Join(recycleDir, recycleBlobsDir))
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	for _, file := range files {
+		if !blobs[file.Name()] {
+			if err := os.Remove(filepath.Join(recycleDir, recycleBlobsDir, file.Name())); err != nil {
+				return err
+			}
+		}
+	}
+	sort.SliceStable(kept, func(i, j int) bool {
+		return kept[i].Deleted < kept[j].Deleted
+	})
+	return writeRecycleIndex(kept)
+}
+
+// restoreRecycled restores the latest deleted copy of every recycled file
+// under prefix, existing files are never overwritten
+func restoreRecycled(prefix string) (int, error) {
+	abs := absPath(prefix)
+	records, err := readRecycleIndex()
+	if err != nil {
+		return 0, err
+	}
+	count := 0
+	done := make(map[string]bool)
+	kept := make([]tRecycled, 0, len(records))
+	for i := len(records) - 1; i >= 0; i-- {
+		record := records[i]
+		if done[record.Path] || (record.Path != abs && !isWithin(record.Path, abs)) {
+			kept = append(kept, record)
+			continue
+		}
+		done[record.Path] = true
+		if _, err := os.Lstat(record.Path); err == nil {
+			kept = append(kept, record)
+			continue
+		}
+		if err := os.MkdirAll(filepath.Dir(record.Path), os.ModePerm); err != nil {
+			return count, err
+		}
+		if err := copyFile(filepath.Join(recycleDir, recycleBlobsDir, record.Blob), record.Path); err != nil {
+			return count, err
+		}
+		count++
+	}
+	sort.SliceStable(kept, func(i, j int) bool {
+		return kept[i].Deleted < kept[j].Deleted
+	})
+	return count, writeRecycleIndex(kept)
+}
+
+// duplicateHashes maps every duplicate path to its group hash
+func duplicateHashes() map[string]string {
+	result := make(map[string]string)
+	for hash, list := range copyDuplicates() {
+		for _, d := range list {
+			result[d.path] = hash
+		}
+	}
+	return result
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"sync/atomic"
+)
+
+// On copy-on-write filesystems (btrfs, XFS, APFS) a duplicate can be
+// rewritten as a clone of its original: the paths stay independent files, a
+// later write to either one copies the blocks it touches, but until then they
+// share every block on disk. The clone itself is platform code.
+
+var errCloneUnsupported = errors.New("the filesystem can't clone the original")
+
+// reflinkFile replaces path with a clone of original keeping the mode and
+// modification time of path, the clone is made next to path and renamed over
+// it like hardlinkFile
+func reflinkFile(original, path string) error {
+	info, err := os.Stat(path)
+	if err != nil || dryRun {
+		// whether the filesystem clones is only known by trying
+		return err
+	}
+	tmp := path + ".dup-fu-link"
+	if err := cloneFile(original, tmp, info.Mode().Perm()); err != nil {
+		return err
+	}
+	err = os.Chmod(tmp, info.Mode().Perm())
+	if err == nil {
+		err = os.Chtimes(tmp, info.ModTime(), info.ModTime())
+	}
+	if err == nil {
+		err = os.Rename(tmp, path)
+	}
+	if err != nil {
+		os.Remove(tmp)
+	}
+	return err
+}
+
+// cloneDuplicates rewrites every duplicate but the originals as a clone and
+// returns the cloned files and the number of copies the filesystem can't
+// clone
+func cloneDuplicates() ([]string, int, error) {
+	list := withoutMismatches(listDuplicates())
+	originals := duplicateOriginals()
+	cloned := make([]string, 0, len(list))
+	unsupported := 0
+	for _, path := range list {
+		err := reflinkFile(originals[path], path)
+		if err == errCloneUnsupported {
+			unsupported++
+			continue
+		}
+		if err != nil {
+			actionFailed(path, err)
+			continue
+		}
+		cloned = append(cloned, path)
+	}
+	return cloned, unsupported, nil
+}
+
+func reflinkDuplicates() tOutcome {
+	before := atomic.LoadInt64(&actionFailures)
+	cloned, unsupported, err := cloneDuplicates()
+	o := newOutcome(opReflink, cloned, err)
+	if !dryRun {
+		o.logf("Replaced %d duplicate file(s) with clones of their original", len(cloned))
+	}
+	if unsupported > 0 {
+		o.logf("Kept %d duplicate file(s) the filesystem can't clone", unsupported)
+	}
+	logMismatches(&o)
+	logFailures(&o, before)
+	tagOriginals(keptOriginals(cloned))
+	return o
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+// clonefileat(2), APFS only
+const (
+	sysClonefileat = 462
+	atFDCWD        = -2
+)
+
+// cloneFile clones src to dst, clonefileat creates dst with the metadata of
+// src, reflinkFile sets them back
+func cloneFile(src, dst string, perm os.FileMode) error {
+	srcPtr, err := syscall.BytePtrFromString(src)
+	if err != nil {
+		return err
+	}
+	dstPtr, err := syscall.BytePtrFromString(dst)
+	if err != nil {
+		return err
+	}
+	cwd := atFDCWD
+	_, _, errno := syscall.Syscall6(sysClonefileat, uintptr(cwd), uintptr(unsafe.Pointer(srcPtr)),
+		uintptr(cwd), uintptr(unsafe.Pointer(dstPtr)), 0, 0)
+	switch errno {
+	case 0:
+		return nil
+	case syscall.EXDEV, syscall.ENOTSUP:
+		return errCloneUnsupported
+	}
+	return &os.PathError{Op: "clonefileat", Path: dst, Err: errno}
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"os"
+	"syscall"
+)
+
+// FICLONE, supported by btrfs, XFS (reflink=1) and other copy-on-write
+// filesystems
+const ficlone = 0x40049409
+
+func cloneFile(src, dst string, perm os.FileMode) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, perm)
+	if err != nil {
+		return err
+	}
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, out.Fd(), ficlone, in.Fd())
+	out.Close()
+	if errno == 0 {
+		return nil
+	}
+	os.Remove(dst)
+	switch errno {
+	case syscall.EXDEV, syscall.EOPNOTSUPP, syscall.EINVAL, syscall.ENOTTY:
+		return errCloneUnsupported
+	}
+	return &os.PathError{Op: "ficlone", Path: dst, Err: errno}
+}
@@ -0,0 +1,11 @@
+//go:build !linux && !darwin
+// +build !linux,!darwin
+
+package main
+
+import "os"
+
+// only Linux and macOS clone files
+func cloneFile(src, dst string, perm os.FileMode) error {
+	return errCloneUnsupported
+}
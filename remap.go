@@ -0,0 +1,84 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// A volume remounted at another path or copied to a new disk keeps its
+// results: -remap /old=/new rewrites the paths under /old read from hash
+// lists, plans, decision sheets and the move journal, the files are then
+// verified at their new path like any other.
+
+type tRemap struct {
+	from, to string
+}
+
+var (
+	remapOptions tPatterns
+	remaps       []tRemap
+)
+
+func setRemaps() error {
+	var err error
+	remaps, err = parsePrefixes("-remap", remapOptions)
+	return err
+}
+
+// parsePrefixes reads from=to values, the longest prefix is tried first so
+// /data/photos=... wins over /data=...
+func parsePrefixes(name string, options []string) ([]tRemap, error) {
+	var list []tRemap
+	for _, option := range options {
+		i := strings.Index(option, "=")
+		if i <= 0 || i == len(option)-1 {
+			return nil, fmt.Errorf("invalid %s value, expected /old=/new: %s", name, option)
+		}
+		list = append(list, tRemap{filepath.Clean(option[:i]), filepath.Clean(option[i+1:])})
+	}
+	sortPrefixes(list)
+	return list, nil
+}
+
+func sortPrefixes(list []tRemap) {
+	sort.SliceStable(list, func(i, j int) bool {
+		return len(list[i].from) > len(list[j].from)
+	})
+}
+
+// mapPrefix moves path from the first matching prefix to its new one
+func mapPrefix(list []tRemap, path string) string {
+	for _, r := range list {
+		if path == r.from {
+			return r.to
+		}
+		if isWithin(path, r.from) {
+			return filepath.Join(r.to, strings.TrimPrefix(path, r.from))
+		}
+	}
+	return path
+}
+
+func remapPath(path string) string {
+	return mapPrefix(remaps, path)
+}
+
+// remapPlan rewrites the files of a plan or a decision sheet and the
+// directories it locks and moves to
+func remapPlan(plan *tPlan) {
+	if len(remaps) == 0 {
+		return
+	}
+	plan.ScanDir = remapPath(plan.ScanDir)
+	plan.TargetDir = remapPath(plan.TargetDir)
+	for i, root := range plan.Roots {
+		plan.Roots[i] = remapPath(root)
+	}
+	for i := range plan.Operations {
+		op := &plan.Operations[i]
+		op.Path = remapPath(op.Path)
+		op.Original = remapPath(op.Original)
+	}
+}
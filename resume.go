@@ -0,0 +1,357 @@
+package main
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// A scan checkpoints every minute in -cache-dir, under a name of its roots
+// so scans of other roots keep theirs: <roots>.json holds the command line,
+// without the secret options, the walk state and the files walked but not
+// grouped yet, <roots>.files every file grouped so far with its hash and
+// content kind. Once the scan finishes the checkpoint is removed, after a
+// crash, a power loss or Esc it's left behind and `dup-fu -resume` runs the
+// same scan again: the walk is quick and rebuilds what the directories tell,
+// every file grouped before is taken from the checkpoint instead of being
+// read. Without -cache-dir nothing is checkpointed.
+
+const (
+	checkpointDir      = "checkpoints"
+	checkpointInterval = time.Minute
+)
+
+type tCheckpoint struct {
+	Args    []string `json:"args"`
+	Dir     string   `json:"dir"`
+	Roots   []string `json:"roots"`
+	Started int64    `json:"started"`
+	Updated int64    `json:"updated"`
+	// the walk was done when it was written
+	Walked bool `json:"walked"`
+	// files grouped when it was written
+	Files uint32 `json:"files"`
+	// files walked and not grouped yet, hashed again by the resumed scan
+	Pending []string `json:"pending"`
+}
+
+// tCheckpointFile is a line of the grouped files
+type tCheckpointFile struct {
+	Path     string `json:"path"`
+	Size     int64  `json:"size"`
+	Modified int64  `json:"modified"`
+	Partial  bool   `json:"partial,omitempty"`
+	// empty for a file settled without a hash
+	Hash    string `json:"hash,omitempty"`
+	Content int    `json:"content"`
+}
+
+var (
+	resume         bool
+	checkpoint     tCheckpoint
+	checkpointLock sync.Mutex
+	// <roots>.json, empty when the scan isn't checkpointed
+	checkpointPath string
+	// the grouped files, flushed with the checkpoint
+	checkpointFiles  *os.File
+	checkpointWriter *bufio.Writer
+	// the scan is finished, the checkpoint is gone for good
+	checkpointDone bool
+	// the files the interrupted scan grouped, by path
+	resumedFiles = make(map[string]tCheckpointFile)
+	// the .files of the checkpoint resumed, read before the scan starts
+	resumeFrom string
+	// walked files not grouped yet, guarded by pendingLock
+	pendingPaths = make(map[string]bool)
+	pendingLock  sync.Mutex
+)
+
+// checkpointName names the checkpoint of the roots, the same whatever their
+// order
+func checkpointName(roots []string) string {
+	sorted := append([]string(nil), roots...)
+	sort.Strings(sorted)
+	sum := sha256.Sum256([]byte(strings.Join(sorted, "\x00")))
+	return hex.EncodeToString(sum[:8])
+}
+
+func absRoots(roots []string) []string {
+	result := make([]string, 0, len(roots))
+	for _, root := range roots {
+		result = append(result, absPath(root))
+	}
+	return result
+}
+
+// resumeArgs replaces `dup-fu -resume [-cache-dir dir] [dir...]` by the
+// command line of the interrupted scan of those roots, before the options
+// are parsed
+func resumeArgs() error {
+	found := false
+	for _, arg := range os.Args[1:] {
+		if arg == "-resume" || arg == "--resume" {
+			found = true
+		}
+	}
+	if !found {
+		return nil
+	}
+	dir := defaultCacheDir()
+	var roots []string
+	args := os.Args[1:]
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch {
+		case arg == "-resume" || arg == "--resume":
+		case arg == "-cache-dir" || arg == "--cache-dir":
+			if i++; i == len(args) {
+				return errors.New("-cache-dir needs a directory")
+			}
+			dir = args[i]
+		case strings.HasPrefix(arg, "-cache-dir=") || strings.HasPrefix(arg, "--cache-dir="):
+			dir = arg[strings.Index(arg, "=")+1:]
+		case strings.HasPrefix(arg, "-"):
+			return errors.New("-resume takes the options of the interrupted scan, only -cache-dir and its directories")
+		default:
+			roots = append(roots, arg)
+		}
+	}
+	if dir == "" {
+		return errors.New("-resume needs -cache-dir, the interrupted scan kept its checkpoint there")
+	}
+	path, saved, err := findCheckpoint(filepath.Join(dir, checkpointDir), absRoots(roots))
+	if err != nil {
+		return err
+	}
+	if err := os.Chdir(saved.Dir); err != nil {
+		return err
+	}
+	workDir = saved.Dir
+	log.Printf("Resuming the scan of %s interrupted %s, %d file(s) were grouped and %d waiting",
+		strings.Join(saved.Roots, ", "), time.Unix(saved.Updated, 0).Format(time.RFC822), saved.Files, len(saved.Pending))
+	os.Args = append([]string{os.Args[0]}, saved.Args...)
+	resumeFrom = strings.TrimSuffix(path, ".json") + ".files"
+	return nil
+}
+
+// findCheckpoint returns the one checkpoint of a scan of every root
+func findCheckpoint(dir string, roots []string) (string, tCheckpoint, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return "", tCheckpoint{}, err
+	}
+	var found []string
+	var saved []tCheckpoint
+	for _, path := range paths {
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return "", tCheckpoint{}, err
+		}
+		var c tCheckpoint
+		if err := json.Unmarshal(data, &c); err != nil {
+			return "", tCheckpoint{}, fmt.Errorf("%s: %v", path, err)
+		}
+		if containsAll(c.Roots, roots) {
+			found = append(found, path)
+			saved = append(saved, c)
+		}
+	}
+	switch len(found) {
+	case 0:
+		return "", tCheckpoint{}, errors.New("no interrupted scan to resume")
+	case 1:
+		return found[0], saved[0], nil
+	}
+	names := make([]string, 0, len(saved))
+	for _, c := range saved {
+		names = append(names, strings.Join(c.Roots, " "))
+	}
+	return "", tCheckpoint{}, fmt.Errorf("several interrupted scans, name the directories of one: dup-fu -resume %s", strings.Join(names, " | "))
+}
+
+func containsAll(list, wanted []string) bool {
+	for _, w := range wanted {
+		found := false
+		for _, item := range list {
+			found = found || item == w
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// loadResumed reads the files grouped by the interrupted scan
+func loadResumed() error {
+	if resumeFrom == "" {
+		return nil
+	}
+	f, err := os.Open(resumeFrom)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	lines := bufio.NewScanner(f)
+	for lines.Scan() {
+		var file tCheckpointFile
+		if err := json.Unmarshal(lines.Bytes(), &file); err != nil {
+			// the last line of a crash may be cut
+			continue
+		}
+		resumedFiles[file.Path] = file
+	}
+	return lines.Err()
+}
+
+// resumedHash returns the hash and the content kind the interrupted scan
+// found for the file, unchanged since
+func resumedHash(data tFileData) ([]byte, int, bool) {
+	file, exist := resumedFiles[data.path]
+	if !exist || file.Hash == "" || file.Size != data.size || file.Modified != data.modified || file.Partial != data.partial {
+		return nil, contentUnknown, false
+	}
+	sum, err := hex.DecodeString(file.Hash)
+	if err != nil {
+		return nil, contentUnknown, false
+	}
+	return sum, file.Content, true
+}
+
+// startCheckpoints records the scan and saves its progress until it
+// finishes
+func startCheckpoints() {
+	if cacheDir == "" {
+		return
+	}
+	dir, err := os.Getwd()
+	if err != nil {
+		addNotice("couldn't checkpoint the scan: %v", err)
+		return
+	}
+	roots := absRoots(scanRoots())
+	path := filepath.Join(cacheDir, checkpointDir, checkpointName(roots))
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		addNotice("couldn't checkpoint the scan: %v", err)
+		return
+	}
+	// the files read back by loadResumed are grouped and written again
+	files, err := os.OpenFile(path+".files", os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		addNotice("couldn't checkpoint the scan: %v", err)
+		return
+	}
+	checkpointLock.Lock()
+	checkpointPath = path + ".json"
+	checkpointFiles, checkpointWriter = files, bufio.NewWriter(files)
+	checkpoint = tCheckpoint{Args: withoutSecrets(os.Args[1:]), Dir: dir, Roots: roots, Started: time.Now().Unix()}
+	checkpointLock.Unlock()
+	if err := saveCheckpoint(); err != nil {
+		addNotice("couldn't checkpoint the scan: %v", err)
+		return
+	}
+	go func() {
+		for range time.Tick(checkpointInterval) {
+			if err := saveCheckpoint(); err != nil {
+				addNotice("couldn't checkpoint the scan: %v", err)
+			}
+		}
+	}()
+}
+
+// checkpointFile adds a grouped file to the checkpoint
+func checkpointFile(d tFileData) {
+	file := tCheckpointFile{Path: d.path, Size: d.size, Modified: d.modified, Partial: d.partial, Content: d.content}
+	if d.settled == "" {
+		file.Hash = hex.EncodeToString(d.hash)
+	}
+	data, err := json.Marshal(file)
+	if err != nil {
+		return
+	}
+	checkpointLock.Lock()
+	defer checkpointLock.Unlock()
+	if checkpointWriter != nil && !checkpointDone {
+		checkpointWriter.Write(append(data, '\n'))
+	}
+}
+
+// addPending records a walked file until it's grouped or fails
+func addPending(path string, walked bool) {
+	pendingLock.Lock()
+	if walked {
+		pendingPaths[path] = true
+	} else {
+		delete(pendingPaths, path)
+	}
+	pendingLock.Unlock()
+}
+
+func listPending() []string {
+	pendingLock.Lock()
+	defer pendingLock.Unlock()
+	result := make([]string, 0, len(pendingPaths))
+	for path := range pendingPaths {
+		result = append(result, path)
+	}
+	sort.Strings(result)
+	return result
+}
+
+// saveCheckpoint flushes the grouped files and the hashes so far and dates
+// the checkpoint
+func saveCheckpoint() error {
+	checkpointLock.Lock()
+	defer checkpointLock.Unlock()
+	if checkpointDone || checkpointPath == "" {
+		return nil
+	}
+	if err := checkpointWriter.Flush(); err != nil {
+		return err
+	}
+	if err := saveHashStore(); err != nil {
+		return err
+	}
+	duplicatesLock.Lock()
+	checkpoint.Files = stats.count
+	checkpoint.Walked = stats.complted
+	duplicatesLock.Unlock()
+	checkpoint.Pending = listPending()
+	checkpoint.Updated = time.Now().Unix()
+	data, err := json.Marshal(checkpoint)
+	if err != nil {
+		return err
+	}
+	tmp := checkpointPath + ".tmp"
+	if err := ioutil.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, checkpointPath)
+}
+
+// finishCheckpoints removes the checkpoint of a finished scan
+func finishCheckpoints() {
+	checkpointLock.Lock()
+	defer checkpointLock.Unlock()
+	checkpointDone = true
+	if checkpointPath == "" {
+		return
+	}
+	checkpointFiles.Close()
+	os.Remove(checkpointPath)
+	os.Remove(strings.TrimSuffix(checkpointPath, ".json") + ".files")
+}
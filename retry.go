@@ -0,0 +1,32 @@
+package main
+
+import (
+	"errors"
+	"syscall"
+	"time"
+)
+
+// Network shares return EIO or time out now and then, a file is read again
+// a few times, waiting longer after every attempt, before it's an error.
+
+func isTransient(err error) bool {
+	var timeout interface{ Timeout() bool }
+	if errors.As(err, &timeout) && timeout.Timeout() {
+		return true
+	}
+	return errors.Is(err, syscall.EIO) || errors.Is(err, syscall.ETIMEDOUT) || errors.Is(err, syscall.EAGAIN)
+}
+
+// retry runs read again after transient errors, waiting the retry delay
+// and twice as long after every attempt
+func (options tOptions) retry(read func() error) error {
+	delay := options.retryDelay
+	for attempt := 0; ; attempt++ {
+		err := read()
+		if err == nil || attempt >= options.retries || !isTransient(err) {
+			return err
+		}
+		time.Sleep(delay)
+		delay *= 2
+	}
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"html/template"
+	"io"
+	"time"
+
+	"code.cloudfoundry.org/bytefmt"
+)
+
+// The review command writes the photo and video groups as a gallery page:
+// a thumbnail per group and a checkbox per file, the copies checked for
+// removal. The page saves the selection as a decisions file, which
+// apply-decisions verifies and applies.
+//
+//	dup-fu review /photos > review.html
+//	dup-fu apply-decisions -action move -target /photos-duplicates decisions.csv
+
+type tReviewFile struct {
+	Path     string
+	Size     int64
+	Modified int64
+	Date     string
+	Remove   bool
+}
+
+type tReviewGroup struct {
+	Number int
+	Hash   string
+	Size   string
+	Kind   string
+	Thumb  template.URL
+	Files  []tReviewFile
+}
+
+func reviewGroups() ([]tReviewGroup, error) {
+	removed, err := removedByDefault()
+	if err != nil {
+		return nil, err
+	}
+	var groups []tReviewGroup
+	for _, g := range duplicateGroups() {
+		kind := g.files[0].content
+		if kind != contentImage && kind != contentVideo {
+			continue
+		}
+		group := tReviewGroup{Number: len(groups) + 1, Hash: g.hash, Size: bytefmt.ByteSize(uint64(g.files[0].size)), Kind: contentNames[kind]}
+		group.Thumb = thumbnailURL(g.hash, g.files[0])
+		for _, d := range g.files {
+			date := time.Unix(0, d.modified).Format("2006-01-02 15:04")
+			group.Files = append(group.Files, tReviewFile{absPath(d.path), d.size, d.modified, date, removed[d.path]})
+		}
+		groups = append(groups, group)
+	}
+	return groups, nil
+}
+
+func runReview(out io.Writer, options tOptions) error {
+	built := buildThumbnails(subscribe(eventGroupUpdated, eventPhaseChanged))
+	go findDuplicates()
+	waitForScan(options)
+	<-built
+	groups, err := reviewGroups()
+	if err != nil {
+		return err
+	}
+	return reviewPage.Execute(out, struct {
+		Roots  string
+		Groups []tReviewGroup
+	}{rootsName(), groups})
+}
+
+var reviewPage = template.Must(template.New("review").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>dup-fu review: {{.Roots}}</title>
+<style>
+body { font-family: sans-serif; margin: 1em 2em; }
+.group { display: flex; gap: 1em; align-items: flex-start; border-top: 1px solid #ccc; padding: 1em 0; }
+.group.all { background: #fdd; }
+.thumb { width: 160px; height: 160px; display: flex; align-items: center; justify-content: center; background: #eee; color: #777; }
+.thumb img { max-width: 100%; max-height: 100%; }
+label { display: block; margin: .2em 0; }
+.date { color: #777; margin-left: .5em; }
+#save { position: sticky; top: 0; padding: .5em 1em; font-size: 1em; }
+</style>
+</head>
+<body>
+<h1>Duplicate photos and videos in {{.Roots}}</h1>
+<p>Checked files are removed, the others kept. Save the selection and run
+<code>dup-fu apply-decisions decisions.csv</code>, every file is verified first.</p>
+<button id="save" onclick="save()">Save decisions.csv</button>
+{{range .Groups}}
+<div class="group">
+  <div class="thumb">{{if .Thumb}}<img src="{{.Thumb}}" alt="">{{else}}{{.Kind}}{{end}}</div>
+  <div>
+    <strong>{{.Size}}</strong>
+    {{- $group := .}}
+    {{range .Files}}
+    <label><input type="checkbox" data-group="{{$group.Number}}" data-hash="{{$group.Hash}}" data-path="{{.Path}}" data-size="{{.Size}}" data-modified="{{.Modified}}"{{if .Remove}} checked{{end}} onchange="mark(this)">
+      {{.Path}}<span class="date">{{.Date}}</span></label>
+    {{end}}
+  </div>
+</div>
+{{else}}
+<p>No duplicate photos or videos.</p>
+{{end}}
+<script>
+function boxes(group) {
+  return document.querySelectorAll('input[data-group="' + group + '"]');
+}
+
+// a group with every file checked would be lost whole
+function mark(box) {
+  var all = Array.prototype.every.call(boxes(box.dataset.group), function (b) { return b.checked; });
+  box.closest(".group").classList.toggle("all", all);
+  return !all;
+}
+
+function field(s) {
+  return /[",\n]/.test(s) ? '"' + s.replace(/"/g, '""') + '"' : s;
+}
+
+function save() {
+  var rows = ["group,decision,path,size,modified,hash"];
+  var boxList = document.querySelectorAll("input[type=checkbox]");
+  for (var i = 0; i < boxList.length; i++) {
+    var box = boxList[i], d = box.dataset;
+    if (!mark(box)) {
+      alert("Keep at least one file of every group.");
+      box.scrollIntoView();
+      return;
+    }
+    rows.push([d.group, box.checked ? "remove" : "keep", field(d.path), d.size, d.modified, d.hash].join(","));
+  }
+  var link = document.createElement("a");
+  link.href = URL.createObjectURL(new Blob([rows.join("\n") + "\n"], {type: "text/csv"}));
+  link.download = "decisions.csv";
+  link.click();
+}
+</script>
+</body>
+</html>
+`))
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"syscall"
+)
+
+// isRotational reads the rotational flag of the block device of root, or of
+// the disk of a partition, and tells whether it was found. Virtual devices
+// (btrfs, network filesystems) have none.
+func isRotational(root string) (bool, bool) {
+	info, err := os.Stat(root)
+	if err != nil {
+		return false, false
+	}
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return false, false
+	}
+	dev := uint64(st.Dev)
+	major := (dev>>8)&0xfff | (dev>>32)&^0xfff
+	minor := dev&0xff | (dev>>12)&^0xff
+	block := fmt.Sprintf("/sys/dev/block/%d:%d", major, minor)
+	for _, path := range []string{block + "/queue/rotational", block + "/../queue/rotational"} {
+		if data, err := ioutil.ReadFile(path); err == nil {
+			return strings.TrimSpace(string(data)) == "1", true
+		}
+	}
+	return false, false
+}
@@ -0,0 +1,9 @@
+//go:build !linux
+// +build !linux
+
+package main
+
+// isRotational is only known on Linux, other systems get a worker per CPU
+func isRotational(root string) (bool, bool) {
+	return false, false
+}
@@ -0,0 +1,311 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// JSON-RPC 2.0 over stdio, one message per line, for front-ends embedding dup-fu
+
+const (
+	rpcParseError     = -32700
+	rpcInvalidRequest = -32600
+	rpcMethodNotFound = -32601
+	rpcInvalidParams  = -32602
+	rpcServerError    = -32000
+)
+
+var (
+	rpcMode     bool
+	rpcRootList string
+	// rpcRootList parsed by setRPCRoots, empty permits every directory
+	rpcRoots []string
+)
+
+type tRPCRequest struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+}
+
+type tRPCError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+type tRPCMessage struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method,omitempty"`
+	Params  interface{}     `json:"params,omitempty"`
+	Result  interface{}     `json:"result,omitempty"`
+	Error   *tRPCError      `json:"error,omitempty"`
+}
+
+type tStatsResult struct {
+	Seconds       uint64 `json:"seconds"`
+	Scanned       uint32 `json:"scanned"`
+	Skipped       uint32 `json:"skipped"`
+	Size          uint64 `json:"size"`
+	Duplicates    uint32 `json:"duplicates"`
+	DuplicateSize uint64 `json:"duplicateSize"`
+	Finished      bool   `json:"finished"`
+}
+
+type tErrorResult struct {
+	Path  string `json:"path"`
+	Error string `json:"error"`
+}
+
+type tFileResult struct {
+	Path     string `json:"path"`
+	Size     int64  `json:"size"`
+	Modified int64  `json:"modified"`
+}
+
+type tGroupResult struct {
+	Hash       string        `json:"hash"`
+	Confidence string        `json:"confidence"`
+	Files      []tFileResult `json:"files"`
+}
+
+type tActResult struct {
+	Files      []string `json:"files"`
+	Seeding    int      `json:"seeding"`
+	Mismatched int      `json:"mismatched"`
+	// nothing was touched, Files would have been
+	DryRun bool `json:"dryRun,omitempty"`
+}
+
+type tRPCServer struct {
+	out     *json.Encoder
+	outLock sync.Mutex
+	started time.Time
+	// the scan method reads files with them
+	options tOptions
+}
+
+func (s *tRPCServer) send(msg tRPCMessage) {
+	msg.JSONRPC = "2.0"
+	s.outLock.Lock()
+	defer s.outLock.Unlock()
+	s.out.Encode(msg)
+}
+
+func (s *tRPCServer) notify(method string, params interface{}) {
+	s.send(tRPCMessage{Method: method, Params: params})
+}
+
+func (s *tRPCServer) stats() tStatsResult {
+	snap := takeSnapshot(0)
+	return tStatsResult{
+		Seconds:       uint64(time.Since(s.started).Seconds()),
+		Scanned:       snap.stats.count,
+		Skipped:       snap.stats.skipped,
+		Size:          snap.stats.size,
+		Duplicates:    snap.stats.duplicates,
+		DuplicateSize: snap.stats.duplicateSize,
+		Finished:      snap.finished,
+	}
+}
+
+// progress notifies the front-end every second until the scan is finished,
+// and of every error, those of the actions too
+func (s *tRPCServer) progress(events <-chan tEvent) {
+	tick := time.Tick(time.Second)
+	for {
+		select {
+		case e := <-events:
+			if e.kind == eventError {
+				s.notify("error", tErrorResult{e.path, e.err.Error()})
+			} else if e.phase == phaseFinished {
+				s.notify("finished", s.stats())
+				// a nil channel is never ready
+				tick = nil
+			}
+		case <-tick:
+			s.notify("progress", s.stats())
+		}
+	}
+}
+
+func setRPCRoots(list string) {
+	if list == "" {
+		return
+	}
+	for _, root := range strings.Split(list, ",") {
+		rpcRoots = append(rpcRoots, absPath(strings.TrimSpace(root)))
+	}
+}
+
+// rpcPermitted tells whether a front-end may scan or move files to dir
+func rpcPermitted(dir string) bool {
+	if len(rpcRoots) == 0 {
+		return true
+	}
+	abs := absPath(dir)
+	for _, root := range rpcRoots {
+		if abs == root || isWithin(abs, root) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *tRPCServer) scan(params json.RawMessage) (interface{}, *tRPCError) {
+	if !s.started.IsZero() {
+		return nil, &tRPCError{rpcServerError, "scan already started"}
+	}
+	var p struct {
+		Dir    string `json:"dir"`
+		Target string `json:"target"`
+	}
+	if len(params) > 0 {
+		if err := json.Unmarshal(params, &p); err != nil {
+			return nil, &tRPCError{rpcInvalidParams, err.Error()}
+		}
+	}
+	dir, target := scanDir, targetDir
+	if p.Dir != "" {
+		dir = p.Dir
+		target = filepath.Join(dir, ".dup-fu")
+	}
+	if p.Target != "" {
+		target = p.Target
+	}
+	if !rpcPermitted(dir) || !rpcPermitted(target) {
+		return nil, &tRPCError{rpcInvalidParams, "directory not in -rpc-roots"}
+	}
+	scanDir, targetDir = dir, target
+	if err := lockRoots(scanRoots()); err != nil {
+		return nil, &tRPCError{rpcServerError, err.Error()}
+	}
+	s.started = time.Now()
+	events := subscribe(eventPhaseChanged, eventError)
+	go findDuplicates()
+	go scan(s.options)
+	go s.progress(events)
+	return s.stats(), nil
+}
+
+func (s *tRPCServer) groups() (interface{}, *tRPCError) {
+	if !scanFinished() {
+		return nil, &tRPCError{rpcServerError, "scan not finished"}
+	}
+	result := make([]tGroupResult, 0)
+	for _, group := range duplicateGroups() {
+		files := make([]tFileResult, 0, len(group.files))
+		for _, f := range group.files {
+			files = append(files, tFileResult{f.path, f.size, f.modified})
+		}
+		result = append(result, tGroupResult{group.hash, confidenceName(group.hash), files})
+	}
+	return result, nil
+}
+
+func (s *tRPCServer) act(params json.RawMessage) (interface{}, *tRPCError) {
+	if !scanFinished() {
+		return nil, &tRPCError{rpcServerError, "scan not finished"}
+	}
+	var p struct {
+		Action string `json:"action"`
+		// who asked, as named by the front-end, for the audit log
+		User string `json:"user"`
+	}
+	if err := json.Unmarshal(params, &p); err != nil {
+		return nil, &tRPCError{rpcInvalidParams, err.Error()}
+	}
+	if rpcViewer {
+		return nil, auditAct(p.User, p.Action, 0, &tRPCError{rpcServerError, "actions are not permitted to viewers"})
+	}
+	var files []string
+	var seeding int
+	var err error
+	switch p.Action {
+	case "delete":
+		files, seeding, err = removeDuplicates()
+	case "move":
+		files, seeding, err = relocateDuplicates()
+	case "hardlink":
+		files, _, err = linkDuplicates()
+	case "symlink":
+		files, err = symlinkCopies()
+	case "reflink":
+		files, _, err = cloneDuplicates()
+	case "tag":
+		files, err = tagCopies()
+	default:
+		return nil, auditAct(p.User, p.Action, 0, &tRPCError{rpcInvalidParams, "unknown action: " + p.Action})
+	}
+	tagOriginals(keptOriginals(files))
+	if !dryRun && p.Action != "tag" {
+		// the next groups and act leave out the files acted on, a tagged
+		// duplicate stays one
+		forgetFiles(files)
+	}
+	if err := afterMediaRemoved(files); err != nil {
+		log.Println("Media library refresh failed:", err)
+	}
+	if err != nil {
+		return nil, auditAct(p.User, p.Action, len(files), &tRPCError{rpcServerError, err.Error()})
+	}
+	if rpcErr := auditAct(p.User, p.Action, len(files), nil); rpcErr != nil {
+		return nil, rpcErr
+	}
+	return tActResult{files, seeding, mismatches(), dryRun}, nil
+}
+
+func (s *tRPCServer) handle(req tRPCRequest) {
+	var result interface{}
+	var rpcErr *tRPCError
+	switch req.Method {
+	case "scan":
+		result, rpcErr = s.scan(req.Params)
+	case "stats":
+		result = s.stats()
+	case "groups":
+		result, rpcErr = s.groups()
+	case "act":
+		result, rpcErr = s.act(req.Params)
+	default:
+		rpcErr = &tRPCError{rpcMethodNotFound, "method not found: " + req.Method}
+	}
+	if req.ID == nil {
+		// notifications get no response
+		return
+	}
+	s.send(tRPCMessage{ID: req.ID, Result: result, Error: rpcErr})
+}
+
+// serveRPC answers requests from in until it's closed
+func serveRPC(in io.Reader, out io.Writer, options tOptions) error {
+	server := &tRPCServer{out: json.NewEncoder(out), options: options}
+	scanner := bufio.NewScanner(in)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		var req tRPCRequest
+		if err := json.Unmarshal(scanner.Bytes(), &req); err != nil {
+			server.send(tRPCMessage{ID: json.RawMessage("null"), Error: &tRPCError{rpcParseError, err.Error()}})
+			continue
+		}
+		if req.JSONRPC != "2.0" || req.Method == "" {
+			server.send(tRPCMessage{ID: req.ID, Error: &tRPCError{rpcInvalidRequest, "invalid request"}})
+			continue
+		}
+		server.handle(req)
+	}
+	return scanner.Err()
+}
+
+func runRPC(options tOptions) error {
+	defer unlockRoots()
+	return serveRPC(os.Stdin, os.Stdout, options)
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+)
+
+// `dup-fu service install [options] [scan-dir] [target-dir]` schedules a
+// weekly unattended run with those options, like the digest it mails: a
+// launchd agent on macOS, a scheduled task on Windows. The secret options
+// aren't written in the schedule, the run reads them from the config file.
+// A run is a scan that ends, not a process to keep alive, so it's scheduled
+// rather than run as a service. Linux and BSD have cron, see the -digest
+// example.
+
+const (
+	serviceInstall   = "install"
+	serviceUninstall = "uninstall"
+	serviceStart     = "start"
+	serviceName      = "dup-fu"
+)
+
+var (
+	serviceAction string
+	serviceTime   string
+	serviceDay    string
+	// serviceTime and serviceDay parsed by validateService
+	serviceHour, serviceMinute int
+	serviceWeekday             time.Weekday
+)
+
+// serviceOptions returns the options after the service action
+func serviceOptions() []string {
+	if len(os.Args) < 3 {
+		return nil
+	}
+	serviceAction = os.Args[2]
+	return os.Args[3:]
+}
+
+func validateService(args []string) error {
+	switch serviceAction {
+	case serviceUninstall, serviceStart:
+		if len(args) > 0 {
+			return fmt.Errorf("usage: dup-fu service %s", serviceAction)
+		}
+		return nil
+	case serviceInstall:
+	default:
+		return errors.New("usage: dup-fu service install|uninstall|start [options] [scan-dir] [target-dir]")
+	}
+	at, err := time.Parse("15:04", serviceTime)
+	if err != nil {
+		return fmt.Errorf("invalid -service-time value: %s", serviceTime)
+	}
+	serviceHour, serviceMinute = at.Hour(), at.Minute()
+	found := false
+	for day := time.Sunday; day <= time.Saturday; day++ {
+		if strings.EqualFold(serviceDay, day.String()) || strings.EqualFold(serviceDay, day.String()[:3]) {
+			serviceWeekday, found = day, true
+		}
+	}
+	if !found {
+		return fmt.Errorf("invalid -service-day value: %s", serviceDay)
+	}
+	if !digest {
+		return errors.New("-digest is required with service install, the TUI can't run unattended")
+	}
+	return nil
+}
+
+// scheduledArgs are the options of the install command but the secret ones,
+// with absolute scan and target directories: the scheduled run doesn't start
+// where this one did
+func scheduledArgs() []string {
+	options := os.Args[3 : len(os.Args)-flag.NArg()]
+	args := withoutSecrets(options)
+	if len(args) < len(options) {
+		log.Println("The secret options aren't saved in the schedule, the run reads them from the config file")
+	}
+	for _, dir := range flag.Args() {
+		args = append(args, absPath(dir))
+	}
+	return args
+}
+
+func runService() error {
+	switch serviceAction {
+	case serviceInstall:
+		exe, err := os.Executable()
+		if err != nil {
+			return err
+		}
+		return installService(exe, scheduledArgs())
+	case serviceUninstall:
+		return uninstallService()
+	}
+	return startService()
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"bytes"
+	"encoding/xml"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+)
+
+// the launchd agent runs as the user, in the background IO class
+
+const serviceLabel = "com.github.masgari.dup-fu"
+
+func agentPath() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, "Library", "LaunchAgents", serviceLabel+".plist"), nil
+}
+
+func plistString(s string) string {
+	var escaped bytes.Buffer
+	xml.EscapeText(&escaped, []byte(s))
+	return "<string>" + escaped.String() + "</string>"
+}
+
+func launchctl(args ...string) error {
+	cmd := exec.Command("launchctl", args...)
+	cmd.Stdout, cmd.Stderr = os.Stdout, os.Stderr
+	return cmd.Run()
+}
+
+func installService(exe string, args []string) error {
+	path, err := agentPath()
+	if err != nil {
+		return err
+	}
+	logPath := filepath.Join(filepath.Dir(filepath.Dir(path)), "Logs", "dup-fu.log")
+	var plist bytes.Buffer
+	plist.WriteString(`<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">
+<plist version="1.0">
+<dict>
+	<key>Label</key>
+	` + plistString(serviceLabel) + `
+	<key>ProgramArguments</key>
+	<array>
+`)
+	for _, arg := range append([]string{exe}, args...) {
+		plist.WriteString("\t\t" + plistString(arg) + "\n")
+	}
+	plist.WriteString(formatter.Sprintf(`	</array>
+	<key>StartCalendarInterval</key>
+	<dict>
+		<key>Weekday</key>
+		<integer>%d</integer>
+		<key>Hour</key>
+		<integer>%d</integer>
+		<key>Minute</key>
+		<integer>%d</integer>
+	</dict>
+	<key>LowPriorityIO</key>
+	<true/>
+	<key>Nice</key>
+	<integer>10</integer>
+	<key>StandardOutPath</key>
+	%s
+	<key>StandardErrorPath</key>
+	%s
+</dict>
+</plist>
+`, int(serviceWeekday), serviceHour, serviceMinute, plistString(logPath), plistString(logPath)))
+	if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+		return err
+	}
+	// reinstalling replaces the loaded agent
+	launchctl("unload", path)
+	if err := ioutil.WriteFile(path, plist.Bytes(), 0644); err != nil {
+		return err
+	}
+	return launchctl("load", "-w", path)
+}
+
+func uninstallService() error {
+	path, err := agentPath()
+	if err != nil {
+		return err
+	}
+	if err := launchctl("unload", "-w", path); err != nil {
+		return err
+	}
+	return os.Remove(path)
+}
+
+func startService() error {
+	return launchctl("start", serviceLabel)
+}
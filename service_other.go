@@ -0,0 +1,20 @@
+//go:build !darwin && !windows
+// +build !darwin,!windows
+
+package main
+
+import "errors"
+
+var errUseCron = errors.New("service is for macOS and Windows, schedule dup-fu -digest with cron or a systemd timer")
+
+func installService(exe string, args []string) error {
+	return errUseCron
+}
+
+func uninstallService() error {
+	return errUseCron
+}
+
+func startService() error {
+	return errUseCron
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"strings"
+	"syscall"
+)
+
+// a scheduled task of the user, run by Task Scheduler even when the user is
+// logged off if they allow it in its properties
+
+func schtasks(args ...string) error {
+	cmd := exec.Command("schtasks", args...)
+	cmd.Stdout, cmd.Stderr = os.Stdout, os.Stderr
+	return cmd.Run()
+}
+
+func installService(exe string, args []string) error {
+	line := make([]string, 0, len(args)+1)
+	for _, arg := range append([]string{exe}, args...) {
+		line = append(line, syscall.EscapeArg(arg))
+	}
+	at := formatter.Sprintf("%02d:%02d", serviceHour, serviceMinute)
+	day := strings.ToUpper(serviceWeekday.String()[:3])
+	return schtasks("/Create", "/F", "/TN", serviceName, "/SC", "WEEKLY", "/D", day, "/ST", at, "/TR", strings.Join(line, " "))
+}
+
+func uninstallService() error {
+	return schtasks("/Delete", "/F", "/TN", serviceName)
+}
+
+func startService() error {
+	return schtasks("/Run", "/TN", serviceName)
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+
+	"github.com/rivo/tview"
+)
+
+func userShell() string {
+	if shell := os.Getenv("SHELL"); shell != "" {
+		return shell
+	}
+	if runtime.GOOS == "windows" {
+		if comspec := os.Getenv("COMSPEC"); comspec != "" {
+			return comspec
+		}
+		return "cmd.exe"
+	}
+	return "/bin/sh"
+}
+
+// openShell suspends the TUI and runs a shell in the selected original's
+// directory, the TUI resumes when the shell exits
+func openShell(app *tview.Application, right *tview.List) {
+	list, err := selectedGroup(right)
+	if err != nil {
+		setStatus(err.Error())
+		return
+	}
+	dir := filepath.Dir(list[0].path)
+	app.Suspend(func() {
+		fmt.Printf("dup-fu: %s, exit the shell to return\n", dir)
+		cmd := exec.Command(userShell())
+		cmd.Dir = dir
+		cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+		if err := cmd.Run(); err != nil {
+			setStatus("Shell failed: " + err.Error())
+		}
+	})
+}
@@ -0,0 +1,197 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"code.cloudfoundry.org/bytefmt"
+)
+
+// The similar command reports files sharing most of their chunks without
+// being duplicates: a video with rewritten metadata, a log that grew, a
+// re-exported document. Exact hashes never match these. Files that are the
+// exact beginning of a larger one are reported apart as truncated copies.
+
+const (
+	// smaller files are cheaper to look at than to report
+	similarMinSize = 1024 * 1024
+	// chunks in more files than this are padding or zeros, not shared content
+	similarFanout = 64
+)
+
+var similarMin int
+
+type tSimilar struct {
+	a, b   tFileData
+	shared int64
+}
+
+// percent of the smaller file found in the other one
+func (s tSimilar) percent() float64 {
+	smaller := s.a.size
+	if s.b.size < smaller {
+		smaller = s.b.size
+	}
+	return float64(s.shared) / float64(smaller) * 100
+}
+
+// similarCandidates is one file of every content, duplicates are already known
+func similarCandidates() []tFileData {
+	duplicatesLock.Lock()
+	defer duplicatesLock.Unlock()
+	var files []tFileData
+	for hash, list := range duplicates {
+		if list[0].size >= similarMinSize && !strings.HasPrefix(hash, "partial:") {
+			files = append(files, list[0])
+		}
+	}
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].path < files[j].path
+	})
+	return files
+}
+
+func chunkAll(files []tFileData) ([][]tChunk, error) {
+	chunks := make([][]tChunk, len(files))
+	for i, file := range files {
+		var err error
+		if chunks[i], err = chunkFile(file.path); err != nil {
+			return nil, err
+		}
+	}
+	return chunks, nil
+}
+
+func findSimilar(files []tFileData, chunks [][]tChunk) []tSimilar {
+	index := make(map[[20]byte][]int)
+	sizes := make(map[[20]byte]int64)
+	for i := range files {
+		seen := make(map[[20]byte]bool)
+		for _, c := range chunks[i] {
+			if !seen[c.sum] {
+				seen[c.sum] = true
+				index[c.sum] = append(index[c.sum], i)
+				sizes[c.sum] = c.size
+			}
+		}
+	}
+	shared := make(map[[2]int]int64)
+	for sum, list := range index {
+		if len(list) < 2 || len(list) > similarFanout {
+			continue
+		}
+		for x := range list {
+			for y := x + 1; y < len(list); y++ {
+				shared[[2]int{list[x], list[y]}] += sizes[sum]
+			}
+		}
+	}
+	var result []tSimilar
+	for pair, size := range shared {
+		s := tSimilar{files[pair[0]], files[pair[1]], size}
+		if s.percent() >= float64(similarMin) {
+			result = append(result, s)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].shared > result[j].shared
+	})
+	return result
+}
+
+// tTruncated is a file that is the beginning of a larger one, usually an
+// interrupted download or copy, the truncated one can go
+type tTruncated struct {
+	file, full tFileData
+}
+
+// samePrefix reports whether every chunk of a but the last, cut at its end,
+// starts b as well
+func samePrefix(a, b []tChunk) bool {
+	if len(a) == 0 || len(a) > len(b) {
+		return false
+	}
+	for i := range a[:len(a)-1] {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func findTruncated(files []tFileData, chunks [][]tChunk) ([]tTruncated, error) {
+	// a prefix starts with the same chunk as the full file
+	byFirst := make(map[[20]byte][]int)
+	for i := range files {
+		if len(chunks[i]) > 0 {
+			byFirst[chunks[i][0].sum] = append(byFirst[chunks[i][0].sum], i)
+		}
+	}
+	var result []tTruncated
+	for _, list := range byFirst {
+		// the largest file is the most complete one
+		sort.Slice(list, func(i, j int) bool {
+			return files[list[i]].size > files[list[j]].size
+		})
+		for _, x := range list {
+			for _, y := range list {
+				if files[x].size >= files[y].size || !samePrefix(chunks[x], chunks[y]) {
+					continue
+				}
+				prefix, err := isPrefix(files[x].path, files[y].path)
+				if err != nil {
+					return nil, err
+				}
+				if prefix {
+					result = append(result, tTruncated{files[x], files[y]})
+					break
+				}
+			}
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].file.path < result[j].file.path
+	})
+	return result, nil
+}
+
+func runSimilar(out io.Writer, options tOptions) error {
+	go findDuplicates()
+	waitForScan(options)
+	files := similarCandidates()
+	chunks, err := chunkAll(files)
+	if err != nil {
+		return err
+	}
+	truncated, err := findTruncated(files, chunks)
+	if err != nil {
+		return err
+	}
+	// the truncated copies go anyway, their similarities are noise
+	reported := make(map[string]bool)
+	if len(truncated) > 0 {
+		fmt.Fprintln(out, "Truncated copies, the start of a larger file, delete them:")
+	}
+	for _, t := range truncated {
+		fmt.Fprintf(out, "  %s (%s of %s)  <-  %s\n", t.file.path, bytefmt.ByteSize(uint64(t.file.size)), bytefmt.ByteSize(uint64(t.full.size)), t.full.path)
+		reported[t.file.path] = true
+	}
+	var list []tSimilar
+	for _, s := range findSimilar(files, chunks) {
+		if !reported[s.a.path] && !reported[s.b.path] {
+			list = append(list, s)
+		}
+	}
+	if len(truncated) > 0 && len(list) > 0 {
+		fmt.Fprintln(out, "\nSimilar files:")
+	}
+	if len(truncated) == 0 && len(list) == 0 {
+		fmt.Fprintln(out, "No similar files found.")
+	}
+	for _, s := range list {
+		fmt.Fprintf(out, "%5.1f%%  %8s shared  %s  <->  %s\n", s.percent(), bytefmt.ByteSize(uint64(s.shared)), s.a.path, s.b.path)
+	}
+	return nil
+}
@@ -0,0 +1,251 @@
+package main
+
+import (
+	"log"
+	"strings"
+	"time"
+
+	"code.cloudfoundry.org/bytefmt"
+	"github.com/gdamore/tcell"
+	"github.com/mattn/go-runewidth"
+)
+
+// A single column interface drawn with tcell only, for minimal terminals
+// (serial consoles, busybox) the tview layout doesn't work on.
+
+var simpleUI bool
+
+type tSimpleUI struct {
+	screen   tcell.Screen
+	groups   []tGroup
+	selected string
+	offset   int
+	// question waiting for y/n, answered by confirm
+	prompt  string
+	confirm func()
+	// outcome of the last action
+	message string
+	done    bool
+}
+
+func (ui *tSimpleUI) stop() {
+	if !ui.done {
+		ui.done = true
+		ui.screen.Fini()
+	}
+}
+
+func (ui *tSimpleUI) print(y int, text string, style tcell.Style) {
+	width, _ := ui.screen.Size()
+	x := 0
+	for _, r := range text {
+		w := runewidth.RuneWidth(r)
+		if x+w > width {
+			break
+		}
+		ui.screen.SetContent(x, y, r, nil, style)
+		x += w
+	}
+	for ; x < width; x++ {
+		ui.screen.SetContent(x, y, ' ', nil, style)
+	}
+}
+
+func (ui *tSimpleUI) selectedIndex() int {
+	for i, group := range ui.groups {
+		if group.hash == ui.selected {
+			return i
+		}
+	}
+	return 0
+}
+
+func (ui *tSimpleUI) move(delta int) {
+	if len(ui.groups) == 0 {
+		return
+	}
+	index := ui.selectedIndex() + delta
+	if index < 0 {
+		index = 0
+	} else if index >= len(ui.groups) {
+		index = len(ui.groups) - 1
+	}
+	ui.selected = ui.groups[index].hash
+}
+
+func (ui *tSimpleUI) draw() {
+	snap := takeSnapshot(-1)
+	ui.groups = snap.groups
+	_, height := ui.screen.Size()
+	normal := tcell.StyleDefault
+	ui.screen.Clear()
+	ui.print(0, "dup-fu: "+rootsName(), normal)
+	done := "No"
+	if snap.finished {
+		done = "Yes"
+	} else if snap.stats.complted {
+		done = formatter.Sprintf("hashing, %d left", snap.pending)
+	}
+	percent := "-"
+	if snap.stats.size > 0 {
+		percent = formatter.Sprintf("%.2f%% %s", snap.stats.duplicatePercent(), formatSeverity(snap.stats.duplicatePercent()))
+	}
+	ui.print(1, formatter.Sprintf("Scanned: %d  Size: %s  Duplicates: %d  Duplicate Size: %s (%s)  Finished: %s",
+		snap.stats.count, bytefmt.ByteSize(snap.stats.size), snap.stats.duplicates, bytefmt.ByteSize(snap.stats.duplicateSize), percent, done), normal)
+	if list := snap.notices; len(list) > 0 {
+		ui.print(2, formatter.Sprintf("Notices: %d, last: %s", len(list), list[len(list)-1]), normal)
+	}
+
+	// a question may take several lines, its last one is the footer
+	footer := []string{"Ctrl+e: Export  Ctrl+m: Move  Ctrl+_: Delete  Ctrl+l: Link trees  Esc: Quit"}
+	if ui.prompt != "" {
+		footer = strings.Split(ui.prompt+" (y/n)", "\n")
+	}
+	rows := height - 4 - len(footer)
+	if ui.message != "" {
+		ui.print(height-1-len(footer), ui.message, normal)
+		rows--
+	}
+	index := ui.selectedIndex()
+	if index < ui.offset {
+		ui.offset = index
+	} else if rows > 0 && index >= ui.offset+rows {
+		ui.offset = index - rows + 1
+	}
+	for row := 0; row < rows && ui.offset+row < len(ui.groups); row++ {
+		group := ui.groups[ui.offset+row]
+		text := "  " + group.files[0].path + " <- " + group.files[1].path
+		if len(group.files) > 2 {
+			text += formatter.Sprintf(" (+%d more)", len(group.files)-2)
+		}
+		style := normal
+		if ui.offset+row == index {
+			text = ">" + text[1:]
+			style = normal.Reverse(true)
+		}
+		ui.print(3+row, text, style)
+	}
+
+	for i, line := range footer {
+		ui.print(height-len(footer)+i, line, normal.Reverse(true))
+	}
+	ui.screen.Show()
+}
+
+// finish shows the outcome of an action above the footer, a dry run report
+// needs the whole terminal
+func (ui *tSimpleUI) finish(o tOutcome) {
+	forgetFiles(o.gone)
+	if o.report != "" {
+		ui.stop()
+		if err := printOutcome(o); err != nil {
+			log.Println(err)
+		}
+		return
+	}
+	ui.message = strings.Join(o.summary, ", ")
+	if o.err != nil {
+		ui.message = "Failed: " + o.err.Error()
+	}
+}
+
+// ask shows a y/n question in the footer, action runs on y
+func (ui *tSimpleUI) ask(question string, action func()) {
+	ui.prompt = question
+	ui.confirm = action
+}
+
+// scanFinished tells to wait when the groups are still being built
+func (ui *tSimpleUI) scanFinished() bool {
+	if scanFinished() {
+		return true
+	}
+	ui.message = "Wait for the scan to finish"
+	return false
+}
+
+// confirmRemoval is the simple UI version of confirmRemoval
+func (ui *tSimpleUI) confirmRemoval(verb string, action func() tOutcome) {
+	if !ui.scanFinished() {
+		return
+	}
+	list := listDuplicates()
+	if len(list) == 0 {
+		ui.message = "No duplicates to " + strings.ToLower(verb)
+		return
+	}
+	ui.ask(removalQuestion(verb, list), func() { ui.finish(action()) })
+}
+
+func (ui *tSimpleUI) handleKey(event *tcell.EventKey) {
+	if ui.prompt != "" {
+		action := ui.confirm
+		ui.prompt, ui.confirm = "", nil
+		if event.Key() == tcell.KeyRune && (event.Rune() == 'y' || event.Rune() == 'Y') {
+			action()
+		}
+		return
+	}
+	_, height := ui.screen.Size()
+	switch event.Key() {
+	case tcell.KeyESC:
+		ui.stop()
+	case tcell.KeyUp:
+		ui.move(-1)
+	case tcell.KeyDown:
+		ui.move(1)
+	case tcell.KeyPgUp:
+		ui.move(-(height - 5))
+	case tcell.KeyPgDn:
+		ui.move(height - 5)
+	case tcell.KeyHome:
+		ui.move(-len(ui.groups))
+	case tcell.KeyEnd:
+		ui.move(len(ui.groups))
+	case tcell.KeyCtrlE:
+		ui.finish(exportDuplicates())
+	case tcell.KeyCtrlM:
+		ui.confirmRemoval("Move", moveDuplicates)
+	case tcell.KeyCtrlUnderscore:
+		ui.confirmRemoval("Delete", deleteDuplicates)
+	case tcell.KeyCtrlL:
+		if !ui.scanFinished() {
+			return
+		}
+		if count, size := treesToLink(); count == 0 {
+			ui.message = "No duplicate trees to link"
+		} else {
+			ui.ask(treesQuestion(count, size), func() { ui.finish(linkDuplicateTrees()) })
+		}
+	}
+}
+
+func runSimpleUI(options tOptions) error {
+	screen, err := tcell.NewScreen()
+	if err != nil {
+		return err
+	}
+	if err := screen.Init(); err != nil {
+		return err
+	}
+	ui := &tSimpleUI{screen: screen}
+	defer ui.stop()
+
+	go findDuplicates()
+	go scan(options)
+	go func() {
+		for range time.Tick(time.Second) {
+			screen.PostEvent(tcell.NewEventInterrupt(nil))
+		}
+	}()
+	for !ui.done {
+		ui.draw()
+		switch event := screen.PollEvent().(type) {
+		case *tcell.EventKey:
+			ui.handleKey(event)
+		case *tcell.EventResize:
+			screen.Sync()
+		}
+	}
+	return nil
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"io"
+)
+
+// Files smaller than smallFileSize are bound by open/close rather than by
+// hashing, they get their own worker pool with a reused buffer.
+const smallFileSize = 64 * 1024
+
+var smallFileChannel chan tFileData
+
+func isSmallFile(data tFileData) bool {
+	return data.size < smallFileSize && !data.partial
+}
+
+// smallChecksum hashes the file and sniffs its content from the first read
+func smallChecksum(o *tOpener, path string, buf []byte) ([]byte, int, error) {
+	f, err := o.open(path)
+	if err != nil {
+		return nil, contentUnknown, err
+	}
+	defer f.Close()
+	h := newHash()
+	content := contentUnknown
+	for {
+		n, err := f.Read(buf)
+		if content == contentUnknown && n > 0 {
+			content = sniffContent(buf[:n])
+		}
+		h.Write(buf[:n])
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, content, err
+		}
+	}
+	return h.Sum(nil), content, nil
+}
+
+func hashSmallFile(o *tOpener, data tFileData, buf []byte, options tOptions) (_ tFileData, err error) {
+	defer recoverHash(&err)
+	if known, exist := knownHash(data); exist {
+		return known, nil
+	}
+	err = options.retry(func() error {
+		var err error
+		data.hash, data.content, err = smallChecksum(o, data.path, buf)
+		return err
+	})
+	return data, err
+}
+
+func calculateSmallChecksum(options tOptions) {
+	defer hashers.Done()
+	o := newOpener()
+	defer o.close()
+	buf := make([]byte, smallFileSize)
+	for data := range smallFileChannel {
+		if hashed, err := hashSmallFile(o, data, buf, options); err != nil {
+			hashFailed(data, err)
+		} else {
+			checksumChannel <- hashed
+		}
+	}
+}
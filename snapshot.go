@@ -0,0 +1,66 @@
+package main
+
+import (
+	"sort"
+	"sync/atomic"
+)
+
+// tSnapshot is a consistent copy of the scan state for rendering, the
+// pipeline goes on changing the live one meanwhile
+type tSnapshot struct {
+	stats    tStats
+	finished bool
+	// files found but not grouped yet
+	pending   int64
+	conflicts int
+	notices   []string
+	// largest reclaimable first
+	groups []tGroup
+}
+
+// takeSnapshot copies the stats and the top largest groups, every group
+// when top is negative and none, for the stats alone, when it is 0
+func takeSnapshot(top int) tSnapshot {
+	var snap tSnapshot
+	walkLock.Lock()
+	snap.conflicts = len(conflicts)
+	duplicatesLock.Lock()
+	walkLock.Unlock()
+	snap.stats = stats
+	snap.finished = stats.finished
+	snap.pending = atomic.LoadInt64(&pending)
+	if top != 0 {
+		snap.groups = topGroups(top)
+	}
+	duplicatesLock.Unlock()
+	snap.notices = listNotices()
+	return snap
+}
+
+// topGroups copies the top largest groups, the caller holds duplicatesLock
+func topGroups(top int) []tGroup {
+	var groups []tGroup
+	for hash, list := range duplicates {
+		if reportedGroup(list) {
+			groups = append(groups, tGroup{hash, list})
+		}
+	}
+	sort.Slice(groups, func(i, j int) bool {
+		return groups[i].reclaimable() > groups[j].reclaimable()
+	})
+	if top >= 0 && len(groups) > top {
+		groups = groups[:top]
+	}
+	// the next file of a group sorts its list in place
+	for i, group := range groups {
+		groups[i].files = append([]tFileData(nil), group.files...)
+	}
+	return groups
+}
+
+// addSkipped counts a file left out of the scan
+func addSkipped() {
+	duplicatesLock.Lock()
+	stats.skipped++
+	duplicatesLock.Unlock()
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"sync/atomic"
+)
+
+// A duplicate can be replaced by a symlink to its original as well, across
+// filesystems too. Programs reading a configuration tree follow the links
+// transparently, a relative link keeps working when the tree is moved as a
+// whole.
+
+const (
+	linkRelative = "relative"
+	linkAbsolute = "absolute"
+)
+
+var linkStyle string
+
+// symlinkTarget is what the link at path points to, by -link-style
+func symlinkTarget(original, path string) (string, error) {
+	original = absPath(original)
+	if linkStyle == linkAbsolute {
+		return original, nil
+	}
+	return filepath.Rel(filepath.Dir(absPath(path)), original)
+}
+
+// symlinkFile replaces path with a symlink to original, made next to path
+// and renamed over it like hardlinkFile
+func symlinkFile(original, path string) error {
+	target, err := symlinkTarget(original, path)
+	if err != nil || dryRun {
+		return err
+	}
+	tmp := path + ".dup-fu-link"
+	if err := os.Symlink(target, tmp); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return nil
+}
+
+// symlinkCopies replaces every duplicate but the originals with a symlink
+// and returns the linked files
+func symlinkCopies() ([]string, error) {
+	list := withoutMismatches(listDuplicates())
+	originals := duplicateOriginals()
+	linked := make([]string, 0, len(list))
+	for _, path := range list {
+		if err := symlinkFile(originals[path], path); err != nil {
+			actionFailed(path, err)
+			continue
+		}
+		linked = append(linked, path)
+	}
+	return linked, nil
+}
+
+func symlinkDuplicates() tOutcome {
+	before := atomic.LoadInt64(&actionFailures)
+	linked, err := symlinkCopies()
+	o := newOutcome(opSymlink, linked, err)
+	if !dryRun {
+		o.logf("Replaced %d duplicate file(s) with symlinks", len(linked))
+	}
+	logMismatches(&o)
+	logFailures(&o, before)
+	tagOriginals(keptOriginals(linked))
+	return o
+}
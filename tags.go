@@ -0,0 +1,71 @@
+package main
+
+import (
+	"errors"
+	"sync/atomic"
+
+	"github.com/rivo/tview"
+)
+
+// Tagging marks the duplicates for the native file manager instead of acting
+// on them: a Finder tag on macOS, an xdg tag Dolphin shows on Linux. The
+// copies can be reviewed there, sorted or searched by the tag, and deleted by
+// hand.
+
+const duplicateTag = "DupFu: duplicate"
+
+var errTagsUnsupported = errors.New("file manager tags aren't supported on this platform")
+
+// tagCopies tags every duplicate but the originals
+func tagCopies() ([]string, error) {
+	list := withoutMismatches(listDuplicates())
+	if dryRun {
+		return list, nil
+	}
+	tagged := make([]string, 0, len(list))
+	for _, path := range list {
+		if err := addFileTag(path, duplicateTag); err != nil {
+			if err == errTagsUnsupported {
+				return tagged, err
+			}
+			actionFailed(path, err)
+			continue
+		}
+		tagged = append(tagged, path)
+	}
+	return tagged, nil
+}
+
+func tagsQuestion(list []string) string {
+	return formatter.Sprintf("Tag %d duplicate file(s) %q?", len(list), duplicateTag)
+}
+
+// confirmTags is confirmLinks for tags, the files stay
+func confirmTags(app *tview.Application, right *tview.List) {
+	if !scanFinished() {
+		setStatus("Wait for the scan to finish")
+		return
+	}
+	list := listDuplicates()
+	if len(list) == 0 {
+		setStatus("No duplicates to tag")
+		return
+	}
+	showModal(app, "confirm", tagsQuestion(list), []string{"Yes", "No"}, func(label string) {
+		if label == "Yes" {
+			runAction(app, right, "Tagging", tagDuplicates)
+		}
+	})
+}
+
+func tagDuplicates() tOutcome {
+	before := atomic.LoadInt64(&actionFailures)
+	tagged, err := tagCopies()
+	o := newOutcome(opTag, tagged, err)
+	if !dryRun {
+		o.logf("Tagged %d duplicate file(s) %q", len(tagged), duplicateTag)
+	}
+	logMismatches(&o)
+	logFailures(&o, before)
+	return o
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"strings"
+	"syscall"
+	"unicode/utf16"
+)
+
+// Finder keeps the tags of a file as a binary property list, an array of
+// strings, in an extended attribute
+
+const finderTags = "com.apple.metadata:_kMDItemUserTags"
+
+var errBadPlist = errors.New("unexpected Finder tags property list")
+
+func addFileTag(path, tag string) error {
+	value, err := getXattr(path, finderTags)
+	if err != nil && err != syscall.ENOATTR {
+		return err
+	}
+	var tags []string
+	if value != "" {
+		if tags, err = decodeTags([]byte(value)); err != nil {
+			return err
+		}
+	}
+	for _, t := range tags {
+		// a tag may end with a newline and its color number
+		if t == tag || strings.HasPrefix(t, tag+"\n") {
+			return nil
+		}
+	}
+	return setXattr(path, finderTags, string(encodeTags(append(tags, tag))))
+}
+
+// plistCount writes the count of an object in its marker, or after it when
+// it doesn't fit
+func plistCount(data *bytes.Buffer, kind byte, count int) {
+	if count < 15 {
+		data.WriteByte(kind | byte(count))
+		return
+	}
+	data.WriteByte(kind | 0xf)
+	data.WriteByte(0x11)
+	binary.Write(data, binary.BigEndian, uint16(count))
+}
+
+// encodeTags writes an array of at most 254 strings, one byte object
+// references
+func encodeTags(tags []string) []byte {
+	if len(tags) > 254 {
+		tags = tags[:254]
+	}
+	var data bytes.Buffer
+	data.WriteString("bplist00")
+	offsets := []int{data.Len()}
+	plistCount(&data, 0xa0, len(tags))
+	for i := range tags {
+		data.WriteByte(byte(i + 1))
+	}
+	for _, tag := range tags {
+		offsets = append(offsets, data.Len())
+		ascii := true
+		for _, r := range tag {
+			if r >= 0x80 {
+				ascii = false
+			}
+		}
+		if ascii {
+			plistCount(&data, 0x50, len(tag))
+			data.WriteString(tag)
+			continue
+		}
+		units := utf16.Encode([]rune(tag))
+		plistCount(&data, 0x60, len(units))
+		binary.Write(&data, binary.BigEndian, units)
+	}
+	table := data.Len()
+	for _, offset := range offsets {
+		binary.Write(&data, binary.BigEndian, uint32(offset))
+	}
+	// trailer: offset and reference sizes, object count, top object, table
+	data.Write(make([]byte, 6))
+	data.WriteByte(4)
+	data.WriteByte(1)
+	binary.Write(&data, binary.BigEndian, uint64(len(offsets)))
+	binary.Write(&data, binary.BigEndian, uint64(0))
+	binary.Write(&data, binary.BigEndian, uint64(table))
+	return data.Bytes()
+}
+
+// plistInt reads a big endian integer of size bytes at offset
+func plistInt(data []byte, offset, size int) (int, error) {
+	if offset < 0 || size < 1 || size > 8 || offset+size > len(data) {
+		return 0, errBadPlist
+	}
+	n := 0
+	for _, b := range data[offset : offset+size] {
+		n = n<<8 | int(b)
+	}
+	return n, nil
+}
+
+// plistObject returns the kind, count and content offset of the object at
+// offset
+func plistObject(data []byte, offset int) (byte, int, int, error) {
+	if offset < 0 || offset >= len(data) {
+		return 0, 0, 0, errBadPlist
+	}
+	kind, count := data[offset]&0xf0, int(data[offset]&0x0f)
+	offset++
+	if count == 0xf {
+		if offset >= len(data) || data[offset]&0xf0 != 0x10 {
+			return 0, 0, 0, errBadPlist
+		}
+		size := 1 << (data[offset] & 0x0f)
+		var err error
+		if count, err = plistInt(data, offset+1, size); err != nil {
+			return 0, 0, 0, err
+		}
+		offset += 1 + size
+	}
+	return kind, count, offset, nil
+}
+
+// decodeTags reads the array of strings Finder writes
+func decodeTags(data []byte) ([]string, error) {
+	if len(data) < 40 || !bytes.HasPrefix(data, []byte("bplist00")) {
+		return nil, errBadPlist
+	}
+	trailer := data[len(data)-32:]
+	offsetSize, refSize := int(trailer[6]), int(trailer[7])
+	top := int(binary.BigEndian.Uint64(trailer[16:24]))
+	table := int(binary.BigEndian.Uint64(trailer[24:32]))
+	objectOffset := func(ref int) (int, error) {
+		return plistInt(data, table+ref*offsetSize, offsetSize)
+	}
+	offset, err := objectOffset(top)
+	if err != nil {
+		return nil, err
+	}
+	kind, count, offset, err := plistObject(data, offset)
+	if err != nil || kind != 0xa0 {
+		return nil, errBadPlist
+	}
+	tags := make([]string, 0, count)
+	for i := 0; i < count; i++ {
+		ref, err := plistInt(data, offset+i*refSize, refSize)
+		if err != nil {
+			return nil, err
+		}
+		at, err := objectOffset(ref)
+		if err != nil {
+			return nil, err
+		}
+		kind, length, start, err := plistObject(data, at)
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case kind == 0x50 && start+length <= len(data):
+			tags = append(tags, string(data[start:start+length]))
+		case kind == 0x60 && start+2*length <= len(data):
+			units := make([]uint16, length)
+			for j := range units {
+				units[j] = binary.BigEndian.Uint16(data[start+2*j:])
+			}
+			tags = append(tags, string(utf16.Decode(units)))
+		default:
+			return nil, errBadPlist
+		}
+	}
+	return tags, nil
+}
@@ -0,0 +1,26 @@
+package main
+
+import (
+	"strings"
+	"syscall"
+)
+
+// user.xdg.tags holds comma separated tags, read by Dolphin and Baloo
+const xdgTags = "user.xdg.tags"
+
+func addFileTag(path, tag string) error {
+	value, err := getXattr(path, xdgTags)
+	if err != nil && err != syscall.ENODATA {
+		return err
+	}
+	var tags []string
+	if value != "" {
+		tags = strings.Split(value, ",")
+	}
+	for _, t := range tags {
+		if t == tag {
+			return nil
+		}
+	}
+	return setXattr(path, xdgTags, strings.Join(append(tags, tag), ","))
+}
@@ -0,0 +1,9 @@
+//go:build !linux && !darwin
+// +build !linux,!darwin
+
+package main
+
+// addFileTag fails, Explorer has no tags for every kind of file
+func addFileTag(path, tag string) error {
+	return errTagsUnsupported
+}
@@ -0,0 +1,54 @@
+package main
+
+import "fmt"
+
+const (
+	severityLow = iota
+	severityMedium
+	severityHigh
+)
+
+// tTheme holds the tview color tags of the severity levels
+type tTheme struct {
+	severity [3]string
+}
+
+var (
+	themeName string
+	theme     tTheme
+	themes    = map[string]tTheme{
+		"default": {[3]string{"green", "yellow", "red"}},
+		// Okabe-Ito blue, orange and vermillion, distinguishable with color blindness
+		"colorblind": {[3]string{"#0072B2", "#E69F00", "#D55E00"}},
+	}
+	// severity is never signaled by color alone
+	severityMarkers = [3]string{"○", "◐", "●"}
+	severityLabels  = [3]string{"low", "medium", "high"}
+	// duplicate percents above these are medium and high
+	mediumPercent float64
+	highPercent   float64
+)
+
+func setTheme(name string) error {
+	t, exist := themes[name]
+	if !exist {
+		return fmt.Errorf("unknown theme: %s", name)
+	}
+	theme = t
+	return nil
+}
+
+func percentSeverity(percent float64) int {
+	if percent > highPercent {
+		return severityHigh
+	} else if percent > mediumPercent {
+		return severityMedium
+	}
+	return severityLow
+}
+
+// formatSeverity is the plain text form, for the modes without colors
+func formatSeverity(percent float64) string {
+	level := percentSeverity(percent)
+	return severityMarkers[level] + " " + severityLabels[level]
+}
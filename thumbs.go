@@ -0,0 +1,168 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"html/template"
+	"image"
+	_ "image/gif" // decoders of the thumbnails
+	"image/jpeg"
+	_ "image/png"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// The thumbnails of the photo and video groups are made while the scan goes
+// on, as soon as a group forms, and cached in target-dir by content hash: the
+// review page is ready when the scan is, and the next review reuses them.
+// Videos get the frame ffmpeg extracts, when it's installed.
+
+const (
+	thumbnailSize    = 160
+	thumbnailDir     = "thumbnails"
+	thumbnailWorkers = 2
+)
+
+var ffmpegPath, _ = exec.LookPath("ffmpeg")
+
+// thumbnailPath names the thumbnail by the content hash of the group. A file
+// settled without one has a key numbered by this scan only, its thumbnail is
+// named by the path, size and time of the file instead.
+func thumbnailPath(hash string, d tFileData) string {
+	name := strings.NewReplacer(":", "-", "/", "-", "\\", "-").Replace(hash)
+	if d.settled != "" {
+		sum := sha256.Sum256([]byte(fmt.Sprintf("%s\x00%d\x00%d", absPath(d.path), d.size, d.modified)))
+		name = "file-" + hex.EncodeToString(sum[:16])
+	}
+	return filepath.Join(targetDir, thumbnailDir, name+".jpg")
+}
+
+// visitThumbnailDir skips the cache, its thumbnails aren't the user's photos
+func visitThumbnailDir(path string) error {
+	if absPath(path) == absPath(filepath.Join(targetDir, thumbnailDir)) {
+		return filepath.SkipDir
+	}
+	return nil
+}
+
+// scaleImage scales an image down to thumbnailSize, nearest neighbor is good
+// enough to recognize a photo. Formats the standard library can't decode,
+// like HEIC and raw files, have none.
+func scaleImage(path string) ([]byte, error) {
+	f, err := openFile(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	src, _, err := image.Decode(f)
+	if err != nil {
+		return nil, err
+	}
+	bounds := src.Bounds()
+	w, h := bounds.Dx(), bounds.Dy()
+	if w == 0 || h == 0 {
+		return nil, errors.New("empty image")
+	}
+	scale := float64(thumbnailSize) / float64(w)
+	if h > w {
+		scale = float64(thumbnailSize) / float64(h)
+	}
+	if scale > 1 {
+		scale = 1
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, int(float64(w)*scale)+1, int(float64(h)*scale)+1))
+	for y := 0; y < dst.Rect.Dy(); y++ {
+		for x := 0; x < dst.Rect.Dx(); x++ {
+			sx, sy := bounds.Min.X+int(float64(x)/scale), bounds.Min.Y+int(float64(y)/scale)
+			if sx < bounds.Max.X && sy < bounds.Max.Y {
+				dst.Set(x, y, src.At(sx, sy))
+			}
+		}
+	}
+	var data bytes.Buffer
+	err = jpeg.Encode(&data, dst, &jpeg.Options{Quality: 75})
+	return data.Bytes(), err
+}
+
+// makeThumbnail writes the thumbnail of the group to the cache, unless it's
+// there already
+func makeThumbnail(hash string, d tFileData) error {
+	path := thumbnailPath(hash, d)
+	if _, err := os.Stat(path); err == nil {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+		return err
+	}
+	switch {
+	case d.content == contentImage:
+		data, err := scaleImage(d.path)
+		if err != nil {
+			return err
+		}
+		return ioutil.WriteFile(path, data, 0644)
+	case d.content == contentVideo && ffmpegPath != "":
+		return exec.Command(ffmpegPath, "-loglevel", "error", "-i", d.path, "-frames:v", "1",
+			"-vf", fmt.Sprintf("scale=%d:%[1]d:force_original_aspect_ratio=decrease", thumbnailSize), "-y", path).Run()
+	}
+	return nil
+}
+
+// thumbnailURL embeds the cached thumbnail of the group, making it first if
+// the scan didn't
+func thumbnailURL(hash string, d tFileData) template.URL {
+	if err := makeThumbnail(hash, d); err != nil {
+		return ""
+	}
+	data, err := ioutil.ReadFile(thumbnailPath(hash, d))
+	if err != nil {
+		return ""
+	}
+	return template.URL("data:image/jpeg;base64," + base64.StdEncoding.EncodeToString(data))
+}
+
+// buildThumbnails makes the thumbnails of the groups as they form, the
+// returned channel is closed once the scan is finished and every one is made
+func buildThumbnails(events <-chan tEvent) <-chan struct{} {
+	jobs := make(chan tEvent, 1000)
+	built := make(chan struct{})
+	var workers sync.WaitGroup
+	for i := 0; i < thumbnailWorkers; i++ {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			for e := range jobs {
+				// a failed thumbnail is left out of the page
+				makeThumbnail(e.hash, e.group[0])
+			}
+		}()
+	}
+	go func() {
+		seen := make(map[string]bool)
+		for e := range events {
+			if e.kind == eventPhaseChanged {
+				if e.phase == phaseFinished {
+					break
+				}
+				continue
+			}
+			content := e.group[0].content
+			if !seen[e.hash] && (content == contentImage || content == contentVideo) {
+				seen[e.hash] = true
+				jobs <- e
+			}
+		}
+		close(jobs)
+		workers.Wait()
+		close(built)
+	}()
+	return built
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	torrentQBittorrent  = "qbittorrent"
+	torrentTransmission = "transmission"
+
+	// transmission torrent status
+	transmissionSeedWait = 5
+	transmissionSeed     = 6
+)
+
+var (
+	torrentClient   string
+	torrentURL      string
+	torrentUser     string
+	torrentPassword string
+)
+
+func validTorrentClient(client string) bool {
+	return client == "" || client == torrentQBittorrent || client == torrentTransmission
+}
+
+// seedingFiles returns the absolute paths of the files the torrent client is seeding
+func seedingFiles() (map[string]bool, error) {
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		return nil, err
+	}
+	client := &http.Client{Timeout: 30 * time.Second, Jar: jar}
+	base := strings.TrimRight(torrentURL, "/")
+	switch torrentClient {
+	case torrentQBittorrent:
+		return qbittorrentSeeding(client, base)
+	case torrentTransmission:
+		return transmissionSeeding(client, base)
+	}
+	return map[string]bool{}, nil
+}
+
+func decodeResponse(resp *http.Response, err error, v interface{}) error {
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s: %s", resp.Request.URL.Path, resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+func qbittorrentSeeding(client *http.Client, base string) (map[string]bool, error) {
+	resp, err := client.PostForm(base+"/api/v2/auth/login", url.Values{"username": {torrentUser}, "password": {torrentPassword}})
+	if err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("qbittorrent login failed: %s", resp.Status)
+	}
+	var torrents []struct {
+		Hash     string `json:"hash"`
+		SavePath string `json:"save_path"`
+	}
+	resp, err = client.Get(base + "/api/v2/torrents/info?filter=seeding")
+	if err := decodeResponse(resp, err, &torrents); err != nil {
+		return nil, err
+	}
+	result := make(map[string]bool)
+	for _, torrent := range torrents {
+		var files []struct {
+			Name string `json:"name"`
+		}
+		resp, err := client.Get(base + "/api/v2/torrents/files?hash=" + torrent.Hash)
+		if err := decodeResponse(resp, err, &files); err != nil {
+			return nil, err
+		}
+		for _, file := range files {
+			result[filepath.Join(torrent.SavePath, filepath.FromSlash(file.Name))] = true
+		}
+	}
+	return result, nil
+}
+
+func transmissionSeeding(client *http.Client, base string) (map[string]bool, error) {
+	body, err := json.Marshal(map[string]interface{}{
+		"method":    "torrent-get",
+		"arguments": map[string]interface{}{"fields": []string{"status", "downloadDir", "files"}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	post := func(session string) (*http.Response, error) {
+		req, err := http.NewRequest(http.MethodPost, base+"/transmission/rpc", bytes.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+		req.SetBasicAuth(torrentUser, torrentPassword)
+		req.Header.Set("X-Transmission-Session-Id", session)
+		return client.Do(req)
+	}
+	resp, err := post("")
+	if err == nil && resp.StatusCode == http.StatusConflict {
+		// the first request only hands out the session id
+		resp.Body.Close()
+		resp, err = post(resp.Header.Get("X-Transmission-Session-Id"))
+	}
+	var result struct {
+		Arguments struct {
+			Torrents []struct {
+				Status      int    `json:"status"`
+				DownloadDir string `json:"downloadDir"`
+				Files       []struct {
+					Name string `json:"name"`
+				} `json:"files"`
+			} `json:"torrents"`
+		} `json:"arguments"`
+	}
+	if err := decodeResponse(resp, err, &result); err != nil {
+		return nil, err
+	}
+	seeding := make(map[string]bool)
+	for _, torrent := range result.Arguments.Torrents {
+		if torrent.Status != transmissionSeed && torrent.Status != transmissionSeedWait {
+			continue
+		}
+		for _, file := range torrent.Files {
+			seeding[filepath.Join(torrent.DownloadDir, filepath.FromSlash(file.Name))] = true
+		}
+	}
+	return seeding, nil
+}
+
+// withoutSeeding drops the files the torrent client is seeding from list
+func withoutSeeding(list []string) ([]string, int, error) {
+	if torrentClient == "" {
+		return list, 0, nil
+	}
+	seeding, err := seedingFiles()
+	if err != nil {
+		return nil, 0, fmt.Errorf("can't check seeding torrents: %v", err)
+	}
+	result := make([]string, 0, len(list))
+	for _, path := range list {
+		if !seeding[absPath(path)] {
+			result = append(result, path)
+		}
+	}
+	return result, len(list) - len(result), nil
+}
+
+func logSeeding(o *tOutcome, count int) {
+	if count > 0 {
+		o.logf("Kept %d duplicate file(s) seeded by %s", count, torrentClient)
+	}
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Deleted files go to the trash of the OS (the XDG trash on Linux and BSD,
+// ~/.Trash on macOS, the Recycle Bin on Windows) and can be put back from
+// there; -permanent removes them for good. A -recycle-dir takes precedence
+// over both.
+
+var permanentDelete bool
+
+// trash directories of every platform, on any volume
+var trashDirNames = map[string]bool{
+	".Trash":       true,
+	".Trashes":     true,
+	"$RECYCLE.BIN": true,
+	"RECYCLER":     true,
+}
+
+// removeFile moves path to the trash, or removes it with -permanent
+func removeFile(path string) error {
+	if permanentDelete {
+		return os.Remove(path)
+	}
+	return moveToTrash(path)
+}
+
+// deleteDuplicate deletes a duplicate of the given hash, keeping it in the
+// recycle area with -recycle-dir
+func deleteDuplicate(path, hash string) error {
+	if dryRun {
+		return nil
+	}
+	if recycleEnabled() {
+		if err := recycle(path, hash); err != nil {
+			return err
+		}
+		return os.Remove(path)
+	}
+	return removeFile(path)
+}
+
+// visitTrashDir skips trashes, what's in them was deleted already
+func visitTrashDir(path string) error {
+	name := filepath.Base(path)
+	if trashDirNames[name] || strings.HasPrefix(name, ".Trash-") {
+		return filepath.SkipDir
+	}
+	if home := homeTrash(); home != "" && absPath(path) == home {
+		return filepath.SkipDir
+	}
+	return nil
+}
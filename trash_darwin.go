@@ -0,0 +1,44 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// The macOS trash is ~/.Trash, files on other volumes go to
+// .Trashes/<uid> at the top of theirs, where Finder shows them too
+
+func homeTrash() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".Trash")
+}
+
+func moveToTrash(path string) error {
+	abs := absPath(path)
+	trash := homeTrash()
+	if trash == "" || deviceID(trash) != deviceID(abs) {
+		trash = filepath.Join(mountPoint(abs), ".Trashes", fmt.Sprint(os.Getuid()))
+	}
+	if err := os.MkdirAll(trash, 0700); err != nil {
+		return err
+	}
+	name := filepath.Base(abs)
+	ext := filepath.Ext(name)
+	for i := 1; ; i++ {
+		// Finder numbers the names the same way
+		trashed := name
+		if i > 1 {
+			trashed = fmt.Sprintf("%s %d%s", strings.TrimSuffix(name, ext), i, ext)
+		}
+		target := filepath.Join(trash, trashed)
+		if _, err := os.Lstat(target); err == nil {
+			continue
+		}
+		return os.Rename(abs, target)
+	}
+}
@@ -0,0 +1,19 @@
+//go:build !windows
+// +build !windows
+
+package main
+
+import "path/filepath"
+
+// mountPoint returns the top directory of the filesystem path is on
+func mountPoint(path string) string {
+	dir := filepath.Dir(absPath(path))
+	dev := deviceID(dir)
+	for {
+		parent := filepath.Dir(dir)
+		if parent == dir || deviceID(parent) != dev {
+			return dir
+		}
+		dir = parent
+	}
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"runtime"
+	"syscall"
+	"unsafe"
+)
+
+// Files go to the Recycle Bin through SHFileOperationW with FOF_ALLOWUNDO
+
+const (
+	foDelete          = 3
+	fofSilent         = 0x4
+	fofNoConfirmation = 0x10
+	fofAllowUndo      = 0x40
+	fofNoConfirmMkdir = 0x200
+	fofNoErrorUI      = 0x400
+	recycleBinFlags   = fofSilent | fofNoConfirmation | fofAllowUndo | fofNoErrorUI | fofNoConfirmMkdir
+)
+
+var (
+	shell32              = syscall.NewLazyDLL("shell32.dll")
+	procSHFileOperationW = shell32.NewProc("SHFileOperationW")
+)
+
+// the Recycle Bin is per volume, the walk skips $RECYCLE.BIN by name
+func homeTrash() string {
+	return ""
+}
+
+// shFileOp lays out a SHFILEOPSTRUCTW deleting from: the struct is packed
+// on 32-bit Windows and naturally aligned on 64-bit, it returns where
+// fAnyOperationsAborted is
+func shFileOp(from *uint16) ([]byte, int) {
+	if unsafe.Sizeof(uintptr(0)) == 8 {
+		op := make([]byte, 56)
+		binary.LittleEndian.PutUint32(op[8:], foDelete)
+		binary.LittleEndian.PutUint64(op[16:], uint64(uintptr(unsafe.Pointer(from))))
+		binary.LittleEndian.PutUint16(op[32:], recycleBinFlags)
+		return op, 36
+	}
+	op := make([]byte, 30)
+	binary.LittleEndian.PutUint32(op[4:], foDelete)
+	binary.LittleEndian.PutUint32(op[8:], uint32(uintptr(unsafe.Pointer(from))))
+	binary.LittleEndian.PutUint16(op[16:], recycleBinFlags)
+	return op, 18
+}
+
+func moveToTrash(path string) error {
+	from, err := syscall.UTF16FromString(absPath(path))
+	if err != nil {
+		return err
+	}
+	// pFrom is a list ended by an empty string
+	from = append(from, 0)
+	op, aborted := shFileOp(&from[0])
+	r, _, _ := procSHFileOperationW.Call(uintptr(unsafe.Pointer(&op[0])))
+	runtime.KeepAlive(from)
+	if r != 0 {
+		return fmt.Errorf("moving %s to the Recycle Bin failed: error 0x%x", path, r)
+	}
+	if binary.LittleEndian.Uint32(op[aborted:]) != 0 {
+		return fmt.Errorf("moving %s to the Recycle Bin was aborted", path)
+	}
+	return nil
+}
@@ -0,0 +1,90 @@
+//go:build !windows && !darwin
+// +build !windows,!darwin
+
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// The XDG trash: files/ holds the trashed files, info/ a .trashinfo file
+// for each with its original path, file managers put them back from there.
+// Files on another filesystem than the home trash go to .Trash-<uid> at the
+// top of their own.
+
+func homeTrash() string {
+	data := os.Getenv("XDG_DATA_HOME")
+	if data == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return ""
+		}
+		data = filepath.Join(home, ".local", "share")
+	}
+	return filepath.Join(data, "Trash")
+}
+
+func moveToTrash(path string) error {
+	abs := absPath(path)
+	trash, original := homeTrash(), abs
+	if trash != "" {
+		if err := os.MkdirAll(trash, 0700); err != nil {
+			return err
+		}
+	}
+	if trash == "" || deviceID(trash) != deviceID(abs) {
+		top := mountPoint(abs)
+		trash = filepath.Join(top, fmt.Sprintf(".Trash-%d", os.Getuid()))
+		rel, err := filepath.Rel(top, abs)
+		if err != nil {
+			return err
+		}
+		original = rel
+	}
+	for _, dir := range []string{"files", "info"} {
+		if err := os.MkdirAll(filepath.Join(trash, dir), 0700); err != nil {
+			return err
+		}
+	}
+	name := filepath.Base(abs)
+	for i := 1; ; i++ {
+		trashed := name
+		if i > 1 {
+			trashed = fmt.Sprintf("%s.%d", name, i)
+		}
+		// creating the info file reserves the name, unless files/ has an
+		// entry of that name without one, which rename would overwrite
+		info := filepath.Join(trash, "info", trashed+".trashinfo")
+		f, err := os.OpenFile(info, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+		if os.IsExist(err) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if _, err := os.Lstat(filepath.Join(trash, "files", trashed)); !os.IsNotExist(err) {
+			f.Close()
+			os.Remove(info)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+		_, err = fmt.Fprintf(f, "[Trash Info]\nPath=%s\nDeletionDate=%s\n",
+			(&url.URL{Path: original}).EscapedPath(), time.Now().Format("2006-01-02T15:04:05"))
+		if closeErr := f.Close(); err == nil {
+			err = closeErr
+		}
+		if err == nil {
+			err = os.Rename(abs, filepath.Join(trash, "files", trashed))
+		}
+		if err != nil {
+			os.Remove(info)
+		}
+		return err
+	}
+}
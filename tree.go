@@ -0,0 +1,276 @@
+package main
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync/atomic"
+
+	"code.cloudfoundry.org/bytefmt"
+	"github.com/rivo/tview"
+)
+
+// Dependency trees (node_modules) and repository clones are often copied
+// wholesale, they are compared as a whole and can be replaced by a symlink
+// to one canonical copy.
+var treeRootSet = make(map[string]bool)
+
+func isTreeRoot(path string) bool {
+	if filepath.Base(path) == "node_modules" {
+		return true
+	}
+	if scanGitDirs {
+		// without the .git directory two clones can't be told apart
+		_, err := os.Lstat(filepath.Join(path, ".git"))
+		return err == nil
+	}
+	return false
+}
+
+// visitTreeRoot records path if it's an outermost tree root, nested trees are
+// covered by their parent, always visited before them
+func visitTreeRoot(path string) {
+	if treeRootOf(path) == "" && isTreeRoot(path) {
+		treeRootSet[path] = true
+	}
+}
+
+func isWithin(path, dir string) bool {
+	if !strings.HasSuffix(dir, string(filepath.Separator)) {
+		// the root directory already ends with a separator
+		dir += string(filepath.Separator)
+	}
+	return strings.HasPrefix(path, dir)
+}
+
+func treeRootOf(path string) string {
+	for dir := filepath.Dir(path); ; dir = filepath.Dir(dir) {
+		if treeRootSet[dir] {
+			return dir
+		}
+		if parent := filepath.Dir(dir); parent == dir {
+			return ""
+		}
+	}
+}
+
+// duplicateTrees groups tree roots by the relative paths and hashes of their
+// files, with the group of every file in a tree
+func duplicateTrees() ([][]string, map[string]string) {
+	entries := make(map[string][]string)
+	groups := make(map[string]string)
+	for hash, list := range copyDuplicates() {
+		for _, d := range list {
+			root := treeRootOf(d.path)
+			if root == "" {
+				continue
+			}
+			rel, err := filepath.Rel(root, d.path)
+			if err != nil {
+				// the tree can't be compared, it's left alone
+				actionFailed(d.path, err)
+				entries[root] = append(entries[root], "\x00unknown\x00"+d.path)
+				continue
+			}
+			entries[root] = append(entries[root], rel+"\x00"+hash)
+			groups[d.path] = hash
+		}
+	}
+	digests := make(map[string][]string)
+	for root, list := range entries {
+		sort.Strings(list)
+		digest := fmt.Sprintf("%x", sha256.Sum256([]byte(strings.Join(list, "\x00"))))
+		digests[digest] = append(digests[digest], root)
+	}
+	result := make([][]string, 0)
+	for _, roots := range digests {
+		if len(roots) < 2 {
+			continue
+		}
+		sort.Strings(roots)
+		result = append(result, roots)
+	}
+	return result, groups
+}
+
+type tTreeEntry struct {
+	mode os.FileMode
+	size int64
+	// where a symlink points
+	target string
+}
+
+func treeListing(root string) (map[string]tTreeEntry, error) {
+	listing := make(map[string]tTreeEntry)
+	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		entry := tTreeEntry{mode: info.Mode()}
+		if info.Mode().IsRegular() {
+			entry.size = info.Size()
+		} else if info.Mode()&os.ModeSymlink != 0 {
+			if entry.target, err = os.Readlink(path); err != nil {
+				return err
+			}
+		}
+		listing[rel] = entry
+		return nil
+	})
+	return listing, err
+}
+
+// sameTree compares the structure of both trees, including the empty and
+// skipped files the hashes don't cover, then the content of every file of b:
+// in the same group as its copy in a when the group is sure, compared byte
+// by byte otherwise. b is deleted when they match.
+func sameTree(a, b string, groups map[string]string) bool {
+	listA, err := treeListing(a)
+	if err != nil {
+		actionFailed(a, err)
+		return false
+	}
+	listB, err := treeListing(b)
+	if err != nil {
+		actionFailed(b, err)
+		return false
+	}
+	if len(listA) != len(listB) {
+		return false
+	}
+	for rel, entry := range listA {
+		if other, exist := listB[rel]; !exist || other != entry {
+			return false
+		}
+	}
+	for rel, entry := range listB {
+		if !entry.mode.IsRegular() || entry.size == 0 {
+			continue
+		}
+		pathA, pathB := filepath.Join(a, rel), filepath.Join(b, rel)
+		group, exist := groups[pathB]
+		if exist && groups[pathA] == group && groupConfidence(group) == confidenceHigh {
+			continue
+		}
+		same, err := sameContent(pathA, pathB)
+		if err != nil {
+			actionFailed(pathB, err)
+			return false
+		}
+		if !same {
+			return false
+		}
+	}
+	return true
+}
+
+func replaceWithSymlink(dir, original string) error {
+	tmp := dir + ".dup-fu-tmp"
+	if err := os.Rename(dir, tmp); err != nil {
+		return err
+	}
+	if err := os.Symlink(original, dir); err != nil {
+		// put the tree back before giving up
+		if undo := os.Rename(tmp, dir); undo != nil {
+			return fmt.Errorf("%v, the tree is left at %s: %v", err, tmp, undo)
+		}
+		return err
+	}
+	return os.RemoveAll(tmp)
+}
+
+// groupedWithin returns the grouped files under dir
+func groupedWithin(dir string) []string {
+	dir = absPath(dir)
+	duplicatesLock.Lock()
+	defer duplicatesLock.Unlock()
+	var result []string
+	for _, list := range duplicates {
+		for _, d := range list {
+			if isWithin(absPath(d.path), dir) {
+				result = append(result, d.path)
+			}
+		}
+	}
+	return result
+}
+
+// treesToLink counts the trees linkDuplicateTrees would replace and sums
+// their grouped files
+func treesToLink() (int, uint64) {
+	trees, _ := duplicateTrees()
+	sizes := duplicateSizes()
+	count, size := 0, uint64(0)
+	for _, roots := range trees {
+		for _, dir := range roots[1:] {
+			count++
+			for _, path := range groupedWithin(dir) {
+				size += uint64(sizes[path])
+			}
+		}
+	}
+	return count, size
+}
+
+func treesQuestion(count int, size uint64) string {
+	return formatter.Sprintf("Replace %d duplicate tree(s), %s, with symlinks to their first copy?", count, bytefmt.ByteSize(size))
+}
+
+// confirmTrees is confirmRemoval for trees, every replaced tree is deleted
+func confirmTrees(app *tview.Application, right *tview.List) {
+	if !scanFinished() {
+		setStatus("Wait for the scan to finish")
+		return
+	}
+	count, size := treesToLink()
+	if count == 0 {
+		setStatus("No duplicate trees to link")
+		return
+	}
+	showModal(app, "confirm", treesQuestion(count, size), []string{"Yes", "No"}, func(label string) {
+		if label == "Yes" {
+			runAction(app, right, "Linking", linkDuplicateTrees)
+		}
+	})
+}
+
+func linkDuplicateTrees() tOutcome {
+	if !scanFinished() {
+		return tOutcome{summary: []string{"Wait for the scan to finish"}}
+	}
+	before := atomic.LoadInt64(&actionFailures)
+	count := 0
+	var o tOutcome
+	trees, groups := duplicateTrees()
+	for _, roots := range trees {
+		original := absPath(roots[0])
+		for _, dir := range roots[1:] {
+			if !sameTree(roots[0], dir, groups) {
+				continue
+			}
+			if dryRun {
+				o.report += fmt.Sprintf("would symlink %s to %s\n", dir, original)
+			} else {
+				within := groupedWithin(dir)
+				if err := replaceWithSymlink(dir, original); err != nil {
+					actionFailed(dir, err)
+					continue
+				}
+				o.gone = append(o.gone, within...)
+			}
+			count++
+		}
+	}
+	if !dryRun {
+		o.logf("Replaced %d duplicate tree(s) with symlinks", count)
+	}
+	logFailures(&o, before)
+	return o
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/rivo/tview"
+)
+
+// With -verify every duplicate is compared byte by byte with its original
+// before it's deleted or moved, so a hash collision never costs a file.
+// Ctrl+v verifies the whole list ahead of time, the progress shows in the
+// Stats panel and the removal reuses the results.
+
+var (
+	verifyCopies bool
+	verifyLock   sync.Mutex
+	// by duplicate path, true when it's the same as its original
+	verified                = make(map[string]bool)
+	verifyDone, verifyTotal int64
+	verifying               int32
+)
+
+func duplicateOriginals() map[string]string {
+	duplicatesLock.Lock()
+	defer duplicatesLock.Unlock()
+	result := make(map[string]string)
+	for _, list := range duplicates {
+		original, removed := splitGroup(list)
+		for _, d := range removed {
+			result[d.path] = original.path
+		}
+	}
+	return result
+}
+
+// verifyCopy compares path with its original once, later calls reuse the
+// result
+func verifyCopy(path, original string) (bool, error) {
+	verifyLock.Lock()
+	same, known := verified[path]
+	verifyLock.Unlock()
+	if known {
+		return same, nil
+	}
+	same, err := sameContent(path, original)
+	if err != nil {
+		return false, err
+	}
+	verifyLock.Lock()
+	verified[path] = same
+	verifyLock.Unlock()
+	return same, nil
+}
+
+// withoutMismatches drops the duplicates whose content differs from their
+// original despite the hash, with -verify. A copy that can't be compared is
+// kept and reported, the others are still acted on.
+func withoutMismatches(list []string) []string {
+	list = withoutChanged(list)
+	if !verifyCopies {
+		return list
+	}
+	originals := duplicateOriginals()
+	result := make([]string, 0, len(list))
+	for _, path := range list {
+		same, err := verifyCopy(path, originals[path])
+		if err != nil {
+			actionFailed(path, err)
+			continue
+		}
+		if same {
+			result = append(result, path)
+		}
+	}
+	return result
+}
+
+// mismatches counts the verified duplicates that differ from their original
+func mismatches() int {
+	verifyLock.Lock()
+	defer verifyLock.Unlock()
+	count := 0
+	for _, same := range verified {
+		if !same {
+			count++
+		}
+	}
+	return count
+}
+
+func logMismatches(o *tOutcome) {
+	if count := mismatches(); count > 0 {
+		o.logf("Kept %d duplicate file(s) differing from their original", count)
+	}
+	logChanged(o)
+}
+
+// verifyAll compares every listed duplicate with its original, showing the
+// progress at most once a second
+func verifyAll(left *tview.TextView) {
+	if !atomic.CompareAndSwapInt32(&verifying, 0, 1) {
+		setStatus("Verification already running")
+		return
+	}
+	defer atomic.StoreInt32(&verifying, 0)
+	list := listDuplicates()
+	originals := duplicateOriginals()
+	atomic.StoreInt64(&verifyTotal, int64(len(list)))
+	atomic.StoreInt64(&verifyDone, 0)
+	shown := time.Now()
+	for _, path := range list {
+		if _, err := verifyCopy(path, originals[path]); err != nil {
+			publishError(path, err)
+		}
+		atomic.AddInt64(&verifyDone, 1)
+		if time.Since(shown) > time.Second {
+			showStats(left)
+			shown = time.Now()
+		}
+	}
+	showStats(left)
+}
+
+func formatVerification() string {
+	total := atomic.LoadInt64(&verifyTotal)
+	if total == 0 {
+		return ""
+	}
+	return formatter.Sprintf("Verified: %d of %d, %d differ", atomic.LoadInt64(&verifyDone), total, mismatches())
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"io"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	vmDisksSkip    = "skip"
+	vmDisksPartial = "partial"
+	vmDisksFull    = "full"
+
+	// number of bytes read from the head and the tail of a VM disk image
+	partialChunkSize = 16 * 1024 * 1024
+)
+
+// hashing multi-hundred-GB disk images rarely finds anything actionable
+var vmDiskExtensions = map[string]bool{
+	".vmdk":  true,
+	".qcow2": true,
+	".vdi":   true,
+	".vhd":   true,
+	".vhdx":  true,
+}
+
+func validVMDisksMode(mode string) bool {
+	return mode == vmDisksSkip || mode == vmDisksPartial || mode == vmDisksFull
+}
+
+func isVMDisk(path string) bool {
+	return vmDiskExtensions[strings.ToLower(filepath.Ext(path))]
+}
+
+// partialChecksum hashes only the first and the last chunk bytes
+func partialChecksum(file string, size, chunk int64) ([]byte, error) {
+	f, err := openFile(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	h := newHash()
+	buf := make([]byte, 2*1024*1024)
+	if _, err = io.CopyBuffer(h, io.LimitReader(f, chunk), buf); err != nil {
+		return nil, err
+	}
+	if size > chunk {
+		offset := size - chunk
+		if offset < chunk {
+			offset = chunk
+		}
+		if _, err = f.Seek(offset, io.SeekStart); err != nil {
+			return nil, err
+		}
+		if _, err = io.CopyBuffer(h, f, buf); err != nil {
+			return nil, err
+		}
+	}
+	return h.Sum(nil), nil
+}
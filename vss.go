@@ -0,0 +1,71 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// On Windows -vss scans a shadow copy of the volume of every root, made for
+// the scan and deleted after it: files locked by the applications using them
+// are read, and consistently. The shadow copy is read like a -snapshot, the
+// files keep their live path for the actions. It takes an administrator.
+
+type tShadowCopy struct {
+	id, volume string
+}
+
+var (
+	useVSS       bool
+	shadowCopies []tShadowCopy
+	shadowLock   sync.Mutex
+
+	errVSSUnsupported = errors.New("-vss is Windows only, -snapshot reads an LVM, ZFS or Btrfs snapshot")
+)
+
+// createShadowCopies makes one shadow copy per volume and reads the roots
+// through them
+func createShadowCopies() error {
+	if !vssSupported {
+		return errVSSUnsupported
+	}
+	created := make(map[string]bool)
+	for _, root := range scanRoots() {
+		volume := strings.ToUpper(filepath.VolumeName(absPath(root)))
+		if created[volume] {
+			continue
+		}
+		if len(volume) != 2 || volume[1] != ':' {
+			removeShadowCopies()
+			return fmt.Errorf("-vss needs roots on a local volume: %s", root)
+		}
+		id, device, err := createShadowCopy(volume + `\`)
+		if err != nil {
+			removeShadowCopies()
+			return fmt.Errorf("couldn't create a shadow copy of %s: %v", volume, err)
+		}
+		created[volume] = true
+		shadowLock.Lock()
+		shadowCopies = append(shadowCopies, tShadowCopy{id, volume})
+		shadowLock.Unlock()
+		snapshots = append(snapshots, tRemap{volume + `\`, device + `\`})
+	}
+	sortPrefixes(snapshots)
+	return nil
+}
+
+// removeShadowCopies deletes the shadow copies of the scan, they'd take
+// space on the volume until then. It runs when the scan ends or is
+// interrupted, whichever comes first.
+func removeShadowCopies() {
+	shadowLock.Lock()
+	defer shadowLock.Unlock()
+	for _, s := range shadowCopies {
+		if err := deleteShadowCopy(s.id); err != nil {
+			publishError(s.volume, fmt.Errorf("couldn't delete the shadow copy %s: %v", s.id, err))
+		}
+	}
+	shadowCopies = nil
+}
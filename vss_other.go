@@ -0,0 +1,14 @@
+//go:build !windows
+// +build !windows
+
+package main
+
+const vssSupported = false
+
+func createShadowCopy(volume string) (string, string, error) {
+	return "", "", errVSSUnsupported
+}
+
+func deleteShadowCopy(id string) error {
+	return errVSSUnsupported
+}
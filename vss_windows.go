@@ -0,0 +1,42 @@
+package main
+
+import (
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+const vssSupported = true
+
+const createShadowScript = `$r = Invoke-CimMethod -ClassName Win32_ShadowCopy -MethodName Create -Arguments @{Volume='%s'; Context='ClientAccessible'}
+if ($r.ReturnValue -ne 0) { exit $r.ReturnValue }
+$s = Get-CimInstance Win32_ShadowCopy -Filter "ID='$($r.ShadowID)'"
+Write-Output $s.ID $s.DeviceObject`
+
+func powershell(script string) (string, error) {
+	out, err := exec.Command("powershell", "-NoProfile", "-NonInteractive", "-Command", script).Output()
+	if exit, ok := err.(*exec.ExitError); ok {
+		// Win32_ShadowCopy.Create codes, 2 is access denied
+		return "", fmt.Errorf("error %d, run dup-fu as an administrator", exit.ExitCode())
+	}
+	return string(out), err
+}
+
+// createShadowCopy returns the id and the device of a new shadow copy of
+// volume, its files are under the device
+func createShadowCopy(volume string) (string, string, error) {
+	out, err := powershell(fmt.Sprintf(createShadowScript, volume))
+	if err != nil {
+		return "", "", err
+	}
+	fields := strings.Fields(out)
+	if len(fields) != 2 {
+		return "", "", fmt.Errorf("unexpected output: %s", out)
+	}
+	return fields[0], fields[1], nil
+}
+
+func deleteShadowCopy(id string) error {
+	_, err := powershell(fmt.Sprintf(`Get-CimInstance Win32_ShadowCopy -Filter "ID='%s'" | Remove-CimInstance`, id))
+	return err
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+)
+
+// filepath.Walk reads one directory at a time, on network filesystems and
+// trees of millions of entries the walk becomes the bottleneck. Every device
+// reads up to -walkers directories at once instead: a directory found while
+// the pool is full is walked by the goroutine that found it. visit is still
+// called for a directory before its entries, its ignore file applies to
+// them, but directories no longer come in walk order. -sequential keeps
+// filepath.Walk, a spinning disk reads in walk order.
+
+type tWalker struct {
+	fn      filepath.WalkFunc
+	slots   chan struct{}
+	running sync.WaitGroup
+	lock    sync.Mutex
+	err     error
+}
+
+// walkTree calls fn for every file and directory under root like
+// filepath.Walk, -walkers directories at a time
+func walkTree(root string, options tOptions, fn filepath.WalkFunc) error {
+	if options.sequential || options.walkers == 1 {
+		return filepath.Walk(root, fn)
+	}
+	info, err := os.Lstat(root)
+	if err != nil {
+		return fn(root, nil, err)
+	}
+	if err := fn(root, info, nil); err != nil || !info.IsDir() {
+		if err == filepath.SkipDir {
+			return nil
+		}
+		return err
+	}
+	w := &tWalker{fn: fn, slots: make(chan struct{}, options.walkers-1)}
+	w.walkDir(root, info)
+	w.running.Wait()
+	return w.err
+}
+
+func (w *tWalker) fail(err error) {
+	w.lock.Lock()
+	if w.err == nil {
+		w.err = err
+	}
+	w.lock.Unlock()
+}
+
+func (w *tWalker) failed() bool {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	return w.err != nil
+}
+
+// walkDir visits the entries of a directory fn accepted
+func (w *tWalker) walkDir(path string, info os.FileInfo) {
+	names, err := readDirNames(path)
+	if err != nil {
+		if err := w.fn(path, info, err); err != nil && err != filepath.SkipDir {
+			w.fail(err)
+		}
+		return
+	}
+	for _, name := range names {
+		if w.failed() {
+			return
+		}
+		child := filepath.Join(path, name)
+		childInfo, err := os.Lstat(child)
+		if err != nil {
+			if err := w.fn(child, childInfo, err); err != nil && err != filepath.SkipDir {
+				w.fail(err)
+				return
+			}
+			continue
+		}
+		err = w.fn(child, childInfo, nil)
+		if err == filepath.SkipDir {
+			if childInfo.IsDir() {
+				continue
+			}
+			// a file skips the rest of its directory
+			return
+		}
+		if err != nil {
+			w.fail(err)
+			return
+		}
+		if childInfo.IsDir() {
+			w.spawn(child, childInfo)
+		}
+	}
+}
+
+// spawn walks the directory in a goroutine of its own while the pool has
+// room, here otherwise
+func (w *tWalker) spawn(path string, info os.FileInfo) {
+	select {
+	case w.slots <- struct{}{}:
+		w.running.Add(1)
+		go func() {
+			defer func() {
+				<-w.slots
+				w.running.Done()
+			}()
+			w.walkDir(path, info)
+		}()
+	default:
+		w.walkDir(path, info)
+	}
+}
+
+func readDirNames(dir string) ([]string, error) {
+	f, err := os.Open(dir)
+	if err != nil {
+		return nil, err
+	}
+	names, err := f.Readdirnames(-1)
+	f.Close()
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(names)
+	return names, nil
+}
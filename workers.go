@@ -0,0 +1,92 @@
+package main
+
+import (
+	"runtime"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"code.cloudfoundry.org/bytefmt"
+)
+
+// Every device gets -workers hashing workers, or by default as many as it
+// serves at once: a spinning disk seeks between the files read together, two
+// workers keep it busy, while an SSD or NVMe drive takes a read per CPU. The
+// Stats panel shows the throughput of every worker over the last seconds.
+
+// seconds the throughput is averaged over
+const throughputWindow = 5
+
+type tWorker struct {
+	root string
+	// bytes hashed, atomic
+	hashed int64
+	// hashed at the last ticks, oldest first
+	samples []int64
+}
+
+var (
+	hashWorkers []*tWorker
+	workersLock sync.Mutex
+)
+
+// deviceWorkers is -workers, or the count the device of root is worth
+func deviceWorkers(root string, workers int) int {
+	if workers > 0 {
+		return workers
+	}
+	if rotational, known := isRotational(root); known && rotational {
+		return 2
+	}
+	return runtime.NumCPU()
+}
+
+// startWorkers starts the hashing workers of the device of root
+func startWorkers(root string, files chan tFileData, options tOptions) {
+	count := deviceWorkers(root, options.workers)
+	hashers.Add(count)
+	for i := 0; i < count; i++ {
+		w := &tWorker{root: root, samples: make([]int64, throughputWindow+1)}
+		workersLock.Lock()
+		hashWorkers = append(hashWorkers, w)
+		workersLock.Unlock()
+		go calculateChecksum(files, w, options)
+	}
+}
+
+// sampleWorkers records what every worker hashed so far, once a second
+func sampleWorkers() {
+	workersLock.Lock()
+	defer workersLock.Unlock()
+	for _, w := range hashWorkers {
+		w.samples = append(w.samples[1:], atomic.LoadInt64(&w.hashed))
+	}
+}
+
+func (w *tWorker) throughput() uint64 {
+	return uint64(w.samples[len(w.samples)-1]-w.samples[0]) / throughputWindow
+}
+
+// formatWorkers lists the throughput of the workers, by device
+func formatWorkers() string {
+	workersLock.Lock()
+	defer workersLock.Unlock()
+	if len(hashWorkers) == 0 {
+		return ""
+	}
+	total := uint64(0)
+	var lines []string
+	line := ""
+	for i, w := range hashWorkers {
+		total += w.throughput()
+		if i == 0 || w.root != hashWorkers[i-1].root {
+			if line != "" {
+				lines = append(lines, line)
+			}
+			line = " " + w.root
+		}
+		line += formatter.Sprintf("  %s/s", bytefmt.ByteSize(w.throughput()))
+	}
+	lines = append(lines, line)
+	return formatter.Sprintf("Workers: %d, %s/s\n", len(hashWorkers), bytefmt.ByteSize(total)) + strings.Join(lines, "\n")
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// The original kept of a group is tagged with extended attributes once its
+// copies are acted on: user.dupfu.hash holds the content hash and
+// user.dupfu.scanned the time. Other tools can read them, and a later scan
+// keeps a tagged file as the original whatever -keep says. -no-xattr turns
+// tagging off, for filesystems without extended attributes.
+
+const (
+	xattrHash    = "user.dupfu.hash"
+	xattrScanned = "user.dupfu.scanned"
+)
+
+var (
+	noXattr             bool
+	errXattrUnsupported = errors.New("extended attributes aren't supported on this platform")
+)
+
+// isTagged tells whether path was kept as the original of the content hash
+func isTagged(path, hash string) bool {
+	if noXattr {
+		return false
+	}
+	value, err := getXattr(path, xattrHash)
+	return err == nil && value == hash
+}
+
+func tagOriginal(path, hash string) error {
+	if err := setXattr(path, xattrHash, hash); err != nil {
+		return err
+	}
+	return setXattr(path, xattrScanned, time.Now().UTC().Format(time.RFC3339))
+}
+
+// keptOriginals maps the originals of the copies acted on to their hash
+func keptOriginals(done []string) map[string]string {
+	originals := duplicateOriginals()
+	duplicatesLock.Lock()
+	hashes := duplicateHashes()
+	duplicatesLock.Unlock()
+	result := make(map[string]string)
+	for _, path := range done {
+		if original, ok := originals[path]; ok {
+			result[original] = hashes[original]
+		}
+	}
+	return result
+}
+
+// tagOriginals tags every original, the first failure stops it: the other
+// originals are likely on the same filesystem
+func tagOriginals(originals map[string]string) {
+	if noXattr || dryRun {
+		return
+	}
+	for path, hash := range originals {
+		if err := tagOriginal(path, hash); err != nil {
+			if err != errXattrUnsupported {
+				publishError(path, fmt.Errorf("couldn't tag the kept originals: %v, -no-xattr turns tagging off", err))
+			}
+			return
+		}
+	}
+}
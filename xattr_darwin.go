@@ -0,0 +1,56 @@
+package main
+
+import (
+	"syscall"
+	"unsafe"
+)
+
+// setxattr and getxattr take a resource fork position and options, both
+// unused here
+
+func setXattr(path, name, value string) error {
+	pathPtr, err := syscall.BytePtrFromString(path)
+	if err != nil {
+		return err
+	}
+	namePtr, err := syscall.BytePtrFromString(name)
+	if err != nil {
+		return err
+	}
+	valuePtr, err := syscall.BytePtrFromString(value)
+	if err != nil {
+		return err
+	}
+	_, _, errno := syscall.Syscall6(syscall.SYS_SETXATTR, uintptr(unsafe.Pointer(pathPtr)), uintptr(unsafe.Pointer(namePtr)),
+		uintptr(unsafe.Pointer(valuePtr)), uintptr(len(value)), 0, 0)
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+func getXattr(path, name string) (string, error) {
+	pathPtr, err := syscall.BytePtrFromString(path)
+	if err != nil {
+		return "", err
+	}
+	namePtr, err := syscall.BytePtrFromString(name)
+	if err != nil {
+		return "", err
+	}
+	// a nil value asks for its size
+	size, _, errno := syscall.Syscall6(syscall.SYS_GETXATTR, uintptr(unsafe.Pointer(pathPtr)), uintptr(unsafe.Pointer(namePtr)), 0, 0, 0, 0)
+	if errno != 0 {
+		return "", errno
+	}
+	if size == 0 {
+		return "", nil
+	}
+	value := make([]byte, size)
+	size, _, errno = syscall.Syscall6(syscall.SYS_GETXATTR, uintptr(unsafe.Pointer(pathPtr)), uintptr(unsafe.Pointer(namePtr)),
+		uintptr(unsafe.Pointer(&value[0])), uintptr(len(value)), 0, 0)
+	if errno != 0 {
+		return "", errno
+	}
+	return string(value[:size]), nil
+}
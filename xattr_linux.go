@@ -0,0 +1,21 @@
+package main
+
+import "syscall"
+
+func setXattr(path, name, value string) error {
+	return syscall.Setxattr(path, name, []byte(value), 0)
+}
+
+// getXattr asks for the size of the value first
+func getXattr(path, name string) (string, error) {
+	size, err := syscall.Getxattr(path, name, nil)
+	if err != nil || size == 0 {
+		return "", err
+	}
+	value := make([]byte, size)
+	n, err := syscall.Getxattr(path, name, value)
+	if err != nil {
+		return "", err
+	}
+	return string(value[:n]), nil
+}
@@ -0,0 +1,12 @@
+//go:build !linux && !darwin
+// +build !linux,!darwin
+
+package main
+
+func setXattr(path, name, value string) error {
+	return errXattrUnsupported
+}
+
+func getXattr(path, name string) (string, error) {
+	return "", errXattrUnsupported
+}